@@ -2,46 +2,97 @@ package main
 
 import (
 	"auth-service/pkg/dbwork"
+	"auth-service/pkg/deletion"
 	"auth-service/pkg/handlers"
+	"auth-service/pkg/router"
+	"auth-service/pkg/rpcserver"
+	"auth-service/pkg/service"
+	"common/config"
+	"common/health"
+	"common/logger"
+	"common/proto/authpb"
+	"common/rpc"
+	"common/tracing"
 	"context"
-	"log"
+	"net/http"
 	"time"
-
-	"github.com/gin-contrib/cors"
-
-	"github.com/gin-gonic/gin"
 )
 
+// readyTimeout ограничивает каждую проверку готовности.
+const readyTimeout = 2 * time.Second
+
 func main() {
 
-	ctx := context.Background()
-	db, err := dbwork.NewPostgreSQL(ctx, dbwork.LoadPSQLConfig())
+	health.Probe()
+
+	log := logger.New("auth_service")
+
+	tracingCfg, err := tracing.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации трассировки: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), "auth_service", tracingCfg)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка настройки трассировки: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	dbCfg, err := config.LoadPostgreSQL()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации бд: %v", err)
+	}
 
+	deletionCfg, err := deletion.LoadConfig()
 	if err != nil {
-		log.Fatalf("Ошибка подключения к бд: %v", err)
+		log.Fatal().Msgf("Ошибка конфигурации удаления аккаунтов: %v", err)
+	}
+
+	serviceCfg, err := service.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации аудита входов: %v", err)
+	}
+
+	tlsCfg, err := rpc.LoadTLS()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации gRPC: %v", err)
+	}
+
+	ctx := log.WithContext(context.Background())
+	db, err := dbwork.NewPostgreSQL(ctx, dbCfg)
+
+	if err != nil {
+		log.Fatal().Msgf("Ошибка подключения к бд: %v", err)
 	}
 
 	defer db.Close()
 
 	if err = db.RunMigrations(ctx, ""); err != nil {
-		log.Fatalf("Ошибка миграции бд: %v", err)
+		log.Fatal().Msgf("Ошибка миграции бд: %v", err)
+	}
+
+	go deletion.NewWorker(db, deletionCfg).Run(ctx)
+
+	grpcServer, err := rpc.NewServer(tlsCfg, log)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка создания gRPC сервера: %v", err)
 	}
+	authpb.RegisterAuthServer(grpcServer, rpcserver.New(db, serviceCfg))
+	go func() {
+		grpcAddr := (&config.Loader{}).StringOr("grpc_addr", ":9081")
+		if err := rpc.ListenAndServe(grpcServer, grpcAddr); err != nil {
+			log.Fatal().Msgf("Ошибка работы gRPC сервера: %v", err)
+		}
+	}()
 
-	handler := handlers.NewHandler(db)
+	handler := handlers.NewHandler(db, serviceCfg, deletionCfg.Grace)
 
-	r := gin.Default()
+	// Сервис готов принимать запросы, пока доступна бд с актуальной схемой
+	checker := health.New(readyTimeout).Add("database", db.Ready)
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://manage_service:8080"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-	r.POST("/registration", handler.Registration)
-	r.POST("/login", handler.Login)
+	r := router.New(handler, checker)
 
-	r.Run(":8081")
+	if err = http.ListenAndServe(":8081", tracing.Handler("auth_service", log, r)); err != nil {
+		log.Fatal().Msgf("Ошибка работы сервера: %v", err)
+	}
 
 }
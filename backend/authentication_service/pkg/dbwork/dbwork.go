@@ -2,20 +2,19 @@ package dbwork
 
 import (
 	"auth-service/pkg/models"
+	"common/audit"
+	"common/config"
+	"common/database"
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -23,97 +22,47 @@ var (
 	LoginBusy            = errors.New("Данный логин уже занят")
 	LoginNotFound        = errors.New("Неправильный логин или пароль")
 	PasswordIsNotCorrect = errors.New("Неправильный логин или пароль")
+	UserNotFound         = errors.New("Пользователь не найден")
+	AddressNotFound      = errors.New("Адрес не найден")
+	DeletionNotRequested = errors.New("Удаление аккаунта не запрошено")
 )
 
-func init() {
-	log.Logger = log.With().Str("package", "dbwork").Logger()
-}
-
-type PostgreSQLConfig struct {
-	User     string
-	Password string
-	Host     string
-	Port     int
-	DBName   string
-	SSLMode  string
-}
-
 type DataBase struct {
 	pool *pgxpool.Pool
+	log  *zerolog.Logger
+	// migrations - каталог миграций, по которому Ready сверяет версию схемы
+	migrations string
 }
 
-func LoadPSQLConfig() PostgreSQLConfig {
-	port, err := strconv.Atoi(os.Getenv("port"))
+func NewPostgreSQL(ctx context.Context, cfg config.PostgreSQL) (*DataBase, error) {
+	pool, err := database.Connect(ctx, cfg)
 	if err != nil {
-		log.Fatal().Msgf("LoadPSQLConfig getPort: %v", err)
+		return nil, fmt.Errorf("dbwork/NewPostgreSQL: %w", err)
 	}
-	return PostgreSQLConfig{
-		User:     os.Getenv("user"),
-		Password: os.Getenv("password"),
-		Host:     os.Getenv("host"),
-		Port:     port,
-		DBName:   os.Getenv("dbname"),
-		SSLMode:  os.Getenv("sslmode"),
-	}
-}
 
-func NewPostgreSQL(ctx context.Context, config PostgreSQLConfig) (*DataBase, error) {
-	log.Info().Msgf("Post cfg: %v", config)
-	connStr := fmt.Sprintf(
-		"postgres://%v:%v@%v:%v/%v?sslmode=%s",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-		config.SSLMode,
-	)
-
-	poolConfig, err := pgxpool.ParseConfig(connStr)
-	if err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL poolConfig: %v", err)
-	}
-
-	poolConfig.MaxConnIdleTime = time.Minute
-
-	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
-	if err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL newPool: %v", err)
-	}
-
-	if err := pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL ping: %v", err)
-	}
-	log.Info().Msg("Подключение к базе данных успешно!")
-
-	return &DataBase{pool: pool}, nil
+	return &DataBase{pool: pool, log: zerolog.Ctx(ctx)}, nil
 }
 
 func (db *DataBase) Close() {
 	if db.pool != nil {
 		db.pool.Close()
-		log.Info().Msg("Соеднинение с базой данных закрыто")
+		db.log.Info().Msg("Соеднинение с базой данных закрыто")
 	}
 }
 
 func (db *DataBase) RunMigrations(ctx context.Context, path string) error {
-	sqlDB := stdlib.OpenDBFromPool(db.pool)
-	defer sqlDB.Close()
-
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("Ошибка установки диалекта: %w", err)
-	}
-
 	if path == "" {
 		path = "pkg/dbwork/migrations"
 	}
+	db.migrations = path
 
-	if err := goose.Up(sqlDB, path); err != nil {
-		return fmt.Errorf("Ошибка применения миграций: %w", err)
-	}
+	return database.Migrate(ctx, db.pool, path)
+}
 
-	log.Info().Msg("Миграции успешно применены")
-	return nil
+// Ready проверяет, что бд доступна и на ней применены все миграции
+// сервиса.
+func (db *DataBase) Ready(ctx context.Context) error {
+	return database.Ready(ctx, db.pool, db.migrations)
 }
 
 func (db *DataBase) CreateUser(ctx context.Context, login, password string) (uuid.UUID, error) {
@@ -141,6 +90,8 @@ func (db *DataBase) CreateUser(ctx context.Context, login, password string) (uui
 
 }
 
+// VerifyPassword проверяет пароль login. При неправильном пароле вместе с
+// PasswordIsNotCorrect возвращается id пользователя.
 func (db *DataBase) VerifyPassword(ctx context.Context, login, password string) (uuid.UUID, bool, error) {
 
 	selectQuery := `SELECT id, login, password, admin
@@ -163,19 +114,440 @@ func (db *DataBase) VerifyPassword(ctx context.Context, login, password string)
 		return user.ID, user.Admin, nil
 	}
 
-	return uuid.Nil, false, PasswordIsNotCorrect
+	// id нужен для журнала аудита неудачных входов
+	return user.ID, false, PasswordIsNotCorrect
 }
 
-func (db *DataBase) MakeAdmin(ctx context.Context, login string) (uuid.UUID, error) {
+// MakeAdmin назначает пользователя id администратором и возвращает, был
+// ли он администратором до этого.
+func (db *DataBase) MakeAdmin(ctx context.Context, id uuid.UUID) (bool, error) {
+	// old - та же строка до изменения
 	updateQuery := `UPDATE users
-	                       SET admin = true
-						   WHERE login=$1
-						   RETURNING id`
-	id := uuid.Nil
+	                SET admin = true
+	                FROM users AS old
+	                WHERE users.id = $1 AND users.deleted_at IS NULL AND old.id = users.id
+	                RETURNING old.admin`
+	wasAdmin := false
+
+	err := db.pool.QueryRow(ctx, updateQuery, id).Scan(&wasAdmin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, UserNotFound
+		}
+		return false, fmt.Errorf("dbwork/MakeAdmin QueryRow: %v", err)
+	}
+	return wasAdmin, nil
+}
 
-	err := db.pool.QueryRow(ctx, updateQuery, login).Scan(&id)
+func (db *DataBase) GetProfile(ctx context.Context, id uuid.UUID) (models.Profile, error) {
+	selectQuery := `SELECT id, login, admin, registration_date, name, phone
+	                FROM users
+	                WHERE id = $1 AND deleted_at IS NULL`
+	profile := models.Profile{}
+
+	err := db.pool.QueryRow(ctx, selectQuery, id).Scan(
+		&profile.ID,
+		&profile.Login,
+		&profile.Admin,
+		&profile.RegData,
+		&profile.Name,
+		&profile.Phone,
+	)
 	if err != nil {
-		return uuid.Nil, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return profile, UserNotFound
+		}
+		return profile, fmt.Errorf("dbwork/GetProfile QueryRow: %v", err)
 	}
-	return id, nil
+
+	return profile, nil
+}
+
+// UpdateProfile меняет только переданные поля, nil означает "оставить как есть".
+func (db *DataBase) UpdateProfile(ctx context.Context, id uuid.UUID, name, phone *string) (models.Profile, error) {
+	updateQuery := `UPDATE users
+	                SET name = COALESCE($2, name),
+	                    phone = COALESCE($3, phone)
+	                WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := db.pool.Exec(ctx, updateQuery, id, name, phone)
+	if err != nil {
+		return models.Profile{}, fmt.Errorf("dbwork/UpdateProfile Exec: %v", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return models.Profile{}, UserNotFound
+	}
+
+	return db.GetProfile(ctx, id)
+}
+
+func (db *DataBase) ChangePassword(ctx context.Context, id uuid.UUID, currentPassword, newPassword string) error {
+	selectQuery := `SELECT password
+	                FROM users
+	                WHERE id = $1`
+	hashPassword := ""
+
+	if err := db.pool.QueryRow(ctx, selectQuery, id).Scan(&hashPassword); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return UserNotFound
+		}
+		return fmt.Errorf("dbwork/ChangePassword selectQuery: %v", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashPassword), []byte(currentPassword)); err != nil {
+		return PasswordIsNotCorrect
+	}
+
+	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("dbwork/ChangePassword generateHashPassword: %v", err)
+	}
+
+	updateQuery := `UPDATE users
+	                SET password = $2
+	                WHERE id = $1`
+
+	if _, err = db.pool.Exec(ctx, updateQuery, id, string(newHash)); err != nil {
+		return fmt.Errorf("dbwork/ChangePassword Exec: %v", err)
+	}
+
+	return nil
+}
+
+func (db *DataBase) ListAddresses(ctx context.Context, userID uuid.UUID) ([]models.Address, error) {
+	selectQuery := `SELECT id, user_id, city, street, house, apartment, postal_code, is_default
+	                FROM address
+	                WHERE user_id = $1
+	                ORDER BY is_default DESC, id`
+
+	rows, err := db.pool.Query(ctx, selectQuery, userID)
+	if err != nil {
+		return nil, fmt.Errorf("dbwork/ListAddresses Query: %v", err)
+	}
+	defer rows.Close()
+
+	addresses := make([]models.Address, 0)
+	for rows.Next() {
+		address := models.Address{}
+		if err := scanAddress(rows, &address); err != nil {
+			return nil, fmt.Errorf("dbwork/ListAddresses Scan: %v", err)
+		}
+		addresses = append(addresses, address)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbwork/ListAddresses rows: %v", err)
+	}
+
+	return addresses, nil
+}
+
+// CreateAddress делает первый адрес пользователя адресом по умолчанию.
+func (db *DataBase) CreateAddress(ctx context.Context, address models.Address) (models.Address, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return address, fmt.Errorf("dbwork/CreateAddress Begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	count := 0
+	countQuery := `SELECT COUNT(*) FROM address WHERE user_id = $1`
+	if err = tx.QueryRow(ctx, countQuery, address.UserID).Scan(&count); err != nil {
+		return address, fmt.Errorf("dbwork/CreateAddress countQuery: %v", err)
+	}
+
+	if count == 0 {
+		address.IsDefault = true
+	} else if address.IsDefault {
+		if err = resetDefaultAddress(ctx, tx, address.UserID); err != nil {
+			return address, fmt.Errorf("dbwork/CreateAddress: %v", err)
+		}
+	}
+
+	createQuery := `INSERT INTO address
+	                       (user_id, city, street, house, apartment, postal_code, is_default)
+	                       VALUES ($1, $2, $3, $4, $5, $6, $7)
+	                       RETURNING id`
+
+	err = tx.QueryRow(ctx, createQuery,
+		address.UserID,
+		address.City,
+		address.Street,
+		address.House,
+		address.Apartment,
+		address.PostalCode,
+		address.IsDefault,
+	).Scan(&address.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return address, UserNotFound
+		}
+		return address, fmt.Errorf("dbwork/CreateAddress QueryRow: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return address, fmt.Errorf("dbwork/CreateAddress Commit: %v", err)
+	}
+
+	return address, nil
+}
+
+func (db *DataBase) UpdateAddress(ctx context.Context, address models.Address) (models.Address, error) {
+	updateQuery := `UPDATE address
+	                SET city = $3, street = $4, house = $5, apartment = $6, postal_code = $7
+	                WHERE id = $1 AND user_id = $2
+	                RETURNING is_default`
+
+	err := db.pool.QueryRow(ctx, updateQuery,
+		address.ID,
+		address.UserID,
+		address.City,
+		address.Street,
+		address.House,
+		address.Apartment,
+		address.PostalCode,
+	).Scan(&address.IsDefault)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return address, AddressNotFound
+		}
+		return address, fmt.Errorf("dbwork/UpdateAddress QueryRow: %v", err)
+	}
+
+	return address, nil
+}
+
+// DeleteAddress при удалении адреса по умолчанию назначает новым
+// адресом по умолчанию самый старый из оставшихся.
+func (db *DataBase) DeleteAddress(ctx context.Context, userID uuid.UUID, id int64) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("dbwork/DeleteAddress Begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	deleteQuery := `DELETE FROM address
+	                WHERE id = $1 AND user_id = $2
+	                RETURNING is_default`
+	wasDefault := false
+
+	if err = tx.QueryRow(ctx, deleteQuery, id, userID).Scan(&wasDefault); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return AddressNotFound
+		}
+		return fmt.Errorf("dbwork/DeleteAddress QueryRow: %v", err)
+	}
+
+	if wasDefault {
+		updateQuery := `UPDATE address
+		                SET is_default = TRUE
+		                WHERE id = (SELECT id FROM address WHERE user_id = $1 ORDER BY id LIMIT 1)`
+		if _, err = tx.Exec(ctx, updateQuery, userID); err != nil {
+			return fmt.Errorf("dbwork/DeleteAddress updateQuery: %v", err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("dbwork/DeleteAddress Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (db *DataBase) SetDefaultAddress(ctx context.Context, userID uuid.UUID, id int64) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("dbwork/SetDefaultAddress Begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = resetDefaultAddress(ctx, tx, userID); err != nil {
+		return fmt.Errorf("dbwork/SetDefaultAddress: %v", err)
+	}
+
+	updateQuery := `UPDATE address
+	                SET is_default = TRUE
+	                WHERE id = $1 AND user_id = $2`
+
+	result, err := tx.Exec(ctx, updateQuery, id, userID)
+	if err != nil {
+		return fmt.Errorf("dbwork/SetDefaultAddress Exec: %v", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return AddressNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("dbwork/SetDefaultAddress Commit: %v", err)
+	}
+
+	return nil
+}
+
+func resetDefaultAddress(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	updateQuery := `UPDATE address
+	                SET is_default = FALSE
+	                WHERE user_id = $1 AND is_default`
+
+	if _, err := tx.Exec(ctx, updateQuery, userID); err != nil {
+		return fmt.Errorf("resetDefaultAddress Exec: %v", err)
+	}
+
+	return nil
+}
+
+func scanAddress(row pgx.Row, address *models.Address) error {
+	return row.Scan(
+		&address.ID,
+		&address.UserID,
+		&address.City,
+		&address.Street,
+		&address.House,
+		&address.Apartment,
+		&address.PostalCode,
+		&address.IsDefault,
+	)
+}
+
+// RequestDeletion планирует удаление аккаунта через grace. Повторный
+// запрос не сдвигает уже назначенную дату.
+func (db *DataBase) RequestDeletion(ctx context.Context, id uuid.UUID, grace time.Duration) (models.Deletion, error) {
+	updateQuery := `UPDATE users
+	                SET deletion_requested_at = COALESCE(deletion_requested_at, $2),
+	                    deletion_scheduled_at = COALESCE(deletion_scheduled_at, $3)
+	                WHERE id = $1 AND deleted_at IS NULL
+	                RETURNING deletion_requested_at, deletion_scheduled_at, deleted_at`
+	now := time.Now()
+	deletion := models.Deletion{}
+
+	err := db.pool.QueryRow(ctx, updateQuery, id, now, now.Add(grace)).Scan(
+		&deletion.RequestedAt,
+		&deletion.ScheduledAt,
+		&deletion.DeletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return deletion, UserNotFound
+		}
+		return deletion, fmt.Errorf("dbwork/RequestDeletion QueryRow: %v", err)
+	}
+
+	return deletion, nil
+}
+
+func (db *DataBase) CancelDeletion(ctx context.Context, id uuid.UUID) error {
+	updateQuery := `UPDATE users
+	                SET deletion_requested_at = NULL,
+	                    deletion_scheduled_at = NULL
+	                WHERE id = $1 AND deleted_at IS NULL AND deletion_scheduled_at IS NOT NULL`
+
+	result, err := db.pool.Exec(ctx, updateQuery, id)
+	if err != nil {
+		return fmt.Errorf("dbwork/CancelDeletion Exec: %v", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return DeletionNotRequested
+	}
+
+	return nil
+}
+
+func (db *DataBase) GetDeletion(ctx context.Context, id uuid.UUID) (models.Deletion, error) {
+	selectQuery := `SELECT deletion_requested_at, deletion_scheduled_at, deleted_at
+	                FROM users
+	                WHERE id = $1`
+	deletion := models.Deletion{}
+
+	err := db.pool.QueryRow(ctx, selectQuery, id).Scan(
+		&deletion.RequestedAt,
+		&deletion.ScheduledAt,
+		&deletion.DeletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return deletion, UserNotFound
+		}
+		return deletion, fmt.Errorf("dbwork/GetDeletion QueryRow: %v", err)
+	}
+
+	return deletion, nil
+}
+
+func (db *DataBase) ListDueDeletions(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	selectQuery := `SELECT id
+	                FROM users
+	                WHERE deletion_scheduled_at <= $1 AND deleted_at IS NULL
+	                ORDER BY deletion_scheduled_at
+	                LIMIT $2`
+
+	rows, err := db.pool.Query(ctx, selectQuery, time.Now(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("dbwork/ListDueDeletions Query: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		id := uuid.Nil
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("dbwork/ListDueDeletions Scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbwork/ListDueDeletions rows: %v", err)
+	}
+
+	return ids, nil
+}
+
+// AnonymizeUser стирает персональные данные, но оставляет строку users,
+// чтобы id пользователя в других сервисах не ссылался в пустоту.
+func (db *DataBase) AnonymizeUser(ctx context.Context, id uuid.UUID) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("dbwork/AnonymizeUser Begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	updateQuery := `UPDATE users
+	                SET login = 'deleted-' || id::text,
+	                    password = '',
+	                    name = '',
+	                    phone = '',
+	                    admin = FALSE,
+	                    deleted_at = $2
+	                WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := tx.Exec(ctx, updateQuery, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("dbwork/AnonymizeUser updateQuery: %v", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return UserNotFound
+	}
+
+	deleteQuery := `DELETE FROM address WHERE user_id = $1`
+	if _, err = tx.Exec(ctx, deleteQuery, id); err != nil {
+		return fmt.Errorf("dbwork/AnonymizeUser deleteQuery: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("dbwork/AnonymizeUser Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (db *DataBase) RecordAudit(ctx context.Context, entry audit.Entry) error {
+	return audit.Insert(ctx, db.pool, entry)
+}
+
+func (db *DataBase) ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return audit.List(ctx, db.pool, filter)
 }
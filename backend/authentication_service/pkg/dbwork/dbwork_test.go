@@ -2,6 +2,8 @@ package dbwork_test
 
 import (
 	"auth-service/pkg/dbwork"
+	"auth-service/pkg/models"
+	"common/config"
 	"context"
 	"fmt"
 	"path/filepath"
@@ -26,6 +28,10 @@ func TestMain(t *testing.T) {
 	VerifyPassword_LoginNotFound(t, db)
 	VerifyPassword_PasswordIsNotCorrect(t, db)
 	MakeAdmin_Success(t, db)
+	UpdateProfile_Success(t, db)
+	ChangePassword_Success(t, db)
+	Address_CRUD(t, db)
+	Deletion_Workflow(t, db)
 
 }
 
@@ -85,10 +91,10 @@ func VerifyPassword_PasswordIsNotCorrect(t *testing.T, db *dbwork.DataBase) {
 	assert.NoError(t, err)
 	assert.NotEqual(t, uuid.Nil, id)
 
-	id, admin, err := db.VerifyPassword(ctx, "Za_Alians", "Orda_lox")
+	userID, admin, err := db.VerifyPassword(ctx, "Za_Alians", "Orda_lox")
 	assert.Error(t, err)
 	assert.Equal(t, dbwork.PasswordIsNotCorrect, err)
-	assert.Equal(t, uuid.Nil, id)
+	assert.Equal(t, id, userID)
 	assert.Equal(t, false, admin)
 }
 
@@ -100,9 +106,16 @@ func MakeAdmin_Success(t *testing.T, db *dbwork.DataBase) {
 	assert.NoError(t, err)
 	assert.NotEqual(t, uuid.Nil, id)
 
-	newID, err := db.MakeAdmin(ctx, "LOLKEK")
+	wasAdmin, err := db.MakeAdmin(ctx, id)
 	assert.NoError(t, err)
-	assert.Equal(t, id, newID)
+	assert.False(t, wasAdmin)
+
+	wasAdmin, err = db.MakeAdmin(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, wasAdmin)
+
+	_, err = db.MakeAdmin(ctx, uuid.New())
+	assert.Equal(t, dbwork.UserNotFound, err)
 
 	id, admin, err := db.VerifyPassword(ctx, "LOLKEK", "Chebyrek")
 	assert.NoError(t, err)
@@ -110,6 +123,155 @@ func MakeAdmin_Success(t *testing.T, db *dbwork.DataBase) {
 	assert.Equal(t, true, admin)
 }
 
+func UpdateProfile_Success(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 3*time.Second)
+	defer cancel()
+
+	id, err := db.CreateUser(ctx, "profile_user", "profile_pass")
+	assert.NoError(t, err)
+
+	profile, err := db.GetProfile(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "profile_user", profile.Login)
+	assert.Equal(t, "", profile.Name)
+
+	name := "Иван Иванов"
+	profile, err = db.UpdateProfile(ctx, id, &name, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, name, profile.Name)
+	assert.Equal(t, "", profile.Phone)
+
+	phone := "+79999999999"
+	profile, err = db.UpdateProfile(ctx, id, nil, &phone)
+	assert.NoError(t, err)
+	assert.Equal(t, name, profile.Name)
+	assert.Equal(t, phone, profile.Phone)
+
+	_, err = db.GetProfile(ctx, uuid.New())
+	assert.Equal(t, dbwork.UserNotFound, err)
+}
+
+func ChangePassword_Success(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 4*time.Second)
+	defer cancel()
+
+	id, err := db.CreateUser(ctx, "change_pass", "old_pass")
+	assert.NoError(t, err)
+
+	err = db.ChangePassword(ctx, id, "wrong_pass", "new_pass")
+	assert.Equal(t, dbwork.PasswordIsNotCorrect, err)
+
+	err = db.ChangePassword(ctx, id, "old_pass", "new_pass")
+	assert.NoError(t, err)
+
+	_, _, err = db.VerifyPassword(ctx, "change_pass", "old_pass")
+	assert.Equal(t, dbwork.PasswordIsNotCorrect, err)
+
+	newID, _, err := db.VerifyPassword(ctx, "change_pass", "new_pass")
+	assert.NoError(t, err)
+	assert.Equal(t, id, newID)
+}
+
+func Address_CRUD(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 3*time.Second)
+	defer cancel()
+
+	id, err := db.CreateUser(ctx, "address_user", "address_pass")
+	assert.NoError(t, err)
+
+	first, err := db.CreateAddress(ctx, models.Address{UserID: id, City: "Москва", Street: "Тверская", House: "1"})
+	assert.NoError(t, err)
+	assert.True(t, first.IsDefault)
+
+	second, err := db.CreateAddress(ctx, models.Address{UserID: id, City: "Казань", Street: "Баумана", House: "2"})
+	assert.NoError(t, err)
+	assert.False(t, second.IsDefault)
+
+	err = db.SetDefaultAddress(ctx, id, second.ID)
+	assert.NoError(t, err)
+
+	addresses, err := db.ListAddresses(ctx, id)
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 2)
+	assert.Equal(t, second.ID, addresses[0].ID)
+	assert.True(t, addresses[0].IsDefault)
+	assert.False(t, addresses[1].IsDefault)
+
+	second.Apartment = "15"
+	updated, err := db.UpdateAddress(ctx, second)
+	assert.NoError(t, err)
+	assert.Equal(t, "15", updated.Apartment)
+	assert.True(t, updated.IsDefault)
+
+	err = db.DeleteAddress(ctx, id, second.ID)
+	assert.NoError(t, err)
+
+	addresses, err = db.ListAddresses(ctx, id)
+	assert.NoError(t, err)
+	assert.Len(t, addresses, 1)
+	assert.True(t, addresses[0].IsDefault)
+
+	err = db.DeleteAddress(ctx, uuid.New(), first.ID)
+	assert.Equal(t, dbwork.AddressNotFound, err)
+
+	err = db.SetDefaultAddress(ctx, id, second.ID)
+	assert.Equal(t, dbwork.AddressNotFound, err)
+}
+
+func Deletion_Workflow(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 4*time.Second)
+	defer cancel()
+
+	id, err := db.CreateUser(ctx, "delete_me", "delete_pass")
+	assert.NoError(t, err)
+
+	_, err = db.CreateAddress(ctx, models.Address{UserID: id, City: "Москва", Street: "Тверская", House: "1"})
+	assert.NoError(t, err)
+
+	deletion, err := db.RequestDeletion(ctx, id, time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, deletion.ScheduledAt)
+	scheduledAt := *deletion.ScheduledAt
+
+	deletion, err = db.RequestDeletion(ctx, id, 0)
+	assert.NoError(t, err)
+	assert.True(t, scheduledAt.Equal(*deletion.ScheduledAt))
+
+	err = db.CancelDeletion(ctx, id)
+	assert.NoError(t, err)
+
+	err = db.CancelDeletion(ctx, id)
+	assert.Equal(t, dbwork.DeletionNotRequested, err)
+
+	_, err = db.RequestDeletion(ctx, id, 0)
+	assert.NoError(t, err)
+
+	ids, err := db.ListDueDeletions(ctx, 100)
+	assert.NoError(t, err)
+	assert.Contains(t, ids, id)
+
+	err = db.AnonymizeUser(ctx, id)
+	assert.NoError(t, err)
+
+	_, err = db.GetProfile(ctx, id)
+	assert.Equal(t, dbwork.UserNotFound, err)
+
+	_, _, err = db.VerifyPassword(ctx, "delete_me", "delete_pass")
+	assert.Equal(t, dbwork.LoginNotFound, err)
+
+	addresses, err := db.ListAddresses(ctx, id)
+	assert.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	deletion, err = db.GetDeletion(ctx, id)
+	assert.NoError(t, err)
+	assert.NotNil(t, deletion.DeletedAt)
+
+	ids, err = db.ListDueDeletions(ctx, 100)
+	assert.NoError(t, err)
+	assert.NotContains(t, ids, id)
+}
+
 func setupTestDB() (*dbwork.DataBase, func(), error) {
 	dbName := "testdb"
 	dbUser := "test"
@@ -137,7 +299,7 @@ func setupTestDB() (*dbwork.DataBase, func(), error) {
 		return nil, nil, err
 	}
 
-	db, err := dbwork.NewPostgreSQL(ctx, dbwork.PostgreSQLConfig{
+	db, err := dbwork.NewPostgreSQL(ctx, config.PostgreSQL{
 		User:     dbUser,
 		Password: dbPassword,
 		Host:     host,
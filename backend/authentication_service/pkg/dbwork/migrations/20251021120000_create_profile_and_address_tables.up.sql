@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+  ADD COLUMN name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN phone TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE address(
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  city TEXT NOT NULL,
+  street TEXT NOT NULL,
+  house TEXT NOT NULL,
+  apartment TEXT NOT NULL DEFAULT '',
+  postal_code TEXT NOT NULL DEFAULT '',
+  is_default BOOL NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_address_user_id ON address(user_id);
+CREATE UNIQUE INDEX idx_address_user_default ON address(user_id) WHERE is_default;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS address;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS name,
+  DROP COLUMN IF EXISTS phone;
+-- +goose StatementEnd
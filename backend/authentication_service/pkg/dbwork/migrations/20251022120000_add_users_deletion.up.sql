@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+  ADD COLUMN deletion_requested_at TIMESTAMP,
+  ADD COLUMN deletion_scheduled_at TIMESTAMP,
+  ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+  WHERE deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users
+  DROP COLUMN IF EXISTS deletion_requested_at,
+  DROP COLUMN IF EXISTS deletion_scheduled_at,
+  DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,123 @@
+package deletion
+
+import (
+	"auth-service/pkg/dbwork"
+	"common/audit"
+	"common/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+type Config struct {
+	Grace       time.Duration
+	Interval    time.Duration
+	AuthorizURL string
+}
+
+// LoadConfig читает deletion_grace_days, deletion_interval_minutes и
+// authoriz_url. Неправильные значения - ошибка запуска, а не тихий откат
+// к значениям по умолчанию.
+func LoadConfig() (Config, error) {
+	l := &config.Loader{}
+	cfg := Config{
+		Grace:       l.DurationOr("deletion_grace_days", 24*time.Hour, 14*24*time.Hour, 0),
+		Interval:    l.DurationOr("deletion_interval_minutes", time.Minute, 10*time.Minute, 1),
+		AuthorizURL: l.StringOr("authoriz_url", "http://autoriz_service:8083"),
+	}
+	if err := l.Err(); err != nil {
+		return cfg, fmt.Errorf("deletion/LoadConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+// Worker доводит до конца удаления аккаунтов, у которых истёк grace период:
+// сначала удаляет сессии в authorization_service, затем обезличивает users.
+// Если authorization_service недоступен, пользователь будет обработан
+// на следующем тике, потому что deleted_at ещё не выставлен.
+type Worker struct {
+	db     *dbwork.DataBase
+	cfg    Config
+	client *http.Client
+}
+
+func NewWorker(db *dbwork.DataBase, cfg Config) *Worker {
+	return &Worker{
+		db:     db,
+		cfg:    cfg,
+		client: &http.Client{Timeout: 6 * time.Second},
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.ProcessDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) ProcessDue(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	ids, err := w.db.ListDueDeletions(ctx, 100)
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка получения аккаунтов на удаление: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := w.purgeSessions(ctx, id); err != nil {
+			log.Ctx(ctx).Error().Msgf("Ошибка удаления сессий пользователя %v: %v", id, err)
+			continue
+		}
+
+		if err := w.db.AnonymizeUser(ctx, id); err != nil {
+			log.Ctx(ctx).Error().Msgf("Ошибка обезличивания пользователя %v: %v", id, err)
+			continue
+		}
+
+		audit.Record(ctx, w.db, audit.ActionUserDelete, audit.Target("user", id), nil, nil)
+		log.Ctx(ctx).Info().Msgf("Аккаунт пользователя %v удалён", id)
+	}
+}
+
+func (w *Worker) purgeSessions(ctx context.Context, id uuid.UUID) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", w.cfg.AuthorizURL+"/user/"+id.String(), nil)
+	if err != nil {
+		return fmt.Errorf("deletion/purgeSessions http.NewRequest: %v", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("deletion/purgeSessions client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respPurge := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&respPurge); err != nil {
+		return fmt.Errorf("deletion/purgeSessions json.Decode: %v", err)
+	}
+
+	if respPurge.Code != http.StatusOK {
+		return fmt.Errorf("Ошибка на стороне authoriz: %v", respPurge.Message)
+	}
+
+	return nil
+}
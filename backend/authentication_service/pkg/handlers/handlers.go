@@ -3,78 +3,463 @@ package handlers
 import (
 	"auth-service/pkg/dbwork"
 	"auth-service/pkg/models"
+	"auth-service/pkg/service"
+	"common/audit"
+	"common/response"
+	"common/response/ginresponse"
 	"context"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-func init() {
-	log.Logger = log.With().Str("package", "handlers").Logger()
-}
+var phoneRegexp = regexp.MustCompile(`^\+?[0-9]{10,15}$`)
 
 type Handler struct {
-	db *dbwork.DataBase
+	db            *dbwork.DataBase
+	service       *service.Service
+	deletionGrace time.Duration
+	audit         http.Handler
 }
 
-func NewHandler(db *dbwork.DataBase) *Handler {
-	return &Handler{db: db}
+func NewHandler(db *dbwork.DataBase, serviceCfg service.Config, deletionGrace time.Duration) *Handler {
+	return &Handler{db: db, service: service.New(db, serviceCfg), deletionGrace: deletionGrace, audit: audit.Handler("auth_service", db.ListAudit)}
 }
 
 func (handler *Handler) Registration(c *gin.Context) {
 	user := models.RUser{}
 	if err := c.ShouldBindJSON(&user); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка получения данных из json: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения данных из json: %v", err)
+		return
+	}
+
+	id, resp := handler.service.Register(c.Request.Context(), user.Login, user.Password)
+	if resp.Failed() {
+		ginresponse.SendProblem(c, resp.Code, resp.ErrorCode, resp.Message)
+		return
+	}
+
+	models.SendResponse(c, resp.Code, resp.Message, id, false)
+}
+
+func (handler *Handler) Login(c *gin.Context) {
+	user := models.RUser{}
+	if err := c.ShouldBindJSON(&user); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения данных из json: %v", err)
+		return
+	}
+
+	id, admin, resp := handler.service.Login(c.Request.Context(), user.Login, user.Password)
+	if resp.Failed() {
+		ginresponse.SendProblem(c, resp.Code, resp.ErrorCode, resp.Message)
+		return
+	}
+
+	models.SendResponse(c, resp.Code, resp.Message, id, admin)
+}
+
+func (handler *Handler) GetProfile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
-	id, err := handler.db.CreateUser(ctx, user.Login, user.Password)
-	if err == dbwork.LoginBusy {
-		models.SendResponse(c, http.StatusConflict, err.Error(), uuid.Nil, false)
+	profile, err := handler.db.GetProfile(ctx, id)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
 		return
 	}
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка создания нового пользователя: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения профиля: %v", err)
 		return
 	}
 
-	models.SendResponse(c, http.StatusCreated, "Пользователь успешно зарегистрирован", id, false)
+	models.SendProfile(c, "Профиль пользователя получен", profile)
 }
 
-func (handler *Handler) Login(c *gin.Context) {
-	user := models.RUser{}
-	if err := c.ShouldBindJSON(&user); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка получения данных из json: %v", err)
+func (handler *Handler) UpdateProfile(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	req := models.RequestUpdateProfile{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения данных из json: %v", err)
 		return
 	}
 
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if utf8.RuneCountInString(*req.Name) > 100 {
+			ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, "Имя не должно быть длиннее 100 символов")
+			return
+		}
+	}
+	if req.Phone != nil {
+		*req.Phone = strings.TrimSpace(*req.Phone)
+		if *req.Phone != "" && !phoneRegexp.MatchString(*req.Phone) {
+			ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, "Неправильный формат номера телефона")
+			return
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
-	id, admin, err := handler.db.VerifyPassword(ctx, user.Login, user.Password)
-	if err == dbwork.LoginNotFound {
-		models.SendResponse(c, http.StatusNotFound, err.Error(), uuid.Nil, false)
+	profile, err := handler.db.UpdateProfile(ctx, id, req.Name, req.Phone)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка обновления профиля: %v", err)
+		return
+	}
+
+	models.SendProfile(c, "Профиль пользователя обновлён", profile)
+}
+
+func (handler *Handler) ChangePassword(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	req := models.RequestChangePassword{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.NewPassword == "" {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	err = handler.db.ChangePassword(ctx, id, req.CurrentPassword, req.NewPassword)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
 		return
 	}
 	if err == dbwork.PasswordIsNotCorrect {
-		models.SendResponse(c, http.StatusUnauthorized, err.Error(), uuid.Nil, false)
-		log.Error().Msgf("Пользователь ввёл неправильный пароль")
+		ginresponse.SendProblem(c, http.StatusForbidden, response.CodeWrongPassword, "Неправильный текущий пароль")
 		return
 	}
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки пароль: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка смены пароля: %v", err)
 		return
 	}
+	audit.Record(ctx, handler.db, audit.ActionUserPasswordChange, audit.Target("user", id), nil, nil)
+
+	models.SendResponse(c, http.StatusOK, "Пароль успешно изменён", id, false)
+}
+
+// GrantAdmin назначает пользователя администратором. Права действуют
+// с его следующего входа: в уже выданных токенах admin не меняется.
+func (handler *Handler) GrantAdmin(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	wasAdmin, err := handler.db.MakeAdmin(ctx, id)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка назначения администратора: %v", err)
+		return
+	}
+	audit.Record(ctx, handler.db, audit.ActionUserRoleGrant, audit.Target("user", id),
+		map[string]bool{"admin": wasAdmin}, map[string]bool{"admin": true})
+
+	models.SendResponse(c, http.StatusOK, "Пользователь назначен администратором", id, true)
+}
+
+func (handler *Handler) ListAddresses(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	addresses, err := handler.db.ListAddresses(ctx, id)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения адресов: %v", err)
+		return
+	}
+
+	models.SendAddresses(c, addresses)
+}
+
+func (handler *Handler) CreateAddress(c *gin.Context) {
+	address, ok := bindAddress(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	address, err := handler.db.CreateAddress(ctx, address)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания адреса: %v", err)
+		return
+	}
+
+	models.SendAddress(c, http.StatusCreated, "Адрес успешно добавлен", address)
+}
+
+func (handler *Handler) UpdateAddress(c *gin.Context) {
+	address, ok := bindAddress(c)
+	if !ok {
+		return
+	}
+
+	addressID, err := strconv.ParseInt(c.Param("address_id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+	address.ID = addressID
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	address, err = handler.db.UpdateAddress(ctx, address)
+	if err == dbwork.AddressNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeAddressNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка обновления адреса: %v", err)
+		return
+	}
+
+	models.SendAddress(c, http.StatusOK, "Адрес успешно обновлён", address)
+}
+
+func (handler *Handler) DeleteAddress(c *gin.Context) {
+	handler.changeAddress(c, handler.db.DeleteAddress, "Адрес успешно удалён")
+}
+
+func (handler *Handler) SetDefaultAddress(c *gin.Context) {
+	handler.changeAddress(c, handler.db.SetDefaultAddress, "Адрес по умолчанию изменён")
+}
+
+func (handler *Handler) changeAddress(
+	c *gin.Context,
+	change func(ctx context.Context, userID uuid.UUID, id int64) error,
+	message string,
+) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	addressID, err := strconv.ParseInt(c.Param("address_id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	err = change(ctx, id, addressID)
+	if err == dbwork.AddressNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeAddressNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка изменения адреса: %v", err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, message, id, false)
+}
+
+func bindAddress(c *gin.Context) (models.Address, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return models.Address{}, false
+	}
+
+	req := models.RequestAddress{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения данных из json: %v", err)
+		return models.Address{}, false
+	}
+
+	address := models.Address{
+		UserID:     id,
+		City:       strings.TrimSpace(req.City),
+		Street:     strings.TrimSpace(req.Street),
+		House:      strings.TrimSpace(req.House),
+		Apartment:  strings.TrimSpace(req.Apartment),
+		PostalCode: strings.TrimSpace(req.PostalCode),
+		IsDefault:  req.IsDefault,
+	}
+
+	if address.City == "" || address.Street == "" || address.House == "" {
+		ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, "Город, улица и дом обязательны")
+		return models.Address{}, false
+	}
+
+	return address, true
+}
+
+func (handler *Handler) ExportUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	profile, err := handler.db.GetProfile(ctx, id)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения профиля: %v", err)
+		return
+	}
+
+	addresses, err := handler.db.ListAddresses(ctx, id)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения адресов: %v", err)
+		return
+	}
+
+	deletion, err := handler.db.GetDeletion(ctx, id)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения статуса удаления: %v", err)
+		return
+	}
+
+	models.SendExport(c, profile, addresses, deletion)
+}
+
+func (handler *Handler) GetDeletion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	deletion, err := handler.db.GetDeletion(ctx, id)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения статуса удаления: %v", err)
+		return
+	}
+
+	models.SendDeletion(c, http.StatusOK, "Статус удаления аккаунта получен", deletion)
+}
+
+func (handler *Handler) RequestDeletion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	deletion, err := handler.db.RequestDeletion(ctx, id, handler.deletionGrace)
+	if err == dbwork.UserNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeUserNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка запроса удаления аккаунта: %v", err)
+		return
+	}
+	audit.Record(ctx, handler.db, audit.ActionUserDeletionRequest, audit.Target("user", id), nil, deletion)
+
+	models.SendDeletion(c, http.StatusAccepted, "Удаление аккаунта запланировано", deletion)
+}
+
+func (handler *Handler) CancelDeletion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	err = handler.db.CancelDeletion(ctx, id)
+	if err == dbwork.DeletionNotRequested {
+		ginresponse.SendProblem(c, http.StatusConflict, response.CodeDeletionNotRequested, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка отмены удаления аккаунта: %v", err)
+		return
+	}
+	audit.Record(ctx, handler.db, audit.ActionUserDeletionCancel, audit.Target("user", id), nil, nil)
+
+	models.SendResponse(c, http.StatusOK, "Удаление аккаунта отменено", id, false)
+}
 
-	models.SendResponse(c, http.StatusOK, "Пользователь успешно вошёл", id, admin)
+// Audit отдаёт журнал аудита сервиса для manage_service.
+func (handler *Handler) Audit(c *gin.Context) {
+	handler.audit.ServeHTTP(c.Writer, c.Request)
 }
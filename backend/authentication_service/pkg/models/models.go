@@ -12,4 +12,32 @@ type User struct {
 	Login    string
 	RegData  time.Time
 	Password string
+	Name     string
+	Phone    string
+}
+
+type Profile struct {
+	ID      uuid.UUID `json:"id"`
+	Login   string    `json:"login"`
+	Admin   bool      `json:"admin"`
+	RegData time.Time `json:"registration_date"`
+	Name    string    `json:"name"`
+	Phone   string    `json:"phone"`
+}
+
+type Address struct {
+	ID         int64     `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	City       string    `json:"city"`
+	Street     string    `json:"street"`
+	House      string    `json:"house"`
+	Apartment  string    `json:"apartment"`
+	PostalCode string    `json:"postal_code"`
+	IsDefault  bool      `json:"is_default"`
+}
+
+type Deletion struct {
+	RequestedAt *time.Time `json:"requested_at"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
 }
@@ -24,6 +24,43 @@ type RUser struct {
 	Password string `json:"password"`
 }
 
+type RequestUpdateProfile struct {
+	Name  *string `json:"name"`
+	Phone *string `json:"phone"`
+}
+
+type RequestChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RequestAddress struct {
+	City       string `json:"city"`
+	Street     string `json:"street"`
+	House      string `json:"house"`
+	Apartment  string `json:"apartment"`
+	PostalCode string `json:"postal_code"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+type ResponseProfile struct {
+	Code    int     `json:"code"`
+	Message string  `json:"message"`
+	Profile Profile `json:"profile"`
+}
+
+type ResponseAddress struct {
+	Code    int     `json:"code"`
+	Message string  `json:"message"`
+	Address Address `json:"address"`
+}
+
+type ResponseAddresses struct {
+	Code      int       `json:"code"`
+	Message   string    `json:"message"`
+	Addresses []Address `json:"addresses"`
+}
+
 func SendResponse(c *gin.Context, code int, message string, id uuid.UUID, admin bool) {
 	c.JSON(code, Response{
 		Code:    code,
@@ -33,18 +70,58 @@ func SendResponse(c *gin.Context, code int, message string, id uuid.UUID, admin
 	})
 }
 
-func SendBadRequest(c *gin.Context) {
-	c.JSON(http.StatusBadRequest, Response{
-		Code:    http.StatusBadRequest,
-		Message: "Ошибка запроса, невозможно прочитать данные",
-		ID:      uuid.Nil,
+type ResponseDeletion struct {
+	Code     int      `json:"code"`
+	Message  string   `json:"message"`
+	Deletion Deletion `json:"deletion"`
+}
+
+type ResponseExport struct {
+	Code      int       `json:"code"`
+	Message   string    `json:"message"`
+	Profile   Profile   `json:"profile"`
+	Addresses []Address `json:"addresses"`
+	Deletion  Deletion  `json:"deletion"`
+}
+
+func SendDeletion(c *gin.Context, code int, message string, deletion Deletion) {
+	c.JSON(code, ResponseDeletion{
+		Code:     code,
+		Message:  message,
+		Deletion: deletion,
+	})
+}
+
+func SendExport(c *gin.Context, profile Profile, addresses []Address, deletion Deletion) {
+	c.JSON(http.StatusOK, ResponseExport{
+		Code:      http.StatusOK,
+		Message:   "Данные пользователя выгружены",
+		Profile:   profile,
+		Addresses: addresses,
+		Deletion:  deletion,
+	})
+}
+
+func SendProfile(c *gin.Context, message string, profile Profile) {
+	c.JSON(http.StatusOK, ResponseProfile{
+		Code:    http.StatusOK,
+		Message: message,
+		Profile: profile,
+	})
+}
+
+func SendAddress(c *gin.Context, code int, message string, address Address) {
+	c.JSON(code, ResponseAddress{
+		Code:    code,
+		Message: message,
+		Address: address,
 	})
 }
 
-func SendInternalServerError(c *gin.Context) {
-	c.JSON(http.StatusInternalServerError, Response{
-		Code:    http.StatusInternalServerError,
-		Message: "Неизвестная ошибка сервера",
-		ID:      uuid.Nil,
+func SendAddresses(c *gin.Context, addresses []Address) {
+	c.JSON(http.StatusOK, ResponseAddresses{
+		Code:      http.StatusOK,
+		Message:   "Список адресов получен",
+		Addresses: addresses,
 	})
 }
@@ -0,0 +1,58 @@
+// Package router собирает http маршруты сервиса.
+package router
+
+import (
+	"auth-service/api"
+	"auth-service/pkg/handlers"
+	"common/audit"
+	"common/health"
+	"common/metrics"
+	"common/metrics/ginmetrics"
+	"common/openapi"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// New возвращает gin с маршрутами всех обработчиков и спецификацией API.
+// /readyz выполняет проверки checker.
+func New(handler *handlers.Handler, checker *health.Checker) *gin.Engine {
+	// Запросы пишет в лог tracing.Handler вместе с идентификатором запроса
+	r := gin.New()
+	r.Use(gin.Recovery(), ginmetrics.Middleware)
+
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://manage_service:8080"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	r.GET(openapi.Path, gin.WrapF(openapi.Handler(api.Spec)))
+	r.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+	r.GET(health.LivePath, gin.WrapH(health.Live()))
+	r.GET(health.ReadyPath, gin.WrapH(checker.Ready()))
+	r.GET(audit.Path, handler.Audit)
+
+	r.POST("/registration", handler.Registration)
+	r.POST("/login", handler.Login)
+
+	r.GET("/user/:id", handler.GetProfile)
+	r.PATCH("/user/:id", handler.UpdateProfile)
+	r.PUT("/user/:id/password", handler.ChangePassword)
+	r.PUT("/user/:id/admin", handler.GrantAdmin)
+	r.GET("/user/:id/address", handler.ListAddresses)
+	r.POST("/user/:id/address", handler.CreateAddress)
+	r.PUT("/user/:id/address/:address_id", handler.UpdateAddress)
+	r.DELETE("/user/:id/address/:address_id", handler.DeleteAddress)
+	r.PUT("/user/:id/address/:address_id/default", handler.SetDefaultAddress)
+	r.GET("/user/:id/export", handler.ExportUser)
+	r.GET("/user/:id/deletion", handler.GetDeletion)
+	r.POST("/user/:id/deletion", handler.RequestDeletion)
+	r.DELETE("/user/:id/deletion", handler.CancelDeletion)
+
+	return r
+}
@@ -0,0 +1,260 @@
+package router_test
+
+import (
+	"auth-service/api"
+	"auth-service/pkg/dbwork"
+	"auth-service/pkg/handlers"
+	"auth-service/pkg/router"
+	"auth-service/pkg/service"
+	"common/audit"
+	"common/config"
+	"common/health"
+	"common/openapi/contract"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+type client struct {
+	t         *testing.T
+	handler   http.Handler
+	validator *contract.Validator
+}
+
+func newClient(t *testing.T, db *dbwork.DataBase) *client {
+	gin.SetMode(gin.TestMode)
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	checker := health.New(time.Second).Add("database", db.Ready)
+	return &client{t: t, handler: router.New(handlers.NewHandler(db, service.Config{LoginKey: []byte("test")}, time.Hour), checker), validator: validator}
+}
+
+// do выполняет запрос и проверяет ответ по спецификации.
+func (c *client) do(method, path, body string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec, err := c.validator.Do(c.handler, req)
+	require.NoError(c.t, err)
+	return rec
+}
+
+func (c *client) decode(rec *httptest.ResponseRecorder, v any) {
+	c.t.Helper()
+	require.NoError(c.t, json.Unmarshal(rec.Body.Bytes(), v))
+}
+
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	problem := struct {
+		Code string `json:"code"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	return problem.Code
+}
+
+// Ошибки разбора запроса возвращаются до обращения к бд
+func TestContractBadRequest(t *testing.T) {
+	c := newClient(t, nil)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", "/openapi.json", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("GET", "/healthz", "").Code)
+
+	const user = "/user/00000000-0000-0000-0000-000000000001"
+	cases := []struct {
+		method, path, body string
+	}{
+		{"POST", "/registration", "{"},
+		{"POST", "/login", "[]"},
+		{"GET", "/user/abc", ""},
+		{"PATCH", user, "{"},
+		{"PUT", user + "/password", `{"current_password":"old"}`},
+		{"PUT", "/user/abc/admin", ""},
+		{"GET", "/user/abc/address", ""},
+		{"POST", user + "/address", `{"city":"Москва"}`},
+		{"PUT", user + "/address/abc", `{"city":"Москва","street":"Тверская","house":"1"}`},
+		{"DELETE", user + "/address/abc", ""},
+		{"PUT", "/user/abc/address/1/default", ""},
+		{"GET", "/user/abc/export", ""},
+		{"GET", "/user/abc/deletion", ""},
+		{"POST", "/user/abc/deletion", ""},
+		{"DELETE", "/user/abc/deletion", ""},
+		{"GET", "/audit?limit=0", ""},
+	}
+	for _, tc := range cases {
+		rec := c.do(tc.method, tc.path, tc.body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "%s %s", tc.method, tc.path)
+	}
+
+	rec := c.do("PATCH", user, `{"phone":"телефон"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "VALIDATION_FAILED", problemCode(t, rec))
+}
+
+func TestContract(t *testing.T) {
+	db, clean, err := setupTestDB()
+	require.NoError(t, err)
+	defer clean()
+
+	c := newClient(t, db)
+
+	rec := c.do("GET", "/readyz", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"database":{"status":"ok"`)
+
+	rec = c.do("POST", "/registration", `{"login":"contract","password":"pass"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	user := struct {
+		ID string `json:"id"`
+	}{}
+	c.decode(rec, &user)
+	path := "/user/" + user.ID
+
+	rec = c.do("POST", "/registration", `{"login":"contract","password":"pass"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "LOGIN_TAKEN", problemCode(t, rec))
+
+	assert.Equal(t, http.StatusOK, c.do("POST", "/login", `{"login":"contract","password":"pass"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, c.do("POST", "/login", `{"login":"contract","password":"wrong"}`).Code)
+	assert.Equal(t, http.StatusNotFound, c.do("POST", "/login", `{"login":"nobody","password":"pass"}`).Code)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", path, "").Code)
+	assert.Equal(t, http.StatusNotFound, c.do("GET", "/user/00000000-0000-0000-0000-000000000001", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("PATCH", path, `{"name":"Иван","phone":"+79990000000"}`).Code)
+
+	rec = c.do("PUT", path+"/password", `{"current_password":"wrong","new_password":"new"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "WRONG_PASSWORD", problemCode(t, rec))
+	assert.Equal(t, http.StatusOK, c.do("PUT", path+"/password", `{"current_password":"pass","new_password":"new"}`).Code)
+
+	assert.Equal(t, http.StatusOK, c.do("PUT", path+"/admin", "").Code)
+	rec = c.do("PUT", "/user/00000000-0000-0000-0000-000000000001/admin", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "USER_NOT_FOUND", problemCode(t, rec))
+
+	rec = c.do("POST", path+"/address", `{"city":"Москва","street":"Тверская","house":"1","is_default":true}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	address := struct {
+		Address struct {
+			ID int64 `json:"id"`
+		} `json:"address"`
+	}{}
+	c.decode(rec, &address)
+	addressPath := path + "/address/" + strconv.FormatInt(address.Address.ID, 10)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", path+"/address", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("PUT", addressPath, `{"city":"Москва","street":"Арбат","house":"2"}`).Code)
+	assert.Equal(t, http.StatusOK, c.do("PUT", addressPath+"/default", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("GET", path+"/export", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("DELETE", addressPath, "").Code)
+
+	rec = c.do("DELETE", addressPath, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "ADDRESS_NOT_FOUND", problemCode(t, rec))
+
+	assert.Equal(t, http.StatusOK, c.do("GET", path+"/deletion", "").Code)
+	assert.Equal(t, http.StatusAccepted, c.do("POST", path+"/deletion", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("DELETE", path+"/deletion", "").Code)
+
+	rec = c.do("DELETE", path+"/deletion", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "DELETION_NOT_REQUESTED", problemCode(t, rec))
+
+	rec = c.do("GET", "/audit?target=user:"+user.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	log := audit.Result{}
+	c.decode(rec, &log)
+	actions := make([]string, 0, len(log.Entries))
+	for _, entry := range log.Entries {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{
+		audit.ActionUserDeletionCancel,
+		audit.ActionUserDeletionRequest,
+		audit.ActionUserRoleGrant,
+		audit.ActionUserPasswordChange,
+		audit.ActionUserLoginFailed,
+		audit.ActionUserLogin,
+		audit.ActionUserRegister,
+	}, actions)
+	assert.Equal(t, user.ID, log.Entries[len(log.Entries)-1].Actor)
+	assert.JSONEq(t, `{"admin":false}`, string(log.Entries[2].Before))
+	assert.JSONEq(t, `{"admin":true}`, string(log.Entries[2].After))
+
+	// Логин в журнал не попадает: неправильный пароль записан на пользователя,
+	// несуществующий логин - его HMAC
+	rec = c.do("GET", "/audit?action=user.login_failed", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	c.decode(rec, &log)
+	require.Len(t, log.Entries, 2)
+	assert.Equal(t, service.LoginTarget([]byte("test"), "nobody"), log.Entries[0].Target)
+	assert.Equal(t, "user:"+user.ID, log.Entries[1].Target)
+}
+
+func setupTestDB() (*dbwork.DataBase, func(), error) {
+	dbName := "testdb"
+	dbUser := "test"
+	dbPassword := "pass"
+	ctx := context.Background()
+	pgContainer, err := postgres.Run(
+		ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase(dbName),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host, err := pgContainer.Host(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port, err := pgContainer.MappedPort(ctx, "5432")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := dbwork.NewPostgreSQL(ctx, config.PostgreSQL{
+		User:     dbUser,
+		Password: dbPassword,
+		Host:     host,
+		Port:     port.Int(),
+		DBName:   dbName,
+		SSLMode:  "disable",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, filename, _, _ := runtime.Caller(0)
+	migrationsPath := filepath.Join(filepath.Dir(filename), "..", "dbwork", "migrations")
+	if err = db.RunMigrations(ctx, migrationsPath); err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		db.Close()
+		_ = pgContainer.Terminate(ctx)
+	}
+
+	return db, cleanup, nil
+}
@@ -0,0 +1,36 @@
+// Package rpcserver - внутренний gRPC API сервиса для manage_service.
+// Работает рядом с http сервером и с той же бд.
+package rpcserver
+
+import (
+	"auth-service/pkg/dbwork"
+	"auth-service/pkg/service"
+	"common/proto/authpb"
+	"common/rpc"
+	"context"
+)
+
+type Server struct {
+	authpb.UnimplementedAuthServer
+	service *service.Service
+}
+
+func New(db *dbwork.DataBase, cfg service.Config) *Server {
+	return &Server{service: service.New(db, cfg)}
+}
+
+func (s *Server) Register(ctx context.Context, req *authpb.Credentials) (*authpb.User, error) {
+	id, resp := s.service.Register(ctx, req.GetLogin(), req.GetPassword())
+	if err := rpc.FromResponse(resp); err != nil {
+		return nil, err
+	}
+	return &authpb.User{Id: id.String()}, nil
+}
+
+func (s *Server) Login(ctx context.Context, req *authpb.Credentials) (*authpb.User, error) {
+	id, admin, resp := s.service.Login(ctx, req.GetLogin(), req.GetPassword())
+	if err := rpc.FromResponse(resp); err != nil {
+		return nil, err
+	}
+	return &authpb.User{Id: id.String(), Admin: admin}, nil
+}
@@ -0,0 +1,113 @@
+// Package service - регистрация и вход пользователей, общие для http
+// и gRPC API сервиса.
+package service
+
+import (
+	"auth-service/pkg/dbwork"
+	"common/audit"
+	"common/config"
+	"common/metrics"
+	"common/response"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const dbTimeout = 3 * time.Second
+
+type Config struct {
+	// LoginKey - ключ HMAC для логинов в журнале аудита
+	LoginKey []byte
+}
+
+// LoadConfig читает audit_login_key. Ключ обязателен: без него неудачные
+// входы с несуществующим логином нельзя записать в журнал.
+func LoadConfig() (Config, error) {
+	l := &config.Loader{}
+	cfg := Config{LoginKey: []byte(l.String("audit_login_key"))}
+	if err := l.Err(); err != nil {
+		return cfg, fmt.Errorf("service/LoadConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+type Service struct {
+	db  *dbwork.DataBase
+	cfg Config
+}
+
+func New(db *dbwork.DataBase, cfg Config) *Service {
+	return &Service{db: db, cfg: cfg}
+}
+
+// Register создаёт пользователя и возвращает его id.
+func (s *Service) Register(ctx context.Context, login, password string) (uuid.UUID, response.Response) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	resp := response.New(http.StatusCreated, "Пользователь успешно зарегистрирован")
+	id, err := s.db.CreateUser(ctx, login, password)
+	if err == dbwork.LoginBusy {
+		resp.Error(http.StatusConflict, response.CodeLoginTaken, err.Error())
+		return id, resp
+	}
+	if err != nil {
+		resp.InternalError()
+		log.Ctx(ctx).Error().Msgf("Ошибка создания нового пользователя: %v", err)
+		return id, resp
+	}
+	metrics.Event(metrics.EventRegistration)
+	audit.Record(audit.AsActor(ctx, id.String()), s.db, audit.ActionUserRegister, audit.Target("user", id), nil, nil)
+
+	return id, resp
+}
+
+// Login проверяет пароль и возвращает id пользователя и признак
+// администратора.
+func (s *Service) Login(ctx context.Context, login, password string) (uuid.UUID, bool, response.Response) {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+
+	resp := response.New(http.StatusOK, "Пользователь успешно вошёл")
+	id, admin, err := s.db.VerifyPassword(ctx, login, password)
+	if err == dbwork.LoginNotFound || err == dbwork.PasswordIsNotCorrect {
+		metrics.Event(metrics.EventLoginFailed)
+		// Журнал нельзя изменить, поэтому логин в нём не хранится: обезличивание
+		// аккаунта не смогло бы его удалить
+		target := audit.Target("user", id)
+		if err == dbwork.LoginNotFound {
+			target = LoginTarget(s.cfg.LoginKey, login)
+			resp.Error(http.StatusNotFound, response.CodeInvalidCredentials, err.Error())
+		} else {
+			resp.Error(http.StatusUnauthorized, response.CodeInvalidCredentials, err.Error())
+			log.Ctx(ctx).Warn().Msg("Пользователь ввёл неправильный пароль")
+		}
+		audit.Record(ctx, s.db, audit.ActionUserLoginFailed, target, nil, nil)
+		return uuid.Nil, false, resp
+	}
+	if err != nil {
+		resp.InternalError()
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки пароль: %v", err)
+		return id, false, resp
+	}
+	metrics.Event(metrics.EventLoginSucceeded)
+	audit.Record(audit.AsActor(ctx, id.String()), s.db, audit.ActionUserLogin, audit.Target("user", id), nil, nil)
+
+	return id, admin, resp
+}
+
+// LoginTarget собирает объект неудачного входа вида "login:<hmac>".
+// Попытки с одним логином можно найти в журнале, зная ключ, а сам
+// логин из записи не восстановить.
+func LoginTarget(key []byte, login string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(login))
+	return audit.Target("login", hex.EncodeToString(mac.Sum(nil)))
+}
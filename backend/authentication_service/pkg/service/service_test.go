@@ -0,0 +1,19 @@
+package service_test
+
+import (
+	"auth-service/pkg/service"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginTarget(t *testing.T) {
+	target := service.LoginTarget([]byte("key"), "user@example.com")
+
+	assert.True(t, strings.HasPrefix(target, "login:"))
+	assert.NotContains(t, target, "user@example.com")
+	assert.Equal(t, target, service.LoginTarget([]byte("key"), "user@example.com"))
+	assert.NotEqual(t, target, service.LoginTarget([]byte("other"), "user@example.com"))
+	assert.NotEqual(t, target, service.LoginTarget([]byte("key"), "user@example.org"))
+}
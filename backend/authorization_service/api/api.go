@@ -0,0 +1,9 @@
+// Package api содержит OpenAPI спецификацию authorization_service.
+package api
+
+import _ "embed"
+
+// Spec - OpenAPI 3 спецификация, которую сервис отдаёт по /openapi.json.
+//
+//go:embed openapi.json
+var Spec []byte
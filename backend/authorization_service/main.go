@@ -3,48 +3,81 @@ package main
 import (
 	"authoriz-service/pkg/dbwork"
 	"authoriz-service/pkg/handlers"
+	"authoriz-service/pkg/router"
+	"authoriz-service/pkg/rpcserver"
+	"common/config"
+	"common/health"
+	"common/logger"
+	"common/proto/authzpb"
+	"common/rpc"
+	"common/tracing"
 	"context"
-	"log"
+	"net/http"
 	"time"
-
-	"github.com/gin-contrib/cors"
-
-	"github.com/gin-gonic/gin"
 )
 
+// readyTimeout ограничивает каждую проверку готовности.
+const readyTimeout = 2 * time.Second
+
 func main() {
 
-	ctx := context.Background()
-	db, err := dbwork.NewPostgreSQL(ctx, dbwork.LoadPSQLConfig())
+	health.Probe()
 
+	log := logger.New("authoriz_service")
+
+	tracingCfg, err := tracing.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации трассировки: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), "authoriz_service", tracingCfg)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к бд: %v", err)
+		log.Fatal().Msgf("Ошибка настройки трассировки: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	dbCfg, err := config.LoadPostgreSQL()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации бд: %v", err)
+	}
+
+	tlsCfg, err := rpc.LoadTLS()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации gRPC: %v", err)
+	}
+
+	ctx := log.WithContext(context.Background())
+	db, err := dbwork.NewPostgreSQL(ctx, dbCfg)
+
+	if err != nil {
+		log.Fatal().Msgf("Ошибка подключения к бд: %v", err)
 	}
 
 	defer db.Close()
 
 	if err = db.RunMigrations(ctx, ""); err != nil {
-		log.Fatalf("Ошибка миграции бд: %v", err)
+		log.Fatal().Msgf("Ошибка миграции бд: %v", err)
 	}
 
-	handler := handlers.NewHandler(db)
+	grpcServer, err := rpc.NewServer(tlsCfg, log)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка создания gRPC сервера: %v", err)
+	}
+	authzpb.RegisterAuthzServer(grpcServer, rpcserver.New(db))
+	go func() {
+		grpcAddr := (&config.Loader{}).StringOr("grpc_addr", ":9083")
+		if err := rpc.ListenAndServe(grpcServer, grpcAddr); err != nil {
+			log.Fatal().Msgf("Ошибка работы gRPC сервера: %v", err)
+		}
+	}()
 
-	r := gin.Default()
+	handler := handlers.NewHandler(db)
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://manage_service:8080"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	// Сервис готов принимать запросы, пока доступна бд с актуальной схемой
+	checker := health.New(readyTimeout).Add("database", db.Ready)
 
-	r.POST("/authorization", handler.Authorization)
-	r.POST("/refresh", handler.Refresh)
-	r.POST("/logout", handler.Logout)
-	r.GET("/admin/:access", handler.Admin)
-	r.GET("/uuid/:access", handler.GetUUID)
+	r := router.New(handler, checker)
 
-	r.Run(":8083")
+	if err = http.ListenAndServe(":8083", tracing.Handler("authoriz_service", log, r)); err != nil {
+		log.Fatal().Msgf("Ошибка работы сервера: %v", err)
+	}
 }
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"authoriz-service/pkg/dbwork"
+	"authoriz-service/pkg/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeProductRead  = "product:read"
+	ScopeProductWrite = "product:write"
+	ScopeProductCount = "product:count"
+
+	apiKeyPrefix = "esk"
+)
+
+var Scopes = []string{ScopeProductRead, ScopeProductWrite, ScopeProductCount}
+
+var (
+	InvalidAPIKey     = errors.New("Неправильный API ключ")
+	APIKeyExpired     = errors.New("Срок действия API ключа истёк")
+	APIKeyRevoked     = errors.New("API ключ отозван")
+	IPIsNotAllowed    = errors.New("IP адрес не разрешён для данного API ключа")
+	UnknownScope      = errors.New("Неизвестное право доступа")
+	InvalidAllowedIPs = errors.New("Неправильный список разрешённых IP адресов")
+)
+
+// CreateAPIKey выпускает новый ключ и сохраняет в бд только его хеш.
+// Открытое значение ключа возвращается один раз и больше нигде не хранится.
+func CreateAPIKey(ctx context.Context, db *dbwork.DataBase, req models.RequestCreateAPIKey) (string, models.APIKey, error) {
+	key := models.APIKey{
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		CreatedBy:  req.CreatedBy,
+		CreatedAt:  time.Now(),
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+
+	for _, scope := range key.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", key, UnknownScope
+		}
+	}
+
+	for _, allowed := range key.AllowedIPs {
+		if _, _, err := net.ParseCIDR(allowed); err != nil && net.ParseIP(allowed) == nil {
+			return "", key, InvalidAllowedIPs
+		}
+	}
+
+	if req.ExpiresIn > 0 {
+		expires := key.CreatedAt.Add(time.Duration(req.ExpiresIn) * time.Hour)
+		key.ExpiresAt = &expires
+	}
+
+	for range 3 {
+		var prefix [4]byte
+		var secret [32]byte
+
+		if _, err := rand.Read(prefix[:]); err != nil {
+			return "", key, fmt.Errorf("auth/CreateAPIKey rand.Read prefix: %v", err)
+		}
+		if _, err := rand.Read(secret[:]); err != nil {
+			return "", key, fmt.Errorf("auth/CreateAPIKey rand.Read secret: %v", err)
+		}
+
+		key.Prefix = hex.EncodeToString(prefix[:])
+		strKey := apiKeyPrefix + "_" + key.Prefix + "_" + base64.RawURLEncoding.EncodeToString(secret[:])
+		key.KeyHash = hashAPIKey(strKey)
+
+		id, err := db.CreateAPIKey(ctx, key)
+		if err != nil {
+			if err == dbwork.DuplicateAPIKey {
+				continue
+			}
+			return "", key, fmt.Errorf("auth/CreateAPIKey CreateAPIKey: %v", err)
+		}
+		key.ID = id
+
+		return strKey, key, nil
+	}
+
+	return "", key, fmt.Errorf("Не удалось создать API ключ")
+}
+
+// CheckAPIKey проверяет ключ, срок его действия и IP клиента,
+// после чего отмечает время последнего использования.
+func CheckAPIKey(ctx context.Context, db *dbwork.DataBase, strKey, ip string) (models.APIKey, error) {
+	// В секрете base64url может встретиться "_", поэтому делится только
+	// по первым двум
+	parts := strings.SplitN(strKey, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return models.APIKey{}, InvalidAPIKey
+	}
+
+	key, err := db.GetAPIKeyByPrefix(ctx, parts[1])
+	if err != nil {
+		if err == dbwork.APIKeyNotFound {
+			return key, InvalidAPIKey
+		}
+		return key, fmt.Errorf("auth/CheckAPIKey GetAPIKeyByPrefix: %v", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashAPIKey(strKey))) != 1 {
+		return key, InvalidAPIKey
+	}
+
+	if key.RevokedAt != nil {
+		return key, APIKeyRevoked
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return key, APIKeyExpired
+	}
+
+	if !ipAllowed(key.AllowedIPs, ip) {
+		return key, IPIsNotAllowed
+	}
+
+	if err = db.TouchAPIKey(ctx, key.ID); err != nil {
+		return key, fmt.Errorf("auth/CheckAPIKey TouchAPIKey: %v", err)
+	}
+
+	return key, nil
+}
+
+// Ключи содержат 256 бит случайных данных, поэтому для хранения
+// достаточно sha256 и не нужен медленный bcrypt на каждый запрос.
+func hashAPIKey(strKey string) string {
+	sum := sha256.Sum256([]byte(strKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func ipAllowed(allowedIPs []string, ip string) bool {
+	if len(allowedIPs) == 0 {
+		return true
+	}
+
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+
+	for _, allowed := range allowedIPs {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(clientIP) {
+				return true
+			}
+			continue
+		}
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(clientIP) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -17,9 +18,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func init() {
-	log.Logger = log.With().Str("package", "auth").Logger()
-}
+// TokenExpired - подпись access токена верна, но срок его действия истёк.
+// Клиенту в этом случае нужно обновить токены, а не входить заново.
+var TokenExpired = errors.New("Срок действия access токена истёк")
 
 type claims struct {
 	GUID  string `json:"GUID"`
@@ -27,10 +28,10 @@ type claims struct {
 	jwt.RegisteredClaims
 }
 
-func CreateAccessToken(db *dbwork.DataBase, GUID string, admin bool) (string, error) {
+func CreateAccessToken(ctx context.Context, db *dbwork.DataBase, GUID string, admin bool) (string, error) {
 	expires, err := strconv.Atoi(os.Getenv("expires_jwt"))
 	if err != nil {
-		log.Error().Msgf("Ошибка expires_access: %v", err)
+		log.Ctx(ctx).Error().Msgf("Ошибка expires_access: %v", err)
 		expires = 1
 	}
 
@@ -45,7 +46,7 @@ func CreateAccessToken(db *dbwork.DataBase, GUID string, admin bool) (string, er
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 	err = db.EnableSession(ctx, GUID)
 	if err != nil {
@@ -64,6 +65,11 @@ func CheckAccessToken(access string) (string, bool, error) {
 			return []byte(os.Getenv("jwt_secret")), nil
 		},
 	)
+	// Подпись проверяется раньше срока действия, поэтому данным
+	// истёкшего токена можно доверять при обновлении токенов
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return claim.GUID, claim.Admin, TokenExpired
+	}
 	if err != nil || !token.Valid {
 		return uuid.Nil.String(), false, fmt.Errorf("auth/CheckAccessToken parse token: %v", err)
 	}
@@ -99,6 +105,6 @@ func CreateRefreshToken(ctx context.Context, db *dbwork.DataBase, GUID string) (
 
 		return strRefresh, nil
 	}
-	log.Error().Msg("Не удалось создать refresh токен")
+	log.Ctx(ctx).Error().Msg("Не удалось создать refresh токен")
 	return "", fmt.Errorf("Не удалось создать refresh token")
 }
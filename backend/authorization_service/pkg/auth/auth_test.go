@@ -3,17 +3,22 @@ package auth_test
 import (
 	"authoriz-service/pkg/auth"
 	"authoriz-service/pkg/dbwork"
+	"authoriz-service/pkg/models"
+	"common/config"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 )
 
@@ -29,11 +34,14 @@ func TestMain(t *testing.T) {
 	CreateAccessToken_Success(t, db)
 	CreateAccessToken_Invalid(t, db)
 	CreateRefreshToken_Success(t, db)
+	CreateCheckAPIKey_Success(t, db)
+	CreateCheckAPIKey_Many(t, db)
+	CheckAPIKey_Invalid(t, db)
 }
 
 func CreateAccessToken_Success(t *testing.T, db *dbwork.DataBase) {
 	GUID := uuid.NewString()
-	access, err := auth.CreateAccessToken(db, GUID, false)
+	access, err := auth.CreateAccessToken(context.Background(), db, GUID, false)
 	assert.NoError(t, err)
 
 	NewGUID, admin, err := auth.CheckAccessToken(access)
@@ -47,8 +55,20 @@ func CreateAccessToken_Invalid(t *testing.T, db *dbwork.DataBase) {
 
 	GUID, admin, err := auth.CheckAccessToken(uuid.NewString())
 	assert.Error(t, err)
+	assert.NotErrorIs(t, err, auth.TokenExpired)
 	assert.Equal(t, uuid.Nil.String(), GUID)
 	assert.Equal(t, false, admin)
+
+	expiredGUID := uuid.NewString()
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"GUID": expiredGUID,
+		"exp":  time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(os.Getenv("jwt_secret")))
+	assert.NoError(t, err)
+
+	GUID, _, err = auth.CheckAccessToken(expired)
+	assert.ErrorIs(t, err, auth.TokenExpired)
+	assert.Equal(t, expiredGUID, GUID)
 }
 
 func CreateRefreshToken_Success(t *testing.T, db *dbwork.DataBase) {
@@ -63,6 +83,82 @@ func CreateRefreshToken_Success(t *testing.T, db *dbwork.DataBase) {
 	assert.NoError(t, err)
 }
 
+func CreateCheckAPIKey_Success(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	key, apiKey, err := auth.CreateAPIKey(ctx, db, models.RequestCreateAPIKey{
+		Name:       "warehouse",
+		Scopes:     []string{auth.ScopeProductCount},
+		AllowedIPs: []string{"10.0.0.0/8", "192.168.1.10"},
+		ExpiresIn:  24,
+		CreatedBy:  uuid.NewString(),
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key)
+	assert.NotContains(t, apiKey.KeyHash, key)
+
+	checked, err := auth.CheckAPIKey(ctx, db, key, "10.1.2.3")
+	assert.NoError(t, err)
+	assert.Equal(t, apiKey.ID, checked.ID)
+	assert.Equal(t, []string{auth.ScopeProductCount}, checked.Scopes)
+
+	_, err = auth.CheckAPIKey(ctx, db, key, "192.168.1.10")
+	assert.NoError(t, err)
+
+	_, err = auth.CheckAPIKey(ctx, db, key, "172.16.0.1")
+	assert.Equal(t, auth.IPIsNotAllowed, err)
+
+	err = db.RevokeAPIKey(ctx, apiKey.ID)
+	assert.NoError(t, err)
+
+	_, err = auth.CheckAPIKey(ctx, db, key, "10.1.2.3")
+	assert.Equal(t, auth.APIKeyRevoked, err)
+}
+
+// CreateCheckAPIKey_Many проверяет, что принимается любой выданный ключ:
+// примерно в половине секретов встречается "_".
+func CreateCheckAPIKey_Many(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	underscores := 0
+	for range 300 {
+		key, apiKey, err := auth.CreateAPIKey(ctx, db, models.RequestCreateAPIKey{
+			Name:      "bulk",
+			Scopes:    []string{auth.ScopeProductRead},
+			CreatedBy: uuid.NewString(),
+		})
+		require.NoError(t, err)
+		if strings.Count(key, "_") > 2 {
+			underscores++
+		}
+
+		checked, err := auth.CheckAPIKey(ctx, db, key, "10.0.0.1")
+		require.NoError(t, err, key)
+		assert.Equal(t, apiKey.ID, checked.ID)
+	}
+	assert.NotZero(t, underscores)
+}
+
+func CheckAPIKey_Invalid(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, _, err := auth.CreateAPIKey(ctx, db, models.RequestCreateAPIKey{
+		Name:      "marketing",
+		Scopes:    []string{"product:everything"},
+		CreatedBy: uuid.NewString(),
+	})
+	assert.Equal(t, auth.UnknownScope, err)
+
+	_, err = auth.CheckAPIKey(ctx, db, "esk_00000000_secret", "")
+	assert.Equal(t, auth.InvalidAPIKey, err)
+
+	_, err = auth.CheckAPIKey(ctx, db, uuid.NewString(), "")
+	assert.Equal(t, auth.InvalidAPIKey, err)
+}
+
 func setupTestDB() (*dbwork.DataBase, func(), error) {
 	dbName := "testdb"
 	dbUser := "test"
@@ -90,7 +186,7 @@ func setupTestDB() (*dbwork.DataBase, func(), error) {
 		return nil, nil, err
 	}
 
-	db, err := dbwork.NewPostgreSQL(ctx, dbwork.PostgreSQLConfig{
+	db, err := dbwork.NewPostgreSQL(ctx, config.PostgreSQL{
 		User:     dbUser,
 		Password: dbPassword,
 		Host:     host,
@@ -1,6 +1,10 @@
 package dbwork
 
 import (
+	"authoriz-service/pkg/models"
+	"common/audit"
+	"common/config"
+	"common/database"
 	"context"
 	"errors"
 	"fmt"
@@ -11,9 +15,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,93 +24,46 @@ var (
 	SessionIsNotActive  = errors.New("Сессия не активна")
 	RefreshIsNotActive  = errors.New("Refresh токен неактивен")
 	InvalidRefreshToken = errors.New("Неправильный refredh токе")
+	DuplicateAPIKey     = errors.New("Данный API ключ уже существует")
+	APIKeyNotFound      = errors.New("API ключ не найден")
 )
 
-type PostgreSQLConfig struct {
-	User     string
-	Password string
-	Host     string
-	Port     int
-	DBName   string
-	SSLMode  string
-}
-
 type DataBase struct {
 	pool *pgxpool.Pool
+	log  *zerolog.Logger
+	// migrations - каталог миграций, по которому Ready сверяет версию схемы
+	migrations string
 }
 
-func LoadPSQLConfig() PostgreSQLConfig {
-	port, err := strconv.Atoi(os.Getenv("port"))
-	if err != nil {
-		log.Fatal().Msgf("LoadPSQLConfig getPort: %v", err)
-	}
-	return PostgreSQLConfig{
-		User:     os.Getenv("user"),
-		Password: os.Getenv("password"),
-		Host:     os.Getenv("host"),
-		Port:     port,
-		DBName:   os.Getenv("dbname"),
-		SSLMode:  os.Getenv("sslmode"),
-	}
-}
-
-func NewPostgreSQL(ctx context.Context, config PostgreSQLConfig) (*DataBase, error) {
-	log.Info().Msgf("Post cfg: %v", config)
-	connStr := fmt.Sprintf(
-		"postgres://%v:%v@%v:%v/%v?sslmode=%s",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-		config.SSLMode,
-	)
-
-	poolConfig, err := pgxpool.ParseConfig(connStr)
-	if err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL poolConfig: %v", err)
-	}
-
-	poolConfig.MaxConnIdleTime = time.Minute
-
-	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+func NewPostgreSQL(ctx context.Context, cfg config.PostgreSQL) (*DataBase, error) {
+	pool, err := database.Connect(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL newPool: %v", err)
+		return nil, fmt.Errorf("dbwork/NewPostgreSQL: %w", err)
 	}
 
-	if err := pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("dbwork/NewPostgreSQL ping: %v", err)
-	}
-	log.Info().Msg("Подключение к базе данных успешно!")
-
-	return &DataBase{pool: pool}, nil
+	return &DataBase{pool: pool, log: zerolog.Ctx(ctx)}, nil
 }
 
 func (db *DataBase) Close() {
 	if db.pool != nil {
 		db.pool.Close()
-		log.Info().Msg("Соеднинение с базой данных закрыто")
+		db.log.Info().Msg("Соеднинение с базой данных закрыто")
 	}
 }
 
 func (db *DataBase) RunMigrations(ctx context.Context, path string) error {
-	sqlDB := stdlib.OpenDBFromPool(db.pool)
-	defer sqlDB.Close()
-
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("Ошибка установки диалекта: %w", err)
-	}
-
 	if path == "" {
 		path = "pkg/dbwork/migrations"
 	}
+	db.migrations = path
 
-	if err := goose.Up(sqlDB, path); err != nil {
-		return fmt.Errorf("Ошибка применения миграций: %w", err)
-	}
+	return database.Migrate(ctx, db.pool, path)
+}
 
-	log.Info().Msg("Миграции успешно применены")
-	return nil
+// Ready проверяет, что бд доступна и на ней применены все миграции
+// сервиса.
+func (db *DataBase) Ready(ctx context.Context) error {
+	return database.Ready(ctx, db.pool, db.migrations)
 }
 
 func (db *DataBase) CheckCollisionRefresh(ctx context.Context, hashRefresh string) error {
@@ -253,3 +208,194 @@ func (db *DataBase) StopSession(ctx context.Context, GUID string) error {
 	return nil
 
 }
+
+func (db *DataBase) ListUserSessions(ctx context.Context, GUID string) ([]models.Session, []models.RefreshToken, error) {
+	selectSessionQuery := `SELECT id, COALESCE(active, FALSE)
+	                       FROM session
+	                       WHERE user_id=$1
+	                       ORDER BY id`
+
+	rows, err := db.pool.Query(ctx, selectSessionQuery, GUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dbwork/ListUserSessions session Query: %v", err)
+	}
+
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Session, error) {
+		session := models.Session{}
+		err := row.Scan(&session.ID, &session.Active)
+		return session, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("dbwork/ListUserSessions session Scan: %v", err)
+	}
+
+	selectRefreshQuery := `SELECT id, expires_at, COALESCE(worker, FALSE)
+	                       FROM refresh
+	                       WHERE user_id=$1
+	                       ORDER BY id`
+
+	rows, err = db.pool.Query(ctx, selectRefreshQuery, GUID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dbwork/ListUserSessions refresh Query: %v", err)
+	}
+
+	refreshTokens, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.RefreshToken, error) {
+		refresh := models.RefreshToken{}
+		err := row.Scan(&refresh.ID, &refresh.ExpiresAt, &refresh.Active)
+		return refresh, err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("dbwork/ListUserSessions refresh Scan: %v", err)
+	}
+
+	return sessions, refreshTokens, nil
+}
+
+// DeleteUserSessions удаляет все сессии и refresh токены пользователя.
+// Повторный вызов для уже удалённого пользователя не считается ошибкой.
+func (db *DataBase) DeleteUserSessions(ctx context.Context, GUID string) error {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("dbwork/DeleteUserSessions Begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `DELETE FROM refresh WHERE user_id=$1`, GUID); err != nil {
+		return fmt.Errorf("dbwork/DeleteUserSessions refresh Exec: %v", err)
+	}
+
+	if _, err = tx.Exec(ctx, `DELETE FROM session WHERE user_id=$1`, GUID); err != nil {
+		return fmt.Errorf("dbwork/DeleteUserSessions session Exec: %v", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("dbwork/DeleteUserSessions Commit: %v", err)
+	}
+
+	return nil
+}
+
+func (db *DataBase) CreateAPIKey(ctx context.Context, key models.APIKey) (int64, error) {
+	createQuery := `INSERT INTO api_key
+	                       (name, prefix, key_hash, scopes, allowed_ips, created_by, created_at, expires_at)
+	                       VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	                       RETURNING id`
+	var id int64
+
+	err := db.pool.QueryRow(ctx, createQuery,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		key.Scopes,
+		key.AllowedIPs,
+		key.CreatedBy,
+		key.CreatedAt,
+		key.ExpiresAt,
+	).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value") {
+			return 0, DuplicateAPIKey
+		}
+		return 0, fmt.Errorf("dbwork/CreateAPIKey QueryRow: %v", err)
+	}
+
+	return id, nil
+}
+
+func (db *DataBase) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	selectQuery := `SELECT id, name, prefix, key_hash, scopes, allowed_ips, created_by,
+	                       created_at, expires_at, last_used_at, revoked_at
+	                FROM api_key
+	                ORDER BY id`
+
+	rows, err := db.pool.Query(ctx, selectQuery)
+	if err != nil {
+		return nil, fmt.Errorf("dbwork/ListAPIKeys Query: %v", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key := models.APIKey{}
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("dbwork/ListAPIKeys Scan: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbwork/ListAPIKeys rows: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (db *DataBase) GetAPIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	selectQuery := `SELECT id, name, prefix, key_hash, scopes, allowed_ips, created_by,
+	                       created_at, expires_at, last_used_at, revoked_at
+	                FROM api_key
+	                WHERE prefix=$1`
+	key := models.APIKey{}
+
+	if err := scanAPIKey(db.pool.QueryRow(ctx, selectQuery, prefix), &key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return key, APIKeyNotFound
+		}
+		return key, fmt.Errorf("dbwork/GetAPIKeyByPrefix QueryRow: %v", err)
+	}
+
+	return key, nil
+}
+
+func (db *DataBase) RevokeAPIKey(ctx context.Context, id int64) error {
+	updateQuery := `UPDATE api_key
+	                SET revoked_at=$1
+	                WHERE id=$2 AND revoked_at IS NULL`
+
+	result, err := db.pool.Exec(ctx, updateQuery, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("dbwork/RevokeAPIKey Exec: %v", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return APIKeyNotFound
+	}
+
+	return nil
+}
+
+func (db *DataBase) TouchAPIKey(ctx context.Context, id int64) error {
+	updateQuery := `UPDATE api_key
+	                SET last_used_at=$1
+	                WHERE id=$2`
+
+	if _, err := db.pool.Exec(ctx, updateQuery, time.Now(), id); err != nil {
+		return fmt.Errorf("dbwork/TouchAPIKey Exec: %v", err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.AllowedIPs,
+		&key.CreatedBy,
+		&key.CreatedAt,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+}
+
+func (db *DataBase) RecordAudit(ctx context.Context, entry audit.Entry) error {
+	return audit.Insert(ctx, db.pool, entry)
+}
+
+func (db *DataBase) ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return audit.List(ctx, db.pool, filter)
+}
@@ -2,6 +2,8 @@ package dbwork_test
 
 import (
 	"authoriz-service/pkg/dbwork"
+	"authoriz-service/pkg/models"
+	"common/config"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
@@ -30,6 +32,8 @@ func TestMain(t *testing.T) {
 	StopSession_Success(t, db)
 	CreateRefresh_Success(t, db)
 	CheckCollisionRefresh(t, db)
+	CreateRevokeAPIKey_Success(t, db)
+	ListDeleteUserSessions_Success(t, db)
 }
 
 func EnableCheckSession_Success(t *testing.T, db *dbwork.DataBase) {
@@ -117,6 +121,82 @@ func CheckCollisionRefresh(t *testing.T, db *dbwork.DataBase) {
 	assert.Error(t, err)
 }
 
+func CreateRevokeAPIKey_Success(t *testing.T, db *dbwork.DataBase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	key := models.APIKey{
+		Name:       "warehouse",
+		Prefix:     "a1b2c3d4",
+		KeyHash:    "hash",
+		Scopes:     []string{"product:count"},
+		AllowedIPs: []string{"10.0.0.0/8"},
+		CreatedBy:  uuid.NewString(),
+		CreatedAt:  time.Now(),
+	}
+
+	id, err := db.CreateAPIKey(ctx, key)
+	assert.NoError(t, err)
+
+	_, err = db.CreateAPIKey(ctx, key)
+	assert.Equal(t, dbwork.DuplicateAPIKey, err)
+
+	dbKey, err := db.GetAPIKeyByPrefix(ctx, key.Prefix)
+	assert.NoError(t, err)
+	assert.Equal(t, id, dbKey.ID)
+	assert.Equal(t, key.Scopes, dbKey.Scopes)
+	assert.Equal(t, key.AllowedIPs, dbKey.AllowedIPs)
+	assert.Nil(t, dbKey.LastUsedAt)
+
+	err = db.TouchAPIKey(ctx, id)
+	assert.NoError(t, err)
+
+	err = db.RevokeAPIKey(ctx, id)
+	assert.NoError(t, err)
+
+	err = db.RevokeAPIKey(ctx, id)
+	assert.Equal(t, dbwork.APIKeyNotFound, err)
+
+	keys, err := db.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.NotNil(t, keys[0].RevokedAt)
+}
+
+func ListDeleteUserSessions_Success(t *testing.T, db *dbwork.DataBase) {
+	GUID := uuid.NewString()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := db.EnableSession(ctx, GUID)
+	assert.NoError(t, err)
+
+	err = db.CreateRefresh(ctx, "hash-"+GUID, GUID)
+	assert.NoError(t, err)
+
+	sessions, refreshTokens, err := db.ListUserSessions(ctx, GUID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Active)
+	assert.Len(t, refreshTokens, 1)
+	assert.True(t, refreshTokens[0].Active)
+
+	err = db.DeleteUserSessions(ctx, GUID)
+	assert.NoError(t, err)
+
+	err = db.DeleteUserSessions(ctx, GUID)
+	assert.NoError(t, err)
+
+	sessions, refreshTokens, err = db.ListUserSessions(ctx, GUID)
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+	assert.Empty(t, refreshTokens)
+
+	err = db.CheckActiveSession(ctx, GUID)
+	assert.Equal(t, dbwork.SessionIsNotActive, err)
+}
+
 func setupTestDB() (*dbwork.DataBase, func(), error) {
 	dbName := "testdb"
 	dbUser := "test"
@@ -144,7 +224,7 @@ func setupTestDB() (*dbwork.DataBase, func(), error) {
 		return nil, nil, err
 	}
 
-	db, err := dbwork.NewPostgreSQL(ctx, dbwork.PostgreSQLConfig{
+	db, err := dbwork.NewPostgreSQL(ctx, config.PostgreSQL{
 		User:     dbUser,
 		Password: dbPassword,
 		Host:     host,
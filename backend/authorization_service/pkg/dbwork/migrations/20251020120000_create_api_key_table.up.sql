@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE api_key(
+  id BIGSERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  prefix VARCHAR(16) NOT NULL UNIQUE,
+  key_hash VARCHAR(64) NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+  created_by UUID NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_key;
+-- +goose StatementEnd
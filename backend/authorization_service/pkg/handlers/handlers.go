@@ -4,48 +4,51 @@ import (
 	"authoriz-service/pkg/auth"
 	"authoriz-service/pkg/dbwork"
 	"authoriz-service/pkg/models"
+	"common/audit"
+	"common/metrics"
+	"common/response"
+	"common/response/ginresponse"
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-func init() {
-	log.Logger = log.With().Str("package", "handlers").Logger()
-}
-
 type Handler struct {
-	db *dbwork.DataBase
+	db    *dbwork.DataBase
+	audit http.Handler
 }
 
 func NewHandler(db *dbwork.DataBase) *Handler {
-	return &Handler{db: db}
+	return &Handler{db: db, audit: audit.Handler("authoriz_service", db.ListAudit)}
 }
 
 func (handler *Handler) Authorization(c *gin.Context) {
 	req := models.Request{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
 		return
 	}
 
-	access, err := auth.CreateAccessToken(handler.db, req.ID, req.Admin)
+	access, err := auth.CreateAccessToken(c.Request.Context(), handler.db, req.ID, req.Admin)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка создания access: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания access: %v", err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
 	refresh, err := auth.CreateRefreshToken(ctx, handler.db, req.ID)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка создания refresh: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания refresh: %v", err)
 		return
 	}
 
@@ -56,52 +59,64 @@ func (handler *Handler) Refresh(c *gin.Context) {
 	tokens := models.Tokens{}
 
 	if err := c.ShouldBindJSON(&tokens); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка получения токенов из запроса: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения токенов из запроса: %v", err)
 		return
 	}
 
+	// Обновить можно и истёкший access токен, если его подпись верна
 	GUID, admin, err := auth.CheckAccessToken(tokens.Access)
-	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки access: %v", err)
+	if err != nil && err != auth.TokenExpired {
+		sendTokenError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки access: %v", err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 4*time.Second)
 	defer cancel()
 
 	if err = handler.db.CheckActiveSession(ctx, GUID); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки активности сессии: %v", err)
+		sendSessionError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки активности сессии: %v", err)
 		return
 	}
 
-	if err = handler.db.CheckRefreshToken(ctx, GUID, tokens.Refresh); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки refresh: %v", err)
+	err = handler.db.CheckRefreshToken(ctx, GUID, tokens.Refresh)
+	// Токен не совпадает с текущим: скорее всего, предъявлен уже
+	// использованный refresh токен
+	if err == dbwork.InvalidRefreshToken {
+		metrics.Event(metrics.EventTokenReuseDetected)
+	}
+	if err == dbwork.RefreshIsNotActive || err == dbwork.InvalidRefreshToken {
+		ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeTokenInvalid, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки refresh: %v", err)
 		return
 	}
 
 	if err = handler.db.StopWorkerRefresh(ctx, GUID); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка остановки refresh: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка остановки refresh: %v", err)
 		return
 	}
 
-	tokens.Access, err = auth.CreateAccessToken(handler.db, GUID, admin)
+	tokens.Access, err = auth.CreateAccessToken(c.Request.Context(), handler.db, GUID, admin)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка создания access: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания access: %v", err)
 		return
 	}
 
 	tokens.Refresh, err = auth.CreateRefreshToken(ctx, handler.db, GUID)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка создания refresh: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания refresh: %v", err)
 		return
 	}
+	metrics.Event(metrics.EventTokenRefreshed)
 
 	models.SendTokens(c, tokens.Access, tokens.Refresh)
 }
@@ -109,25 +124,25 @@ func (handler *Handler) Refresh(c *gin.Context) {
 func (handler *Handler) Logout(c *gin.Context) {
 	tokens := models.Tokens{}
 	if err := c.ShouldBindJSON(&tokens); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка чтения json: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка чтения json: %v", err)
 		return
 	}
 
 	GUID, _, err := auth.CheckAccessToken(tokens.Access)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки access: %v", err)
+		sendTokenError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки access: %v", err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
 	err = handler.db.StopSession(ctx, GUID)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка остановки сессии: %v", err)
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка остановки сессии: %v", err)
 		return
 	}
 
@@ -139,17 +154,17 @@ func (handler *Handler) Admin(c *gin.Context) {
 
 	GUID, admin, err := auth.CheckAccessToken(access)
 	if err != nil {
-		models.SendResponse(c, http.StatusUnauthorized, "Ошибка проверки токена")
-		log.Error().Msgf("Ошибка проверки access: %v", err)
+		sendTokenError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки access: %v", err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
 	if err = handler.db.CheckActiveSession(ctx, GUID); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки сессии: %v", err)
+		sendSessionError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки сессии: %v", err)
 		return
 	}
 
@@ -158,8 +173,7 @@ func (handler *Handler) Admin(c *gin.Context) {
 		return
 	}
 
-	models.SendResponse(c, http.StatusNotFound, "Уровень администратора не подтвержден")
-
+	ginresponse.SendProblem(c, http.StatusForbidden, response.CodeNotAdmin, "Уровень администратора не подтвержден")
 }
 
 func (handler *Handler) GetUUID(c *gin.Context) {
@@ -167,18 +181,180 @@ func (handler *Handler) GetUUID(c *gin.Context) {
 
 	GUID, admin, err := auth.CheckAccessToken(access)
 	if err != nil {
-		models.SendResponse(c, http.StatusUnauthorized, "Ошибка проверки токена")
-		log.Error().Msgf("Ошибка проверки access: %v", err)
+		sendTokenError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки access: %v", err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
 	defer cancel()
 
 	if err = handler.db.CheckActiveSession(ctx, GUID); err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка проверки сессии: %v", err)
+		sendSessionError(c, err)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки сессии: %v", err)
 		return
 	}
 	models.SendResponseGetUUID(c, GUID, admin)
 }
+
+func (handler *Handler) ListUserSessions(c *gin.Context) {
+	GUID := c.Param("id")
+	if _, err := uuid.Parse(GUID); err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	sessions, refreshTokens, err := handler.db.ListUserSessions(ctx, GUID)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения сессий пользователя: %v", err)
+		return
+	}
+
+	models.SendSessions(c, sessions, refreshTokens)
+}
+
+func (handler *Handler) DeleteUserSessions(c *gin.Context) {
+	GUID := c.Param("id")
+	if _, err := uuid.Parse(GUID); err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	if err := handler.db.DeleteUserSessions(ctx, GUID); err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка удаления сессий пользователя: %v", err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Сессии пользователя удалены")
+}
+
+func (handler *Handler) CreateAPIKey(c *gin.Context) {
+	req := models.RequestCreateAPIKey{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	if req.Name == "" || req.CreatedBy == "" || len(req.Scopes) == 0 {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	key, apiKey, err := auth.CreateAPIKey(ctx, handler.db, req)
+	if err == auth.UnknownScope || err == auth.InvalidAllowedIPs {
+		ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка создания API ключа: %v", err)
+		return
+	}
+	// Сам ключ в журнал не попадает, только его префикс и права
+	audit.Record(ctx, handler.db, audit.ActionAPIKeyCreate, audit.Target("apikey", apiKey.ID), nil, apiKey)
+
+	models.SendAPIKey(c, http.StatusCreated, "API ключ успешно создан", key, apiKey)
+}
+
+func (handler *Handler) ListAPIKeys(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	apiKeys, err := handler.db.ListAPIKeys(ctx)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка получения списка API ключей: %v", err)
+		return
+	}
+
+	models.SendAPIKeys(c, apiKeys)
+}
+
+func (handler *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	err = handler.db.RevokeAPIKey(ctx, id)
+	if err == dbwork.APIKeyNotFound {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeAPIKeyNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка отзыва API ключа: %v", err)
+		return
+	}
+	audit.Record(ctx, handler.db, audit.ActionAPIKeyRevoke, audit.Target("apikey", id), nil, nil)
+
+	models.SendResponse(c, http.StatusOK, "API ключ успешно отозван")
+}
+
+func (handler *Handler) CheckAPIKey(c *gin.Context) {
+	req := models.RequestCheckAPIKey{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	apiKey, err := auth.CheckAPIKey(ctx, handler.db, req.Key, req.IP)
+	switch err {
+	case nil:
+	case auth.InvalidAPIKey, auth.APIKeyExpired, auth.APIKeyRevoked:
+		ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeAPIKeyInvalid, err.Error())
+		return
+	case auth.IPIsNotAllowed:
+		ginresponse.SendProblem(c, http.StatusForbidden, response.CodeAPIKeyForbidden, err.Error())
+		return
+	default:
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка проверки API ключа: %v", err)
+		return
+	}
+
+	models.SendAPIKey(c, http.StatusOK, "API ключ действителен", "", apiKey)
+}
+
+// sendTokenError отличает истёкший access токен от неправильного,
+// чтобы клиент знал, когда достаточно обновить токены.
+func sendTokenError(c *gin.Context, err error) {
+	if err == auth.TokenExpired {
+		ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeTokenExpired, err.Error())
+		return
+	}
+	ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeTokenInvalid, "Ошибка проверки токена")
+}
+
+func sendSessionError(c *gin.Context, err error) {
+	if err == dbwork.SessionIsNotActive {
+		ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeSessionInactive, err.Error())
+		return
+	}
+	ginresponse.SendInternalServerError(c)
+}
+
+// Audit отдаёт журнал аудита сервиса для manage_service.
+func (handler *Handler) Audit(c *gin.Context) {
+	handler.audit.ServeHTTP(c.Writer, c.Request)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"common/response"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,10 +12,8 @@ type Request struct {
 	Admin bool   `json:"admin"`
 }
 
-type Response struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+// Response - общий для сервисов конверт ответа
+type Response = response.Response
 
 type Tokens struct {
 	Access  string `json:"access"`
@@ -37,6 +36,30 @@ type ResponseUUIDAdmin struct {
 	Admin bool   `json:"admin"`
 }
 
+type RequestCreateAPIKey struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	AllowedIPs []string `json:"allowed_ips"`
+	ExpiresIn  int      `json:"expires_in"`
+	CreatedBy  string   `json:"created_by"`
+}
+
+type RequestCheckAPIKey struct {
+	Key string `json:"key"`
+	IP  string `json:"ip"`
+}
+
+type ResponseAPIKey struct {
+	Response
+	Key    string `json:"key,omitempty"`
+	APIKey APIKey `json:"api_key"`
+}
+
+type ResponseAPIKeys struct {
+	Response
+	APIKeys []APIKey `json:"api_keys"`
+}
+
 func SendResponseGetUUID(c *gin.Context, id string, admin bool) {
 	c.JSON(http.StatusOK, ResponseUUIDAdmin{
 		Response: Response{
@@ -78,16 +101,40 @@ func SendTokens(c *gin.Context, access, refresh string) {
 	})
 }
 
-func SendBadRequest(c *gin.Context) {
-	c.JSON(http.StatusBadRequest, Response{
-		Code:    http.StatusBadRequest,
-		Message: "Ошибка запроса, невозможно прочитать данные",
+type ResponseSessions struct {
+	Response
+	Sessions      []Session      `json:"sessions"`
+	RefreshTokens []RefreshToken `json:"refresh_tokens"`
+}
+
+func SendSessions(c *gin.Context, sessions []Session, refreshTokens []RefreshToken) {
+	c.JSON(http.StatusOK, ResponseSessions{
+		Response: Response{
+			Code:    http.StatusOK,
+			Message: "Сессии пользователя получены",
+		},
+		Sessions:      sessions,
+		RefreshTokens: refreshTokens,
+	})
+}
+
+func SendAPIKey(c *gin.Context, code int, message, key string, apiKey APIKey) {
+	c.JSON(code, ResponseAPIKey{
+		Response: Response{
+			Code:    code,
+			Message: message,
+		},
+		Key:    key,
+		APIKey: apiKey,
 	})
 }
 
-func SendInternalServerError(c *gin.Context) {
-	c.JSON(http.StatusInternalServerError, Response{
-		Code:    http.StatusInternalServerError,
-		Message: "Неизвестная ошибка сервера",
+func SendAPIKeys(c *gin.Context, apiKeys []APIKey) {
+	c.JSON(http.StatusOK, ResponseAPIKeys{
+		Response: Response{
+			Code:    http.StatusOK,
+			Message: "Список API ключей получен",
+		},
+		APIKeys: apiKeys,
 	})
 }
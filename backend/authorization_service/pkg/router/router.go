@@ -0,0 +1,55 @@
+// Package router собирает http маршруты сервиса.
+package router
+
+import (
+	"authoriz-service/api"
+	"authoriz-service/pkg/handlers"
+	"common/audit"
+	"common/health"
+	"common/metrics"
+	"common/metrics/ginmetrics"
+	"common/openapi"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// New возвращает gin с маршрутами всех обработчиков и спецификацией API.
+// /readyz выполняет проверки checker.
+func New(handler *handlers.Handler, checker *health.Checker) *gin.Engine {
+	// Запросы пишет в лог tracing.Handler вместе с идентификатором запроса
+	r := gin.New()
+	r.Use(gin.Recovery(), ginmetrics.Middleware)
+
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://manage_service:8080"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	r.GET(openapi.Path, gin.WrapF(openapi.Handler(api.Spec)))
+	r.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+	r.GET(health.LivePath, gin.WrapH(health.Live()))
+	r.GET(health.ReadyPath, gin.WrapH(checker.Ready()))
+	r.GET(audit.Path, handler.Audit)
+
+	r.POST("/authorization", handler.Authorization)
+	r.POST("/refresh", handler.Refresh)
+	r.POST("/logout", handler.Logout)
+	r.GET("/admin/:access", handler.Admin)
+	r.GET("/uuid/:access", handler.GetUUID)
+
+	r.GET("/user/:id/sessions", handler.ListUserSessions)
+	r.DELETE("/user/:id", handler.DeleteUserSessions)
+
+	r.POST("/apikey", handler.CreateAPIKey)
+	r.GET("/apikey", handler.ListAPIKeys)
+	r.DELETE("/apikey/:id", handler.RevokeAPIKey)
+	r.POST("/apikey/check", handler.CheckAPIKey)
+
+	return r
+}
@@ -0,0 +1,274 @@
+package router_test
+
+import (
+	"authoriz-service/api"
+	"authoriz-service/pkg/dbwork"
+	"authoriz-service/pkg/handlers"
+	"authoriz-service/pkg/router"
+	"common/audit"
+	"common/config"
+	"common/health"
+	"common/openapi/contract"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+type client struct {
+	t         *testing.T
+	handler   http.Handler
+	validator *contract.Validator
+}
+
+func newClient(t *testing.T, db *dbwork.DataBase) *client {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("expires_jwt", "2")
+	t.Setenv("jwt_secret", "MiNeLoshadi")
+	t.Setenv("expires_refresh", "10")
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	checker := health.New(time.Second).Add("database", db.Ready)
+	return &client{t: t, handler: router.New(handlers.NewHandler(db), checker), validator: validator}
+}
+
+// do выполняет запрос и проверяет ответ по спецификации.
+func (c *client) do(method, path string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	data, ok := body.(string)
+	if !ok {
+		encoded, err := json.Marshal(body)
+		require.NoError(c.t, err)
+		data = string(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec, err := c.validator.Do(c.handler, req)
+	require.NoError(c.t, err)
+	return rec
+}
+
+func (c *client) decode(rec *httptest.ResponseRecorder, v any) {
+	c.t.Helper()
+	require.NoError(c.t, json.Unmarshal(rec.Body.Bytes(), v))
+}
+
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	problem := struct {
+		Code string `json:"code"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	return problem.Code
+}
+
+// Ошибки разбора запроса и проверки подписи токена возвращаются до обращения к бд
+func TestContractWithoutDB(t *testing.T) {
+	c := newClient(t, nil)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", "/openapi.json", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("GET", "/healthz", "").Code)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{"POST", "/authorization", "{"},
+		{"POST", "/refresh", "["},
+		{"POST", "/logout", "{"},
+		{"GET", "/user/abc/sessions", ""},
+		{"DELETE", "/user/abc", ""},
+		{"POST", "/apikey", `{"name":"shop"}`},
+		{"DELETE", "/apikey/abc", ""},
+		{"POST", "/apikey/check", "{"},
+		{"GET", "/audit?from=yesterday", ""},
+	}
+	for _, tc := range cases {
+		rec := c.do(tc.method, tc.path, tc.body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "%s %s", tc.method, tc.path)
+	}
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"GUID": uuid.NewString(),
+		"exp":  time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte("MiNeLoshadi"))
+	require.NoError(t, err)
+
+	for _, path := range []string{"/admin/", "/uuid/"} {
+		rec := c.do("GET", path+"invalid", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "TOKEN_INVALID", problemCode(t, rec))
+
+		rec = c.do("GET", path+expired, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "TOKEN_EXPIRED", problemCode(t, rec))
+	}
+
+	rec := c.do("POST", "/logout", map[string]string{"access": expired})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "TOKEN_EXPIRED", problemCode(t, rec))
+
+	rec = c.do("POST", "/refresh", map[string]string{"access": "invalid", "refresh": "invalid"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "TOKEN_INVALID", problemCode(t, rec))
+}
+
+func TestContract(t *testing.T) {
+	db, clean, err := setupTestDB()
+	require.NoError(t, err)
+	defer clean()
+
+	c := newClient(t, db)
+	GUID := uuid.NewString()
+
+	rec := c.do("GET", "/readyz", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"database":{"status":"ok"`)
+
+	rec = c.do("POST", "/authorization", map[string]any{"id": GUID, "admin": false})
+	require.Equal(t, http.StatusOK, rec.Code)
+	tokens := struct {
+		Access  string `json:"access"`
+		Refresh string `json:"refresh"`
+	}{}
+	c.decode(rec, &tokens)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", "/uuid/"+tokens.Access, "").Code)
+	rec = c.do("GET", "/admin/"+tokens.Access, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "NOT_ADMIN", problemCode(t, rec))
+
+	rec = c.do("POST", "/refresh", map[string]string{"access": tokens.Access, "refresh": "wrong"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "TOKEN_INVALID", problemCode(t, rec))
+
+	rec = c.do("POST", "/refresh", tokens)
+	require.Equal(t, http.StatusOK, rec.Code)
+	c.decode(rec, &tokens)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", "/user/"+GUID+"/sessions", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("POST", "/logout", tokens).Code)
+
+	rec = c.do("GET", "/uuid/"+tokens.Access, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "SESSION_INACTIVE", problemCode(t, rec))
+
+	assert.Equal(t, http.StatusOK, c.do("DELETE", "/user/"+GUID, "").Code)
+
+	// API ключи
+	rec = c.do("POST", "/apikey", map[string]any{"name": "shop", "scopes": []string{"product:fly"}, "created_by": GUID})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "VALIDATION_FAILED", problemCode(t, rec))
+
+	rec = c.do("POST", "/apikey", map[string]any{
+		"name":        "shop",
+		"scopes":      []string{"product:read"},
+		"allowed_ips": []string{"10.0.0.0/8"},
+		"created_by":  GUID,
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	created := struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID int64 `json:"id"`
+		} `json:"api_key"`
+	}{}
+	c.decode(rec, &created)
+
+	assert.Equal(t, http.StatusOK, c.do("GET", "/apikey", "").Code)
+	assert.Equal(t, http.StatusOK, c.do("POST", "/apikey/check", map[string]string{"key": created.Key, "ip": "10.1.2.3"}).Code)
+
+	rec = c.do("POST", "/apikey/check", map[string]string{"key": created.Key, "ip": "192.168.0.1"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "API_KEY_FORBIDDEN", problemCode(t, rec))
+
+	keyPath := "/apikey/" + strconv.FormatInt(created.APIKey.ID, 10)
+	assert.Equal(t, http.StatusOK, c.do("DELETE", keyPath, "").Code)
+
+	rec = c.do("POST", "/apikey/check", map[string]string{"key": created.Key, "ip": "10.1.2.3"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "API_KEY_INVALID", problemCode(t, rec))
+
+	rec = c.do("DELETE", "/apikey/999999", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "API_KEY_NOT_FOUND", problemCode(t, rec))
+
+	rec = c.do("GET", "/audit?target=apikey:"+strconv.FormatInt(created.APIKey.ID, 10), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	log := audit.Result{}
+	c.decode(rec, &log)
+	require.Len(t, log.Entries, 2)
+	assert.Equal(t, audit.ActionAPIKeyRevoke, log.Entries[0].Action)
+	assert.Equal(t, audit.ActionAPIKeyCreate, log.Entries[1].Action)
+	assert.Contains(t, string(log.Entries[1].After), `"scopes":["product:read"]`)
+	assert.NotContains(t, string(log.Entries[1].After), created.Key)
+}
+
+func setupTestDB() (*dbwork.DataBase, func(), error) {
+	dbName := "testdb"
+	dbUser := "test"
+	dbPassword := "pass"
+	ctx := context.Background()
+	pgContainer, err := postgres.Run(
+		ctx,
+		"postgres:15-alpine",
+		postgres.WithDatabase(dbName),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host, err := pgContainer.Host(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port, err := pgContainer.MappedPort(ctx, "5432")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := dbwork.NewPostgreSQL(ctx, config.PostgreSQL{
+		User:     dbUser,
+		Password: dbPassword,
+		Host:     host,
+		Port:     port.Int(),
+		DBName:   dbName,
+		SSLMode:  "disable",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, filename, _, _ := runtime.Caller(0)
+	migrationsPath := filepath.Join(filepath.Dir(filename), "..", "dbwork", "migrations")
+	if err = db.RunMigrations(ctx, migrationsPath); err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		db.Close()
+		_ = pgContainer.Terminate(ctx)
+	}
+
+	return db, cleanup, nil
+}
@@ -0,0 +1,146 @@
+// Package rpcserver - внутренний gRPC API сервиса для manage_service.
+// Работает рядом с http сервером и с той же бд.
+package rpcserver
+
+import (
+	"authoriz-service/pkg/auth"
+	"authoriz-service/pkg/dbwork"
+	"common/metrics"
+	"common/proto/authzpb"
+	"common/response"
+	"common/rpc"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Server struct {
+	authzpb.UnimplementedAuthzServer
+	db *dbwork.DataBase
+}
+
+func New(db *dbwork.DataBase) *Server {
+	return &Server{db: db}
+}
+
+func (s *Server) IssueTokens(ctx context.Context, req *authzpb.IssueTokensRequest) (*authzpb.Tokens, error) {
+	access, err := auth.CreateAccessToken(ctx, s.db, req.GetUserId(), req.GetAdmin())
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка создания access: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	refresh, err := auth.CreateRefreshToken(ctx, s.db, req.GetUserId())
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка создания refresh: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+
+	return &authzpb.Tokens{Access: access, Refresh: refresh}, nil
+}
+
+func (s *Server) Refresh(ctx context.Context, req *authzpb.Tokens) (*authzpb.Tokens, error) {
+	// Обновить можно и истёкший access токен, если его подпись верна
+	GUID, admin, err := auth.CheckAccessToken(req.GetAccess())
+	if err != nil && err != auth.TokenExpired {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки access: %v", err)
+		return nil, tokenError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 4*time.Second)
+	defer cancel()
+
+	if err = s.db.CheckActiveSession(ctx, GUID); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки активности сессии: %v", err)
+		return nil, sessionError(err)
+	}
+
+	err = s.db.CheckRefreshToken(ctx, GUID, req.GetRefresh())
+	// Токен не совпадает с текущим: скорее всего, предъявлен уже
+	// использованный refresh токен
+	if err == dbwork.InvalidRefreshToken {
+		metrics.Event(metrics.EventTokenReuseDetected)
+	}
+	if err == dbwork.RefreshIsNotActive || err == dbwork.InvalidRefreshToken {
+		return nil, rpc.Errorf(http.StatusUnauthorized, response.CodeTokenInvalid, err.Error())
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки refresh: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+
+	if err = s.db.StopWorkerRefresh(ctx, GUID); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка остановки refresh: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+
+	tokens := &authzpb.Tokens{}
+	if tokens.Access, err = auth.CreateAccessToken(ctx, s.db, GUID, admin); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка создания access: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+	if tokens.Refresh, err = auth.CreateRefreshToken(ctx, s.db, GUID); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка создания refresh: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+	metrics.Event(metrics.EventTokenRefreshed)
+
+	return tokens, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *authzpb.LogoutRequest) (*authzpb.LogoutResponse, error) {
+	GUID, _, err := auth.CheckAccessToken(req.GetAccess())
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки access: %v", err)
+		return nil, tokenError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err = s.db.StopSession(ctx, GUID); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка остановки сессии: %v", err)
+		return nil, rpc.Error(response.InternalServerError())
+	}
+
+	return &authzpb.LogoutResponse{}, nil
+}
+
+func (s *Server) Introspect(ctx context.Context, req *authzpb.IntrospectRequest) (*authzpb.Principal, error) {
+	GUID, admin, err := auth.CheckAccessToken(req.GetAccess())
+	if err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки access: %v", err)
+		return nil, tokenError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err = s.db.CheckActiveSession(ctx, GUID); err != nil {
+		log.Ctx(ctx).Error().Msgf("Ошибка проверки сессии: %v", err)
+		return nil, sessionError(err)
+	}
+
+	return &authzpb.Principal{UserId: GUID, Admin: admin}, nil
+}
+
+// tokenError отличает истёкший access токен от неправильного,
+// чтобы клиент знал, когда достаточно обновить токены.
+func tokenError(err error) error {
+	if err == auth.TokenExpired {
+		return rpc.Errorf(http.StatusUnauthorized, response.CodeTokenExpired, err.Error())
+	}
+	return rpc.Errorf(http.StatusUnauthorized, response.CodeTokenInvalid, "Ошибка проверки токена")
+}
+
+func sessionError(err error) error {
+	if err == dbwork.SessionIsNotActive {
+		return rpc.Errorf(http.StatusUnauthorized, response.CodeSessionInactive, err.Error())
+	}
+	return rpc.Error(response.InternalServerError())
+}
@@ -0,0 +1,53 @@
+package rpcserver_test
+
+import (
+	"authoriz-service/pkg/rpcserver"
+	"common/proto/authzpb"
+	"common/response"
+	"common/rpc"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func problem(t *testing.T, err error) response.Problem {
+	t.Helper()
+	problem, ok := rpc.Problem(err)
+	require.True(t, ok, "ожидалась ошибка gRPC, получено %v", err)
+	return problem
+}
+
+// Проверки токенов до обращения к бд
+func TestTokensWithoutDB(t *testing.T) {
+	t.Setenv("jwt_secret", "MiNeLoshadi")
+	server := rpcserver.New(nil)
+	ctx := context.Background()
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"GUID": uuid.NewString(),
+		"exp":  time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte("MiNeLoshadi"))
+	require.NoError(t, err)
+
+	_, err = server.Introspect(ctx, &authzpb.IntrospectRequest{Access: "invalid"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, response.CodeTokenInvalid, problem(t, err).Code)
+
+	_, err = server.Introspect(ctx, &authzpb.IntrospectRequest{Access: expired})
+	assert.Equal(t, response.CodeTokenExpired, problem(t, err).Code)
+	assert.Equal(t, http.StatusUnauthorized, problem(t, err).Status)
+
+	_, err = server.Logout(ctx, &authzpb.LogoutRequest{Access: expired})
+	assert.Equal(t, response.CodeTokenExpired, problem(t, err).Code)
+
+	_, err = server.Refresh(ctx, &authzpb.Tokens{Access: "invalid", Refresh: "invalid"})
+	assert.Equal(t, response.CodeTokenInvalid, problem(t, err).Code)
+}
@@ -0,0 +1,165 @@
+// Package audit - журнал действий пользователей и администраторов:
+// кто, когда и с какого адреса изменил товар, вошёл в систему или выдал
+// API ключ. Каждый сервис пишет журнал в таблицу audit_log своей бд,
+// строки которой нельзя изменить или удалить. manage_service собирает
+// журналы сервисов в /admin/audit.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"common/tracing"
+)
+
+// Действия, которые попадают в журнал.
+const (
+	ActionProductCreate        = "product.create"
+	ActionProductUpdate        = "product.update"
+	ActionProductDelete        = "product.delete"
+	ActionProductStock         = "product.stock"
+	ActionProductImagesConfirm = "product.images.confirm"
+	ActionProductImagesOrder   = "product.images.order"
+	ActionProductImagePrimary  = "product.images.primary"
+	ActionProductImageAlt      = "product.images.alt"
+
+	ActionUserRegister        = "user.register"
+	ActionUserLogin           = "user.login"
+	ActionUserLoginFailed     = "user.login_failed"
+	ActionUserRoleGrant       = "user.role_grant"
+	ActionUserPasswordChange  = "user.password_change"
+	ActionUserDeletionRequest = "user.deletion_request"
+	ActionUserDeletionCancel  = "user.deletion_cancel"
+	ActionUserDelete          = "user.delete"
+
+	ActionAPIKeyCreate = "apikey.create"
+	ActionAPIKeyRevoke = "apikey.revoke"
+)
+
+// recordTimeout ограничивает запись в журнал. Запись не зависит от отмены
+// запроса: действие уже выполнено и должно остаться в журнале.
+const recordTimeout = 3 * time.Second
+
+// Entry - запись журнала. Before и After содержат только поля, которые
+// изменило действие.
+type Entry struct {
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+	// Service - сервис, в журнале которого хранится запись
+	Service string `json:"service,omitempty"`
+	// Actor - GUID пользователя, "apikey:<id>" или пустая строка для
+	// фоновых задач сервиса
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	RequestID string          `json:"request_id"`
+}
+
+// Store сохраняет записи журнала в бд сервиса.
+type Store interface {
+	RecordAudit(ctx context.Context, entry Entry) error
+}
+
+// Target собирает объект действия вида "product:12".
+func Target(kind string, id any) string {
+	return fmt.Sprintf("%s:%v", kind, id)
+}
+
+// AsActor подменяет GUID в Actor из ctx. Нужен для входа и регистрации,
+// когда пользователь становится известен только в ходе запроса.
+func AsActor(ctx context.Context, id string) context.Context {
+	actor := tracing.ActorFrom(ctx)
+	actor.ID = id
+	return tracing.WithActor(ctx, actor)
+}
+
+// Record записывает в store действие action над target. Исполнитель,
+// его адрес и идентификатор запроса берутся из ctx. before и after -
+// состояние объекта до и после действия, любое из них может быть nil.
+// Действие к этому моменту уже выполнено, поэтому ошибка записи его не
+// отменяет, а пишется в лог.
+func Record(ctx context.Context, store Store, action, target string, before, after any) {
+	entry, err := newEntry(ctx, action, target, before, after)
+	if err == nil {
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+		defer cancel()
+		err = store.RecordAudit(recordCtx, entry)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Str("action", action).Str("target", target).Msg("Ошибка записи в журнал аудита")
+	}
+}
+
+func newEntry(ctx context.Context, action, target string, before, after any) (Entry, error) {
+	actor := tracing.ActorFrom(ctx)
+	entry := Entry{
+		Time:      time.Now().UTC(),
+		Actor:     actor.ID,
+		Action:    action,
+		Target:    target,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		RequestID: tracing.RequestID(ctx),
+	}
+
+	var err error
+	entry.Before, entry.After, err = Diff(before, after)
+	if err != nil {
+		return entry, fmt.Errorf("audit/Record %s: %w", action, err)
+	}
+	return entry, nil
+}
+
+// Diff переводит before и after в json и, если оба - объекты, оставляет
+// в них только поля, значения которых различаются. nil остаётся пустым.
+func Diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	beforeJSON, err := marshal(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := marshal(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var beforeFields, afterFields map[string]json.RawMessage
+	if json.Unmarshal(beforeJSON, &beforeFields) != nil || json.Unmarshal(afterJSON, &afterFields) != nil ||
+		beforeFields == nil || afterFields == nil {
+		return beforeJSON, afterJSON, nil
+	}
+
+	for field, value := range beforeFields {
+		if changed, ok := afterFields[field]; ok && bytes.Equal(value, changed) {
+			delete(beforeFields, field)
+			delete(afterFields, field)
+		}
+	}
+
+	if beforeJSON, err = json.Marshal(beforeFields); err != nil {
+		return nil, nil, err
+	}
+	if afterJSON, err = json.Marshal(afterFields); err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+func marshal(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("audit/marshal: %w", err)
+	}
+	return data, nil
+}
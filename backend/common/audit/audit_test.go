@@ -0,0 +1,112 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"common/audit"
+	"common/tracing"
+)
+
+type store []audit.Entry
+
+func (s *store) RecordAudit(ctx context.Context, entry audit.Entry) error {
+	*s = append(*s, entry)
+	return nil
+}
+
+type product struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestRecord(t *testing.T) {
+	ctx := tracing.WithRequestID(context.Background(), "req-1")
+	ctx = tracing.WithActor(ctx, tracing.Actor{ID: "admin-guid", IP: "10.0.0.1", UserAgent: "curl/8.0"})
+	// Запрос уже отменён, но действие выполнено и попадает в журнал
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	entries := store{}
+	audit.Record(ctx, &entries, audit.ActionProductStock, audit.Target("product", 12), product{"Телефон", 5}, product{"Телефон", 4})
+	audit.Record(audit.AsActor(ctx, "user-guid"), &entries, audit.ActionUserLogin, audit.Target("user", "user-guid"), nil, nil)
+
+	require.Len(t, entries, 2)
+	stock := entries[0]
+	assert.Equal(t, "admin-guid", stock.Actor)
+	assert.Equal(t, "product:12", stock.Target)
+	assert.Equal(t, "10.0.0.1", stock.IP)
+	assert.Equal(t, "curl/8.0", stock.UserAgent)
+	assert.Equal(t, "req-1", stock.RequestID)
+	assert.WithinDuration(t, time.Now(), stock.Time, time.Minute)
+	// В журнал попадают только изменённые поля
+	assert.JSONEq(t, `{"count":5}`, string(stock.Before))
+	assert.JSONEq(t, `{"count":4}`, string(stock.After))
+
+	login := entries[1]
+	assert.Equal(t, "user-guid", login.Actor)
+	assert.Equal(t, "10.0.0.1", login.IP)
+	assert.Nil(t, login.Before)
+	assert.Nil(t, login.After)
+}
+
+func TestParseFilter(t *testing.T) {
+	query := url.Values{
+		"actor":  {"admin-guid"},
+		"action": {"product.images"},
+		"from":   {"2026-10-01T00:00:00Z"},
+		"limit":  {"50"},
+	}
+	filter, err := audit.ParseFilter(query)
+	require.NoError(t, err)
+	assert.Equal(t, audit.Filter{
+		Actor:  "admin-guid",
+		Action: "product.images",
+		From:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Limit:  50,
+	}, filter)
+
+	// Фильтр без изменений доходит до сервисов через manage_service
+	again, err := audit.ParseFilter(filter.Values())
+	require.NoError(t, err)
+	assert.Equal(t, filter, again)
+
+	for _, bad := range []url.Values{
+		{"action": {"product%"}},
+		{"from": {"вчера"}},
+		{"limit": {"0"}},
+		{"limit": {"100000"}},
+	} {
+		_, err = audit.ParseFilter(bad)
+		assert.ErrorIs(t, err, audit.ErrInvalidFilter, bad.Encode())
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, audit.WriteCSV(&out, []audit.Entry{{
+		Time:      time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Service:   "auth_service",
+		Action:    audit.ActionUserLoginFailed,
+		Target:    "login:=HYPERLINK(\"http://evil\")",
+		UserAgent: "Mozilla/5.0",
+	}}))
+
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "time", records[0][0])
+	assert.Equal(t, []string{"2026-10-19T12:00:00Z", "auth_service", "", "user.login_failed",
+		"login:=HYPERLINK(\"http://evil\")", "", "", "", "Mozilla/5.0", ""}, records[1])
+
+	out.Reset()
+	require.NoError(t, audit.WriteCSV(&out, []audit.Entry{{Actor: "=1+1"}}))
+	assert.Contains(t, out.String(), "'=1+1")
+}
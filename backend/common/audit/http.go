@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"common/response"
+)
+
+// Path - внутренний маршрут сервисов, по которому manage_service читает
+// их журналы.
+const Path = "/audit"
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+var ErrInvalidFilter = errors.New("Неправильный фильтр журнала: actor, action и target - строки, from и to - время в RFC 3339, limit от 1 до 1000")
+
+// actionPattern - действие или его группа, например "product" или
+// "product.images".
+var actionPattern = regexp.MustCompile(`^[a-z_]+(\.[a-z_]+)*$`)
+
+// Filter отбирает записи журнала. Пустые поля не ограничивают выборку.
+// Action выбирает и само действие, и вложенные, "product" выбирает все
+// действия с товарами. From включается в выборку, To - нет.
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultLimit
+	}
+	return f.Limit
+}
+
+// ParseFilter читает Filter из параметров запроса.
+func ParseFilter(values url.Values) (Filter, error) {
+	filter := Filter{
+		Actor:  values.Get("actor"),
+		Action: values.Get("action"),
+		Target: values.Get("target"),
+	}
+	if filter.Action != "" && !actionPattern.MatchString(filter.Action) {
+		return filter, ErrInvalidFilter
+	}
+
+	var err error
+	for name, field := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := values.Get(name); value != "" {
+			if *field, err = time.Parse(time.RFC3339, value); err != nil {
+				return filter, ErrInvalidFilter
+			}
+		}
+	}
+
+	if value := values.Get("limit"); value != "" {
+		filter.Limit, err = strconv.Atoi(value)
+		if err != nil || filter.Limit < 1 || filter.Limit > MaxLimit {
+			return filter, ErrInvalidFilter
+		}
+	}
+	return filter, nil
+}
+
+// Values - обратное к ParseFilter преобразование для запроса к сервису.
+func (f Filter) Values() url.Values {
+	values := url.Values{}
+	for name, value := range map[string]string{"actor": f.Actor, "action": f.Action, "target": f.Target} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	if !f.From.IsZero() {
+		values.Set("from", f.From.Format(time.RFC3339Nano))
+	}
+	if !f.To.IsZero() {
+		values.Set("to", f.To.Format(time.RFC3339Nano))
+	}
+	values.Set("limit", strconv.Itoa(f.limit()))
+	return values
+}
+
+// Result - ответ Path.
+type Result struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Handler отдаёт записи журнала сервиса service, подходящие под фильтр
+// из параметров запроса. list читает их из бд сервиса.
+func Handler(service string, list func(ctx context.Context, filter Filter) ([]Entry, error)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		filter, err := ParseFilter(r.URL.Query())
+		if err != nil {
+			response.WriteProblem(rw, response.NewProblem(http.StatusBadRequest, response.CodeValidation, err.Error()))
+			return
+		}
+
+		entries, err := list(r.Context(), filter)
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Ошибка чтения журнала аудита")
+			response.WriteProblem(rw, response.InternalServerError())
+			return
+		}
+		for i := range entries {
+			entries[i].Service = service
+		}
+
+		response.WriteJSON(rw, http.StatusOK, Result{Entries: entries})
+	})
+}
+
+// csvHeader - столбцы выгрузки WriteCSV.
+var csvHeader = []string{"time", "service", "actor", "action", "target", "before", "after", "ip", "user_agent", "request_id"}
+
+// WriteCSV выгружает entries в CSV для просмотра в табличном редакторе.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.Time.UTC().Format(time.RFC3339Nano),
+			entry.Service,
+			entry.Actor,
+			entry.Action,
+			entry.Target,
+			string(entry.Before),
+			string(entry.After),
+			entry.IP,
+			entry.UserAgent,
+			entry.RequestID,
+		}
+		for i := range record {
+			record[i] = csvCell(record[i])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell не даёт табличному редактору выполнить значение как формулу:
+// User-Agent и логин задаёт клиент.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
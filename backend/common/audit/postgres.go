@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Insert добавляет entry в таблицу audit_log. Таблицу создают миграции
+// сервиса, триггеры на ней запрещают UPDATE, DELETE и TRUNCATE.
+func Insert(ctx context.Context, pool *pgxpool.Pool, entry Entry) error {
+	insertQuery := `INSERT INTO audit_log
+	                (created_at, actor, action, target, before_state, after_state, ip, user_agent, request_id)
+	                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := pool.Exec(ctx, insertQuery, entry.Time, entry.Actor, entry.Action, entry.Target,
+		nullJSON(entry.Before), nullJSON(entry.After), entry.IP, entry.UserAgent, entry.RequestID)
+	if err != nil {
+		return fmt.Errorf("audit/Insert: %w", err)
+	}
+	return nil
+}
+
+// List возвращает записи audit_log, подходящие под filter, новые первыми.
+func List(ctx context.Context, pool *pgxpool.Pool, filter Filter) ([]Entry, error) {
+	conditions := make([]string, 0, 5)
+	args := make([]any, 0, 6)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.Actor != "" {
+		where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		// "product" выбирает все действия с товарами, "_" в имени действия
+		// не должен работать как шаблон LIKE
+		where(`(action = ? OR action LIKE replace(?, '_', '\_') || '.%')`, filter.Action)
+	}
+	if filter.Target != "" {
+		where("target = ?", filter.Target)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < ?", filter.To)
+	}
+
+	selectQuery := `SELECT id, created_at, actor, action, target, before_state, after_state, ip, user_agent, request_id
+	                FROM audit_log`
+	if len(conditions) > 0 {
+		selectQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.limit())
+	selectQuery += " ORDER BY created_at DESC, id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	entries := make([]Entry, 0)
+	rows, err := pool.Query(ctx, selectQuery, args...)
+	if err != nil {
+		return entries, fmt.Errorf("audit/List query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := Entry{}
+		var before, after []byte
+		if err = rows.Scan(&entry.ID, &entry.Time, &entry.Actor, &entry.Action, &entry.Target,
+			&before, &after, &entry.IP, &entry.UserAgent, &entry.RequestID); err != nil {
+			return entries, fmt.Errorf("audit/List scan: %w", err)
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullJSON превращает пустое состояние в NULL.
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
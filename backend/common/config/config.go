@@ -0,0 +1,105 @@
+// Package config читает настройки сервисов из переменных окружения.
+// Loader копит ошибки всех переменных, чтобы сервис при запуске сообщил
+// обо всех неправильных настройках сразу, а не по одной.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Loader struct {
+	errs []error
+}
+
+// String возвращает обязательную переменную.
+func (l *Loader) String(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		l.errs = append(l.errs, fmt.Errorf("переменная %s не задана", key))
+	}
+	return value
+}
+
+func (l *Loader) StringOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// ListOr возвращает список значений переменной через запятую без
+// пробелов и пустых элементов.
+func (l *Loader) ListOr(key string, fallback ...string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// OneOf возвращает значение переменной, если оно входит в allowed.
+func (l *Loader) OneOf(key, fallback string, allowed ...string) string {
+	value := l.StringOr(key, fallback)
+	if !slices.Contains(allowed, value) {
+		l.errs = append(l.errs, fmt.Errorf("переменная %s=%q, допустимо: %v", key, value, allowed))
+	}
+	return value
+}
+
+// Int возвращает обязательную целую переменную из диапазона [min, max].
+func (l *Loader) Int(key string, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		l.errs = append(l.errs, fmt.Errorf("переменная %s не задана", key))
+		return 0
+	}
+	return l.parseInt(key, value, min, max)
+}
+
+func (l *Loader) IntOr(key string, fallback, min, max int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return l.parseInt(key, value, min, max)
+}
+
+// DurationOr читает целое число единиц unit, например дней или минут,
+// как это принято в config.env.
+func (l *Loader) DurationOr(key string, unit, fallback time.Duration, min int) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return time.Duration(l.parseInt(key, value, min, int(^uint(0)>>1))) * unit
+}
+
+func (l *Loader) parseInt(key, value string, min, max int) int {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("переменная %s=%q не число", key, value))
+		return 0
+	}
+	if number < min || number > max {
+		l.errs = append(l.errs, fmt.Errorf("переменная %s=%d вне диапазона [%d, %d]", key, number, min, max))
+	}
+	return number
+}
+
+// Err возвращает все накопленные ошибки или nil.
+func (l *Loader) Err() error {
+	return errors.Join(l.errs...)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+type PostgreSQL struct {
+	User     string
+	Password string
+	Host     string
+	Port     int
+	DBName   string
+	SSLMode  string
+	// StatementTimeout ограничивает время одного запроса на стороне
+	// сервера бд, 0 - без ограничения
+	StatementTimeout time.Duration
+}
+
+// LoadPostgreSQL читает настройки подключения из переменных user, password,
+// host, port, dbname и sslmode.
+func LoadPostgreSQL() (PostgreSQL, error) {
+	l := &Loader{}
+	cfg := PostgreSQL{
+		User:             l.String("user"),
+		Password:         l.String("password"),
+		Host:             l.String("host"),
+		Port:             l.Int("port", 1, 65535),
+		DBName:           l.String("dbname"),
+		SSLMode:          l.OneOf("sslmode", "disable", "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		StatementTimeout: l.DurationOr("statement_timeout_seconds", time.Second, 10*time.Second, 0),
+	}
+	if err := l.Err(); err != nil {
+		return cfg, fmt.Errorf("LoadPostgreSQL: %w", err)
+	}
+	return cfg, nil
+}
+
+func (cfg PostgreSQL) ConnString() string {
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Path:     cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	return connURL.String()
+}
+
+// String нужен для логов, поэтому пароль не выводится.
+func (cfg PostgreSQL) String() string {
+	return fmt.Sprintf("postgres://%s@%s:%d/%s?sslmode=%s", cfg.User, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode)
+}
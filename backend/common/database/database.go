@@ -0,0 +1,96 @@
+// Package database подключает сервисы к PostgreSQL через pgx и
+// применяет миграции goose.
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/rs/zerolog"
+
+	"common/config"
+	"common/metrics"
+	"common/tracing"
+)
+
+// Connect создаёт пул соединений и проверяет, что бд доступна. Запросы
+// пула попадают в трассировку, состояние пула - в метрики. Лог пишется
+// в логгер из ctx.
+func Connect(ctx context.Context, cfg config.PostgreSQL) (*pgxpool.Pool, error) {
+	zerolog.Ctx(ctx).Info().Stringer("database", cfg).Msg("Подключение к базе данных")
+
+	poolConfig, err := pgxpool.ParseConfig(cfg.ConnString())
+	if err != nil {
+		return nil, fmt.Errorf("database/Connect poolConfig: %w", err)
+	}
+
+	poolConfig.MaxConnIdleTime = time.Minute
+	poolConfig.ConnConfig.Tracer = tracing.QueryTracer{}
+	if cfg.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("database/Connect newPool: %w", err)
+	}
+
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("database/Connect ping: %w", err)
+	}
+	zerolog.Ctx(ctx).Info().Msg("Подключение к базе данных успешно!")
+	metrics.WatchPool(pool)
+
+	return pool, nil
+}
+
+// Migrate применяет миграции goose из каталога dir.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, dir string) error {
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	defer sqlDB.Close()
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("Ошибка установки диалекта: %w", err)
+	}
+
+	if err := goose.UpContext(ctx, sqlDB, dir); err != nil {
+		return fmt.Errorf("Ошибка применения миграций: %w", err)
+	}
+
+	zerolog.Ctx(ctx).Info().Msg("Миграции успешно применены")
+	return nil
+}
+
+// Ready проверяет, что бд отвечает и на ней применены все миграции из
+// каталога dir. Экземпляр с новыми миграциями не готов, пока их не
+// применит, иначе его запросы упадут на старой схеме.
+func Ready(ctx context.Context, pool *pgxpool.Pool, dir string) error {
+	if err := pool.Ping(ctx); err != nil {
+		return fmt.Errorf("database/Ready ping: %w", err)
+	}
+
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	defer sqlDB.Close()
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, sqlDB, os.DirFS(dir))
+	if err != nil {
+		return fmt.Errorf("database/Ready миграции: %w", err)
+	}
+	current, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("database/Ready версия: %w", err)
+	}
+
+	sources := provider.ListSources()
+	if want := sources[len(sources)-1].Version; current < want {
+		return fmt.Errorf("database/Ready: применена миграция %d, сервису нужна %d", current, want)
+	}
+	return nil
+}
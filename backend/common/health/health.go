@@ -0,0 +1,128 @@
+// Package health - проверки живости и готовности сервисов. /healthz
+// отвечает, пока процесс работает, и нужен только для перезапуска
+// зависшего процесса. /readyz проверяет зависимости сервиса: бд,
+// хранилище, другие сервисы, и отвечает 503, если хотя бы одна из них
+// недоступна, чтобы запросы не направлялись в неготовый экземпляр.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	LivePath  = "/healthz"
+	ReadyPath = "/readyz"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверяет одну зависимость и возвращает ошибку, если она
+// недоступна.
+type Check func(ctx context.Context) error
+
+// Result - итог одной проверки.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report - ответ /readyz: общий статус и итог каждой проверки.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker выполняет проверки готовности сервиса.
+type Checker struct {
+	timeout time.Duration
+	checks  map[string]Check
+}
+
+// New создаёт Checker, в котором каждая проверка ограничена timeout.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Add добавляет проверку name. Вызывается до запуска сервера.
+func (c *Checker) Add(name string, check Check) *Checker {
+	c.checks[name] = check
+	return c
+}
+
+// Run выполняет все проверки одновременно.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Live отвечает 200, пока процесс обрабатывает запросы.
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	})
+}
+
+// Ready отвечает 200, если все проверки прошли, иначе 503. В обоих
+// случаях в теле Report.
+func (c *Checker) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,57 @@
+package health_test
+
+import (
+	"common/health"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ready(t *testing.T, checker *health.Checker) (int, health.Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	checker.Ready().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, health.ReadyPath, nil))
+
+	report := health.Report{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+
+	status, report := ready(t, health.New(time.Second).Add("database", ok).Add("storage", ok))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusOK, report.Status)
+	assert.Len(t, report.Checks, 2)
+
+	// Зависшая проверка ограничена таймаутом и не держит остальные
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	failed := func(context.Context) error { return errors.New("нет соединения") }
+
+	status, report = ready(t, health.New(50*time.Millisecond).Add("database", ok).Add("storage", slow).Add("core", failed))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	assert.Equal(t, health.StatusFail, report.Checks["storage"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["storage"].Error)
+	assert.Equal(t, "нет соединения", report.Checks["core"].Error)
+}
+
+func TestLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	health.Live().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, health.LivePath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ProbeArg - аргумент, с которым бинарник сервиса проверяет сам себя.
+// Образы собраны без curl, поэтому healthcheck в docker compose
+// запускает, например, /app/auth probe http://localhost:8081/readyz.
+const ProbeArg = "probe"
+
+// probeTimeout ограничивает запрос probe.
+const probeTimeout = 5 * time.Second
+
+// Probe ничего не делает, если процесс запущен без аргументов probe URL.
+// Иначе запрашивает URL и завершает процесс с кодом 0, если он ответил
+// 200, и с кодом 1 в остальных случаях. Вызывается первым в main.
+func Probe() {
+	if len(os.Args) != 3 || os.Args[1] != ProbeArg {
+		return
+	}
+
+	client := http.Client{Timeout: probeTimeout}
+	resp, err := client.Get(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "%s: статус %d\n", os.Args[2], resp.StatusCode)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,40 @@
+// Package logger создаёт общий для всех сервисов zerolog.
+package logger
+
+import (
+	stdlog "log"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// New создаёт логгер сервиса: уровень берётся из log_level (по умолчанию
+// info), формат из log_format (json или console). К каждой записи
+// добавляется имя сервиса, секреты в записях скрываются, см. Redact.
+// Логгер не становится глобальным: main передаёт его в контексте и
+// конструкторам, а обработчики берут логгер запроса через zerolog.Ctx.
+// Стандартный log, которым пишут сторонние библиотеки, тоже пишет через
+// него, чтобы в выводе сервиса был один формат.
+func New(service string) zerolog.Logger {
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+
+	level, err := zerolog.ParseLevel(os.Getenv("log_level"))
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+
+	out := Redact(os.Stderr)
+	if os.Getenv("log_format") == "console" {
+		out = Redact(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+	}
+	logger := zerolog.New(out).Level(level).With().Timestamp().Str("service", service).Logger()
+
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(logger)
+
+	if err != nil {
+		logger.Warn().Msgf("Неизвестный log_level %q, используется info", os.Getenv("log_level"))
+	}
+	return logger
+}
@@ -0,0 +1,20 @@
+// Package ginmetrics - metrics.Middleware для сервисов на gin. Вынесен
+// отдельно, чтобы сервисы на gorilla/mux не зависели от gin.
+package ginmetrics
+
+import (
+	"common/metrics"
+	"common/tracing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware называет спан запроса по шаблону маршрута и учитывает
+// запрос в метриках.
+func Middleware(c *gin.Context) {
+	start := time.Now()
+	tracing.SetRoute(c.Request, c.FullPath())
+	c.Next()
+	metrics.ObserveRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+}
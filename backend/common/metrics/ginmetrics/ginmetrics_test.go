@@ -0,0 +1,30 @@
+package ginmetrics_test
+
+import (
+	"common/metrics"
+	"common/metrics/ginmetrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ginmetrics.Middleware)
+	r.DELETE("/product/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/product/7", "/product/8", "/.env"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="DELETE",route="/product/:id",status="204"} 2`)
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="DELETE",route="unmatched",status="404"} 1`)
+}
@@ -0,0 +1,133 @@
+// Package metrics - метрики Prometheus, общие для всех сервисов. Имена
+// и метки задаются здесь, чтобы запросы к gin и gorilla/mux сервисам,
+// вызовы gRPC и события магазина читались одинаково на всех панелях.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path - адрес, по которому каждый сервис отдаёт метрики.
+const Path = "/metrics"
+
+// unmatched - значение метки route для запросов, не попавших ни в один
+// маршрут. Сам путь в метку не пишется, иначе сканеры адресов создадут
+// по ряду на каждый путь.
+const unmatched = "unmatched"
+
+// События магазина для store_events_total.
+const (
+	EventRegistration       = "registration"
+	EventLoginSucceeded     = "login_succeeded"
+	EventLoginFailed        = "login_failed"
+	EventProductCreated     = "product_created"
+	EventStockChanged       = "stock_changed"
+	EventTokenRefreshed     = "token_refreshed"
+	EventTokenReuseDetected = "token_reuse_detected"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Число обработанных http запросов.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Время обработки http запросов.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	rpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_requests_total",
+		Help: "Число обработанных gRPC вызовов.",
+	}, []string{"method", "status"})
+
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rpc_request_duration_seconds",
+		Help:    "Время обработки gRPC вызовов.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	downstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "downstream_request_duration_seconds",
+		Help:    "Время запросов к другим сервисам вместе с повторами.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target", "method", "status"})
+
+	events = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "store_events_total",
+		Help: "События магазина: регистрации, входы, изменения каталога и токенов.",
+	}, []string{"event"})
+)
+
+// Handler отдаёт метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest учитывает http запрос. route - шаблон маршрута, например
+// /product/:id или /product/{id}, пустой для запросов без маршрута.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = unmatched
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveDownstream учитывает запрос к сервису target. method - http
+// метод или полное имя gRPC метода, status - код ответа или причина
+// ошибки.
+func ObserveDownstream(target, method, status string, duration time.Duration) {
+	downstreamDuration.WithLabelValues(target, method, status).Observe(duration.Seconds())
+}
+
+// Event учитывает событие магазина, одно из Event*.
+func Event(event string) {
+	events.WithLabelValues(event).Inc()
+}
+
+// Middleware - middleware для gorilla/mux: учитывает запросы с шаблоном
+// маршрута, который возвращает route.
+func Middleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+			next.ServeHTTP(recorder, r)
+			ObserveRequest(r.Method, route(r), recorder.status, time.Since(start))
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,94 @@
+package metrics_test
+
+import (
+	"common/metrics"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scrape возвращает строки метрик с префиксом prefix.
+func scrape(t *testing.T, prefix string) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := metrics.Middleware(func(r *http.Request) string {
+		if r.URL.Path == "/product/7" {
+			return "/product/{id}"
+		}
+		return ""
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/product/7" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+
+	for _, path := range []string{"/product/7", "/product/7", "/.env"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	lines := scrape(t, "http_requests_total")
+	assert.Contains(t, lines, `http_requests_total{method="GET",route="/product/{id}",status="200"} 2`)
+	assert.Contains(t, lines, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, scrape(t, "http_request_duration_seconds_count"),
+		`http_request_duration_seconds_count{method="GET",route="/product/{id}",status="200"} 2`)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/Login"}
+	call := func(err error) {
+		metrics.UnaryServerInterceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+			return nil, err
+		})
+	}
+	call(nil)
+	call(status.Error(codes.NotFound, "нет пользователя"))
+
+	lines := scrape(t, "rpc_requests_total")
+	assert.Contains(t, lines, `rpc_requests_total{method="/auth.Auth/Login",status="OK"} 1`)
+	assert.Contains(t, lines, `rpc_requests_total{method="/auth.Auth/Login",status="NotFound"} 1`)
+}
+
+func TestEventsAndDownstream(t *testing.T) {
+	// Событий, которых сервис не создаёт, в его метриках нет
+	assert.Empty(t, scrape(t, `store_events_total{event="product_created"}`))
+
+	metrics.Event(metrics.EventLoginFailed)
+	metrics.Event(metrics.EventLoginFailed)
+	metrics.ObserveDownstream("auth", "/auth.Auth/Login", "OK", 20*time.Millisecond)
+
+	assert.Equal(t, []string{`store_events_total{event="login_failed"} 2`}, scrape(t, "store_events_total"))
+	assert.Contains(t, scrape(t, "downstream_request_duration_seconds_count"),
+		`downstream_request_duration_seconds_count{method="/auth.Auth/Login",status="OK",target="auth"} 1`)
+}
+
+func TestPoolWithoutDatabase(t *testing.T) {
+	// Пока пул не подключён, метрик пула нет
+	assert.Empty(t, scrape(t, "db_pool_"))
+}
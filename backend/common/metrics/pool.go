@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pool - пул соединений сервиса, состояние которого отдаёт poolCollector.
+var pool atomic.Pointer[pgxpool.Pool]
+
+func init() {
+	prometheus.MustRegister(poolCollector{})
+}
+
+// WatchPool отдаёт в метриках состояние пула p вместо предыдущего.
+func WatchPool(p *pgxpool.Pool) {
+	pool.Store(p)
+}
+
+var (
+	poolConns = prometheus.NewDesc("db_pool_connections",
+		"Соединения пула по состоянию: acquired, idle, constructing.", []string{"state"}, nil)
+	poolMaxConns = prometheus.NewDesc("db_pool_max_connections",
+		"Максимальный размер пула.", nil, nil)
+	poolAcquires = prometheus.NewDesc("db_pool_acquires_total",
+		"Число выданных соединений.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("db_pool_empty_acquires_total",
+		"Число выдач, которым пришлось ждать соединение или создавать новое.", nil, nil)
+	poolCanceledAcquires = prometheus.NewDesc("db_pool_canceled_acquires_total",
+		"Число выдач, отменённых до получения соединения.", nil, nil)
+	poolAcquireDuration = prometheus.NewDesc("db_pool_acquire_duration_seconds_total",
+		"Суммарное время ожидания соединений.", nil, nil)
+)
+
+// poolCollector читает pgxpool.Stat при каждом запросе метрик.
+type poolCollector struct{}
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolConns
+	ch <- poolMaxConns
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolCanceledAcquires
+	ch <- poolAcquireDuration
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	p := pool.Load()
+	if p == nil {
+		return
+	}
+	stat := p.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), "acquired")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.IdleConns()), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(stat.ConstructingConns()), "constructing")
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
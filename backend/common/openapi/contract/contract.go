@@ -0,0 +1,128 @@
+// Package contract проверяет настоящие ответы обработчиков по OpenAPI
+// спецификации сервиса. Используется в тестах.
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// Изображения отдаются как есть, без разбора тела
+func init() {
+	for _, contentType := range []string{"image/jpeg", "image/png", "image/gif", "image/webp"} {
+		openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+	}
+}
+
+// Validator сверяет ответы со спецификацией.
+type Validator struct {
+	router routers.Router
+}
+
+// New разбирает и проверяет спецификацию.
+func New(spec []byte) (*Validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("contract/New разбор спецификации: %w", err)
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("contract/New проверка спецификации: %w", err)
+	}
+	if err = checkStatuses(doc); err != nil {
+		return nil, err
+	}
+
+	// Тестовые запросы идут на httptest, поэтому адреса серверов не важны
+	doc.Servers = nil
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("contract/New маршруты: %w", err)
+	}
+
+	return &Validator{router: router}, nil
+}
+
+// Do выполняет запрос через handler и проверяет, что маршрут и ответ
+// описаны в спецификации. Тело запроса не проверяется, чтобы тесты могли
+// отправлять заведомо неправильные данные. Возвращает ответ для дальнейших
+// проверок.
+func (v *Validator) Do(handler http.Handler, req *http.Request) (*httptest.ResponseRecorder, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("contract/Do чтение запроса: %w", err)
+		}
+	}
+
+	route, params, err := v.router.FindRoute(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s нет в спецификации: %w", req.Method, req.URL.Path, err)
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		MultiError:            true,
+	}
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options:    options,
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// ResponseRecorder сохраняет статус 1xx, а настоящий сервер отправил бы
+	// вместо него 200
+	if rec.Code < http.StatusOK {
+		return rec, fmt.Errorf("%s %s ответ %d: статус 1xx не может быть окончательным",
+			req.Method, req.URL.Path, rec.Code)
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options:                options,
+	}
+	if err = openapi3filter.ValidateResponse(req.Context(), responseInput); err != nil {
+		return rec, fmt.Errorf("%s %s ответ %d не соответствует спецификации: %w",
+			req.Method, req.URL.Path, rec.Code, err)
+	}
+
+	return rec, nil
+}
+
+// checkStatuses запрещает описывать ответы 1xx: клиент никогда не получит
+// их как окончательный ответ.
+func checkStatuses(doc *openapi3.T) error {
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			if operation.Responses == nil {
+				continue
+			}
+			for status := range operation.Responses.Map() {
+				if strings.HasPrefix(status, "1") {
+					return fmt.Errorf("contract/New %s %s: ответ %s не может быть окончательным", method, path, status)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package contract_test
+
+import (
+	"common/openapi/contract"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func spec(status string) []byte {
+	return []byte(`{
+		"openapi": "3.0.3",
+		"info": {"title": "test", "version": "1.0.0"},
+		"paths": {"/ping": {"get": {"responses": {"` + status + `": {"description": "ok"}}}}}
+	}`)
+}
+
+func TestInformationalStatus(t *testing.T) {
+	_, err := contract.New(spec("100"))
+	assert.ErrorContains(t, err, "100")
+
+	validator, err := contract.New(spec("200"))
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusOK) })
+	_, err = validator.Do(ok, httptest.NewRequest("GET", "/ping", nil))
+	assert.NoError(t, err)
+
+	informational := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { rw.WriteHeader(http.StatusContinue) })
+	_, err = validator.Do(informational, httptest.NewRequest("GET", "/ping", strings.NewReader("")))
+	assert.ErrorContains(t, err, "1xx")
+}
@@ -0,0 +1,16 @@
+// Package openapi отдаёт OpenAPI спецификацию сервиса.
+package openapi
+
+import "net/http"
+
+// Path - адрес, по которому каждый сервис отдаёт свою спецификацию.
+const Path = "/openapi.json"
+
+// Handler отдаёт встроенную в сервис спецификацию как есть.
+func Handler(spec []byte) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Write(spec)
+	}
+}
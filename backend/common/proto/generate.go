@@ -0,0 +1,6 @@
+// Package proto содержит описания внутренних gRPC API сервисов и
+// сгенерированный по ним код в подпакетах authpb, authzpb и catalogpb.
+// После изменения .proto файлов код нужно сгенерировать заново.
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative authpb/auth.proto authzpb/authz.proto catalogpb/catalog.proto
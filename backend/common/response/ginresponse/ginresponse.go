@@ -0,0 +1,29 @@
+// Package ginresponse отправляет problem+json из обработчиков gin. Вынесен
+// отдельно, чтобы сервисы на gorilla/mux не зависели от gin.
+package ginresponse
+
+import (
+	"common/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendProblem отправляет ошибку в формате problem+json со стабильным кодом.
+func SendProblem(c *gin.Context, status int, code response.ErrorCode, detail string) {
+	WriteProblem(c, response.NewProblem(status, code, detail))
+}
+
+// WriteProblem пишет problem в ответ с его статусом. Ошибку другого
+// сервиса так можно вернуть клиенту без изменений.
+func WriteProblem(c *gin.Context, problem response.Problem) {
+	c.Header("Content-Type", response.ProblemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+func SendBadRequest(c *gin.Context) {
+	WriteProblem(c, response.BadRequest())
+}
+
+func SendInternalServerError(c *gin.Context) {
+	WriteProblem(c, response.InternalServerError())
+}
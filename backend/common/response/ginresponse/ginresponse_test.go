@@ -0,0 +1,38 @@
+package ginresponse_test
+
+import (
+	"common/response"
+	"common/response/ginresponse"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/product/:id", func(c *gin.Context) {
+		ginresponse.SendProblem(c, http.StatusNotFound, response.CodeProductNotFound, "Товар не найден")
+	})
+	r.GET("/bad", ginresponse.SendBadRequest)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product/7", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, response.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, response.NewProblem(http.StatusNotFound, response.CodeProductNotFound, "Товар не найден"), problem)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bad", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, response.CodeBadRequest, problem.Code)
+}
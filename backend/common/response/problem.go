@@ -0,0 +1,114 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType - тип содержимого ответа с ошибкой по RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix вместе с кодом ошибки образует URI типа проблемы.
+const problemTypePrefix = "urn:problem-type:"
+
+// ErrorCode - стабильный машинный код ошибки. Клиенты ветвятся по нему,
+// а не по тексту сообщения, который может меняться.
+type ErrorCode string
+
+const (
+	CodeBadRequest   ErrorCode = "BAD_REQUEST"
+	CodeValidation   ErrorCode = "VALIDATION_FAILED"
+	CodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	CodeForbidden    ErrorCode = "FORBIDDEN"
+	CodeNotFound     ErrorCode = "NOT_FOUND"
+	CodeConflict     ErrorCode = "CONFLICT"
+	CodeInternal     ErrorCode = "INTERNAL_ERROR"
+	CodeUnavailable  ErrorCode = "SERVICE_UNAVAILABLE"
+	CodeRateLimited  ErrorCode = "RATE_LIMITED"
+
+	// Товары и изображения
+	CodeProductNotFound    ErrorCode = "PRODUCT_NOT_FOUND"
+	CodeInsufficientStock  ErrorCode = "INSUFFICIENT_STOCK"
+	CodeImageNotFound      ErrorCode = "IMAGE_NOT_FOUND"
+	CodeInvalidImage       ErrorCode = "INVALID_IMAGE"
+	CodeImageOrderMismatch ErrorCode = "IMAGE_ORDER_MISMATCH"
+
+	// Пользователи
+	CodeLoginTaken           ErrorCode = "LOGIN_TAKEN"
+	CodeInvalidCredentials   ErrorCode = "INVALID_CREDENTIALS"
+	CodeWrongPassword        ErrorCode = "WRONG_PASSWORD"
+	CodeUserNotFound         ErrorCode = "USER_NOT_FOUND"
+	CodeAddressNotFound      ErrorCode = "ADDRESS_NOT_FOUND"
+	CodeDeletionNotRequested ErrorCode = "DELETION_NOT_REQUESTED"
+
+	// Токены, сессии и API ключи
+	CodeTokenExpired    ErrorCode = "TOKEN_EXPIRED"
+	CodeTokenInvalid    ErrorCode = "TOKEN_INVALID"
+	CodeSessionInactive ErrorCode = "SESSION_INACTIVE"
+	CodeNotAdmin        ErrorCode = "NOT_ADMIN"
+	CodeAPIKeyInvalid   ErrorCode = "API_KEY_INVALID"
+	CodeAPIKeyForbidden ErrorCode = "API_KEY_FORBIDDEN"
+	CodeAPIKeyScope     ErrorCode = "API_KEY_SCOPE_MISSING"
+	CodeAPIKeyNotFound  ErrorCode = "API_KEY_NOT_FOUND"
+)
+
+// Problem - описание ошибки по RFC 7807 с расширением code.
+type Problem struct {
+	Type   string    `json:"type"`
+	Title  string    `json:"title"`
+	Status int       `json:"status"`
+	Detail string    `json:"detail,omitempty"`
+	Code   ErrorCode `json:"code"`
+}
+
+// NewProblem собирает Problem. Если код не указан, он выводится из статуса.
+func NewProblem(status int, code ErrorCode, detail string) Problem {
+	if code == "" {
+		code = codeForStatus(status)
+	}
+	return Problem{
+		Type:   problemTypePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func BadRequest() Problem {
+	return NewProblem(http.StatusBadRequest, CodeBadRequest, MessageBadRequest)
+}
+
+func InternalServerError() Problem {
+	return NewProblem(http.StatusInternalServerError, CodeInternal, MessageInternalError)
+}
+
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// WriteProblem пишет p в ответ с его статусом. Для сервисов без gin.
+func WriteProblem(rw http.ResponseWriter, p Problem) error {
+	rw.Header().Set("Content-Type", ProblemContentType)
+	rw.WriteHeader(p.Status)
+	return json.NewEncoder(rw).Encode(p)
+}
@@ -0,0 +1,72 @@
+// Package response - общий для сервисов формат ответа: конверт
+// успешного результата и problem+json (RFC 7807) для ошибок.
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	MessageOK            = "Выполнение прошло успешно"
+	MessageCreated       = "Объект успешно создан"
+	MessageBadRequest    = "Ошибка запроса, невозможно прочитать данные"
+	MessageInternalError = "Внутренняя ошибка сервера"
+)
+
+// Response - конверт, в котором сервисы возвращают результат операции.
+// Code совпадает с http статусом. ErrorCode заполняется для ошибок и
+// попадает в ответ только через Problem.
+type Response struct {
+	Code      int       `json:"code"`
+	Message   string    `json:"message"`
+	ErrorCode ErrorCode `json:"-"`
+}
+
+func New(code int, message string) Response {
+	return Response{Code: code, Message: message}
+}
+
+func (resp *Response) Error(code int, errorCode ErrorCode, message string) {
+	resp.Code = code
+	resp.ErrorCode = errorCode
+	resp.Message = message
+}
+
+func (resp *Response) InternalError() {
+	resp.Error(http.StatusInternalServerError, CodeInternal, MessageInternalError)
+}
+
+// Failed сообщает, что ответ описывает ошибку.
+func (resp *Response) Failed() bool {
+	return resp.Code >= http.StatusBadRequest
+}
+
+// Problem превращает ошибочный ответ в problem+json.
+func (resp *Response) Problem() Problem {
+	return NewProblem(resp.Code, resp.ErrorCode, resp.Message)
+}
+
+func (resp *Response) StatusOK() {
+	*resp = New(http.StatusOK, MessageOK)
+}
+
+func (resp *Response) StatusCreated() {
+	*resp = New(http.StatusCreated, MessageCreated)
+}
+
+// WriteJSON пишет v в ответ с указанным статусом. Для сервисов без gin.
+func WriteJSON(rw http.ResponseWriter, status int, v any) error {
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+	return json.NewEncoder(rw).Encode(v)
+}
+
+// Write пишет v с настоящим http статусом из resp, а ошибку - в
+// формате problem+json. Для сервисов без gin.
+func Write(rw http.ResponseWriter, resp Response, v any) error {
+	if resp.Failed() {
+		return WriteProblem(rw, resp.Problem())
+	}
+	return WriteJSON(rw, resp.Code, v)
+}
@@ -0,0 +1,48 @@
+package response_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"common/response"
+)
+
+func TestWriteProblem(t *testing.T) {
+	resp := response.Response{}
+	resp.Error(http.StatusNotFound, response.CodeProductNotFound, "Товар не найден")
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, response.Write(rec, resp, resp))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, response.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	problem := response.Problem{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, response.CodeProductNotFound, problem.Code)
+	assert.Equal(t, "urn:problem-type:PRODUCT_NOT_FOUND", problem.Type)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, "Товар не найден", problem.Detail)
+}
+
+func TestWriteSuccess(t *testing.T) {
+	resp := response.Response{}
+	resp.StatusCreated()
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, response.Write(rec, resp, resp))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"code":201,"message":"Объект успешно создан"}`, rec.Body.String())
+}
+
+func TestNewProblemDefaultCode(t *testing.T) {
+	assert.Equal(t, response.CodeUnauthorized, response.NewProblem(http.StatusUnauthorized, "", "").Code)
+	assert.Equal(t, response.CodeInternal, response.NewProblem(http.StatusBadGateway, "", "").Code)
+	assert.Equal(t, response.CodeUnavailable, response.NewProblem(http.StatusServiceUnavailable, "", "").Code)
+	assert.Equal(t, response.CodeRateLimited, response.NewProblem(http.StatusTooManyRequests, "", "").Code)
+}
@@ -0,0 +1,157 @@
+// Package rpc - общие настройки внутренних gRPC серверов и клиентов:
+// учётные данные TLS, перехват паник и перевод ошибок в problem+json.
+package rpc
+
+import (
+	"common/config"
+	"common/metrics"
+	"common/tracing"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime/debug"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// TLS - файлы сертификатов для внутренних соединений. Пока они не
+// заданы, сервисы общаются без шифрования внутри сети docker compose.
+// Если задан CAFile, сервер требует сертификат клиента (mTLS), а клиент
+// проверяет сертификат сервера по этому CA.
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ServerName - имя в сертификате сервера, если оно не совпадает
+	// с адресом подключения. Только для клиента.
+	ServerName string
+}
+
+// LoadTLS читает grpc_tls_cert, grpc_tls_key, grpc_tls_ca и
+// grpc_tls_server_name.
+func LoadTLS() (TLS, error) {
+	l := &config.Loader{}
+	cfg := TLS{
+		CertFile:   l.StringOr("grpc_tls_cert", ""),
+		KeyFile:    l.StringOr("grpc_tls_key", ""),
+		CAFile:     l.StringOr("grpc_tls_ca", ""),
+		ServerName: l.StringOr("grpc_tls_server_name", ""),
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return cfg, errors.New("rpc/LoadTLS: grpc_tls_cert и grpc_tls_key задаются вместе")
+	}
+	return cfg, nil
+}
+
+// ServerCredentials возвращает учётные данные сервера: TLS, если задан
+// сертификат, и mTLS, если задан ещё и CA клиентов.
+func (cfg TLS) ServerCredentials() (credentials.TransportCredentials, error) {
+	if cfg.CertFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("rpc/ServerCredentials сертификат: %w", err)
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13}
+
+	if cfg.CAFile != "" {
+		if tlsCfg.ClientCAs, err = loadPool(cfg.CAFile); err != nil {
+			return nil, fmt.Errorf("rpc/ServerCredentials: %w", err)
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// ClientCredentials возвращает учётные данные клиента: TLS, если задан
+// CA или собственный сертификат клиента, иначе соединение без шифрования.
+func (cfg TLS) ClientCredentials() (credentials.TransportCredentials, error) {
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsCfg := &tls.Config{ServerName: cfg.ServerName, MinVersion: tls.VersionTLS13}
+
+	if cfg.CAFile != "" {
+		var err error
+		if tlsCfg.RootCAs, err = loadPool(cfg.CAFile); err != nil {
+			return nil, fmt.Errorf("rpc/ClientCredentials: %w", err)
+		}
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("rpc/ClientCredentials сертификат: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("чтение CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("в %s нет сертификатов", path)
+	}
+	return pool, nil
+}
+
+// NewServer создаёт gRPC сервер с учётными данными из cfg. Паника
+// в обработчике возвращается клиенту как INTERNAL и не роняет сервис.
+// Сервер отвечает на grpc.health.v1.Health: по нему клиенты исключают
+// неисправные экземпляры из балансировки. Вызовы попадают в трассировку,
+// метрики и лог logger вместе с идентификатором запроса клиента.
+func NewServer(cfg TLS, logger zerolog.Logger, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	creds, err := cfg.ServerCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]grpc.ServerOption{
+		grpc.Creds(creds),
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(logger), metrics.UnaryServerInterceptor, recoverUnary),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return server, nil
+}
+
+// ListenAndServe слушает addr и обслуживает server, пока он не будет
+// остановлен.
+func ListenAndServe(server *grpc.Server, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("rpc/ListenAndServe: %w", err)
+	}
+	return server.Serve(listener)
+}
+
+func recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			zerolog.Ctx(ctx).Error().Msgf("Паника в %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "Внутренняя ошибка сервера")
+		}
+	}()
+	return handler(ctx, req)
+}
@@ -0,0 +1,223 @@
+package rpc_test
+
+import (
+	"common/proto/authpb"
+	"common/response"
+	"common/rpc"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorRoundTrip(t *testing.T) {
+	err := rpc.Errorf(http.StatusConflict, response.CodeLoginTaken, "Данный логин уже занят")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	problem, ok := rpc.Problem(err)
+	require.True(t, ok)
+	assert.Equal(t, response.NewProblem(http.StatusConflict, response.CodeLoginTaken, "Данный логин уже занят"), problem)
+}
+
+func TestProblemWithoutDetails(t *testing.T) {
+	problem, ok := rpc.Problem(status.Error(codes.Unavailable, "connection refused"))
+	require.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, problem.Status)
+	assert.Equal(t, response.CodeUnavailable, problem.Code)
+
+	_, ok = rpc.Problem(errors.New("не статус"))
+	assert.False(t, ok)
+}
+
+func TestFromResponse(t *testing.T) {
+	resp := response.Response{}
+	resp.StatusOK()
+	assert.NoError(t, rpc.FromResponse(resp))
+
+	resp.Error(http.StatusNotFound, response.CodeProductNotFound, "Товар не найден")
+	problem, ok := rpc.Problem(rpc.FromResponse(resp))
+	require.True(t, ok)
+	assert.Equal(t, response.CodeProductNotFound, problem.Code)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+}
+
+type authServer struct {
+	authpb.UnimplementedAuthServer
+}
+
+func (authServer) Login(context.Context, *authpb.Credentials) (*authpb.User, error) {
+	return &authpb.User{Id: "id"}, nil
+}
+
+func (authServer) Register(context.Context, *authpb.Credentials) (*authpb.User, error) {
+	panic("сломалось")
+}
+
+// serve запускает Auth на свободном порту и возвращает его адрес.
+func serve(t *testing.T, cfg rpc.TLS) string {
+	t.Helper()
+	server, err := rpc.NewServer(cfg, zerolog.Nop())
+	require.NoError(t, err)
+	authpb.RegisterAuthServer(server, authServer{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+func login(t *testing.T, addr string, cfg rpc.TLS) error {
+	t.Helper()
+	creds, err := cfg.ClientCredentials()
+	require.NoError(t, err)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = authpb.NewAuthClient(conn).Login(ctx, &authpb.Credentials{})
+	return err
+}
+
+func TestInsecureByDefault(t *testing.T) {
+	addr := serve(t, rpc.TLS{})
+	assert.NoError(t, login(t, addr, rpc.TLS{}))
+}
+
+func TestRecoverPanic(t *testing.T) {
+	addr := serve(t, rpc.TLS{})
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = authpb.NewAuthClient(conn).Register(context.Background(), &authpb.Credentials{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestHealth(t *testing.T) {
+	addr := serve(t, rpc.TLS{})
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir)
+	server := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	client := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+
+	addr := serve(t, rpc.TLS{CertFile: server.cert, KeyFile: server.key, CAFile: ca.file})
+
+	assert.NoError(t, login(t, addr, rpc.TLS{CertFile: client.cert, KeyFile: client.key, CAFile: ca.file, ServerName: "server"}))
+
+	// Без сертификата клиента сервер разрывает соединение
+	assert.Error(t, login(t, addr, rpc.TLS{CAFile: ca.file, ServerName: "server"}))
+	// Без шифрования тоже
+	assert.Error(t, login(t, addr, rpc.TLS{}))
+}
+
+func TestLoadTLS(t *testing.T) {
+	t.Setenv("grpc_tls_cert", "cert.pem")
+	t.Setenv("grpc_tls_key", "")
+	_, err := rpc.LoadTLS()
+	assert.Error(t, err)
+
+	t.Setenv("grpc_tls_key", "key.pem")
+	cfg, err := rpc.LoadTLS()
+	require.NoError(t, err)
+	assert.Equal(t, rpc.TLS{CertFile: "cert.pem", KeyFile: "key.pem"}, cfg)
+}
+
+type certFiles struct {
+	cert string
+	key  string
+}
+
+type testCA struct {
+	file string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T, dir string) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, "ca.pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return testCA{file: file, cert: cert, key: key}
+}
+
+// issue выпускает сертификат name, подписанный CA.
+func (ca testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) certFiles {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	files := certFiles{cert: filepath.Join(dir, name+".pem"), key: filepath.Join(dir, name+"-key.pem")}
+	writePEM(t, files.cert, "CERTIFICATE", der)
+	writePEM(t, files.key, "EC PRIVATE KEY", keyDER)
+	return files
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"common/response"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain - домен ErrorInfo, в котором передаются коды ошибок
+// сервисов магазина.
+const errorDomain = "xolead"
+
+// Error превращает problem в статус gRPC. Стабильный код ошибки и http
+// статус передаются в ErrorInfo, чтобы manage_service вернул клиенту
+// тот же problem+json, что и при обращении по http.
+func Error(problem response.Problem) error {
+	st := status.New(grpcCode(problem.Status), problem.Detail)
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   string(problem.Code),
+		Domain:   errorDomain,
+		Metadata: map[string]string{"status": strconv.Itoa(problem.Status)},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
+// Errorf - Error для problem, собранного из статуса, кода и текста.
+func Errorf(status int, code response.ErrorCode, detail string) error {
+	return Error(response.NewProblem(status, code, detail))
+}
+
+// FromResponse возвращает ошибку для неуспешного ответа сервиса и nil
+// для успешного.
+func FromResponse(resp response.Response) error {
+	if !resp.Failed() {
+		return nil
+	}
+	return Error(resp.Problem())
+}
+
+// Problem восстанавливает problem из ошибки вызова. Если сервер не
+// передал ErrorInfo, статус и код выводятся из кода gRPC. ok равен
+// false, если err не статус gRPC.
+func Problem(err error) (problem response.Problem, ok bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return response.Problem{}, false
+	}
+
+	for _, detail := range st.Details() {
+		info, isInfo := detail.(*errdetails.ErrorInfo)
+		if !isInfo || info.GetDomain() != errorDomain {
+			continue
+		}
+		httpStatus, convErr := strconv.Atoi(info.GetMetadata()["status"])
+		if convErr != nil {
+			httpStatus = HTTPStatus(st.Code())
+		}
+		return response.NewProblem(httpStatus, response.ErrorCode(info.GetReason()), st.Message()), true
+	}
+
+	return response.NewProblem(HTTPStatus(st.Code()), "", st.Message()), true
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.InvalidArgument
+}
+
+// HTTPStatus - http статус, соответствующий коду gRPC.
+func HTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition, codes.Aborted:
+		return http.StatusConflict
+	case codes.Unavailable, codes.ResourceExhausted:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
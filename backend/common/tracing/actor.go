@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Заголовки, в которых manage_service передаёт сервисам, кто выполняет
+// запрос. Их значения задаёт только manage_service, см. actorPropagator.
+const (
+	ActorIDHeader        = "X-Actor-ID"
+	ActorIPHeader        = "X-Actor-IP"
+	ActorUserAgentHeader = "X-Actor-User-Agent"
+)
+
+// maxActorFieldLength ограничивает значения заголовков, пришедшие от
+// вызывающего сервиса.
+const maxActorFieldLength = 512
+
+// Actor - кто выполняет запрос. manage_service определяет его по токену
+// или API ключу и адресу клиента, сервисы записывают его в журнал аудита.
+type Actor struct {
+	// ID - GUID пользователя или "apikey:<id>". Пустой у запросов без
+	// авторизации и у фоновых задач
+	ID        string
+	IP        string
+	UserAgent string
+}
+
+type actorKey struct{}
+
+// WithActor сохраняет actor в ctx.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom возвращает actor из ctx или пустой Actor.
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// actorPropagator передаёт Actor в http заголовках и метаданных gRPC.
+// Inject всегда записывает все заголовки, даже пустые: запрос, который
+// manage_service пересылает сервису вместе с заголовками клиента, не
+// должен нести подставленного клиентом Actor.
+type actorPropagator struct{}
+
+func (actorPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	actor := ActorFrom(ctx)
+	carrier.Set(ActorIDHeader, actor.ID)
+	carrier.Set(ActorIPHeader, actor.IP)
+	carrier.Set(ActorUserAgentHeader, actor.UserAgent)
+}
+
+func (actorPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	actor := Actor{
+		ID:        actorField(carrier, ActorIDHeader),
+		IP:        actorField(carrier, ActorIPHeader),
+		UserAgent: actorField(carrier, ActorUserAgentHeader),
+	}
+	if actor == (Actor{}) {
+		return ctx
+	}
+	return WithActor(ctx, actor)
+}
+
+func (actorPropagator) Fields() []string {
+	return []string{ActorIDHeader, ActorIPHeader, ActorUserAgentHeader}
+}
+
+func actorField(carrier propagation.TextMapCarrier, header string) string {
+	value := carrier.Get(header)
+	if value == "" {
+		// Метаданные gRPC хранят ключи в нижнем регистре
+		value = carrier.Get(strings.ToLower(header))
+	}
+	if len(value) > maxActorFieldLength {
+		value = value[:maxActorFieldLength]
+	}
+	return value
+}
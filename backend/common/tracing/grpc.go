@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOption создаёт спаны для входящих gRPC вызовов. Контекст трассы
+// и идентификатор запроса берутся из метаданных вызова.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
+
+// DialOption создаёт спаны для исходящих gRPC вызовов и передаёт
+// сервису traceparent и x-request-id в метаданных.
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
+
+// UnaryServerInterceptor - аналог Handler для gRPC: кладёт в контекст
+// logger с идентификаторами запроса и трассы и пишет строку лога
+// о завершённом вызове.
+func UnaryServerInterceptor(logger zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if RequestID(ctx) == "" {
+			ctx = WithRequestID(ctx, NewRequestID())
+		}
+		callLogger := requestLogger(logger, ctx)
+		ctx = callLogger.WithContext(ctx)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		callLogger.Info().
+			Str("method", info.FullMethod).
+			Str("code", status.Code(err).String()).
+			Dur("duration", time.Since(start)).
+			Msg("Вызов обработан")
+		return resp, err
+	}
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler создаёт спан для каждого входящего запроса. Контекст трассы
+// и X-Request-ID берутся из заголовков запроса, если их нет, создаются
+// новые. Идентификатор запроса возвращается клиенту в X-Request-ID, а
+// logger с request_id и trace_id доступен обработчикам через zerolog.Ctx.
+func Handler(service string, logger zerolog.Logger, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(requestScope(logger, next), service,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// SetRoute называет спан запроса по шаблону маршрута, например
+// "GET /product/:id", чтобы запросы к разным товарам попадали в одну
+// группу. Вызывается из middleware роутера, когда маршрут уже найден.
+func SetRoute(r *http.Request, route string) {
+	if route == "" {
+		return
+	}
+	span := trace.SpanFromContext(r.Context())
+	span.SetName(r.Method + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// Transport создаёт спан для каждого исходящего запроса и передаёт
+// сервису traceparent и X-Request-ID.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// requestScope дополняет контекст запроса идентификатором и логгером
+// и пишет строку лога о завершённом запросе.
+func requestScope(base zerolog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := RequestID(ctx)
+		if id == "" {
+			id = NewRequestID()
+			ctx = WithRequestID(ctx, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := requestLogger(base, ctx)
+		ctx = logger.WithContext(ctx)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		logger.Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", recorder.status).
+			Dur("duration", time.Since(start)).
+			Msg("Запрос обработан")
+	})
+}
+
+// requestLogger дополняет logger идентификаторами запроса и трассы
+// из ctx.
+func requestLogger(logger zerolog.Logger, ctx context.Context) *zerolog.Logger {
+	fields := logger.With()
+	if id := RequestID(ctx); id != "" {
+		fields = fields.Str("request_id", id)
+	}
+	if id := TraceID(ctx); id != "" {
+		fields = fields.Str("trace_id", id)
+	}
+	scoped := fields.Logger()
+	return &scoped
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush нужен для потоковых ответов, например изображений, которые
+// manage_service передаёт из core_service.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush и другим
+// возможностям исходного ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// RequestIDHeader - заголовок, по которому запрос клиента находится
+// в логах всех сервисов.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает идентификатор, пришедший от клиента,
+// чтобы он не раздувал логи.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID сохраняет идентификатор запроса в ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из ctx или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID создаёт случайный идентификатор запроса.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID пропускает только печатные ASCII символы без пробелов.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDPropagator передаёт идентификатор запроса вместе с traceparent:
+// в http заголовке X-Request-ID и в одноимённых метаданных gRPC.
+type requestIDPropagator struct{}
+
+func (requestIDPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	if id := RequestID(ctx); id != "" {
+		carrier.Set(RequestIDHeader, id)
+	}
+}
+
+func (requestIDPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	id := carrier.Get(RequestIDHeader)
+	if id == "" {
+		// Метаданные gRPC хранят ключи в нижнем регистре
+		id = carrier.Get("x-request-id")
+	}
+	if !validRequestID(id) {
+		return ctx
+	}
+	return WithRequestID(ctx, id)
+}
+
+func (requestIDPropagator) Fields() []string {
+	return []string{RequestIDHeader}
+}
@@ -0,0 +1,107 @@
+// Package tracing связывает запросы, которые проходят через несколько
+// сервисов: передаёт X-Request-ID и W3C traceparent и создаёт спаны
+// OpenTelemetry для входящих запросов, исходящих вызовов и запросов к бд.
+package tracing
+
+import (
+	"common/config"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// instrumentation - имя, под которым спаны сервисов попадают в трассировку.
+const instrumentation = "common/tracing"
+
+type Config struct {
+	Exporter string
+	// SampleRatio - доля новых трасс, которые записываются. Решение
+	// вызывающего сервиса из traceparent соблюдается.
+	SampleRatio float64
+}
+
+// LoadConfig читает tracing_exporter: none (по умолчанию, идентификаторы
+// трасс есть в логах, но спаны никуда не отправляются), stdout или otlp.
+// Адрес коллектора для otlp задают стандартные переменные
+// OTEL_EXPORTER_OTLP_ENDPOINT или OTEL_EXPORTER_OTLP_TRACES_ENDPOINT.
+// tracing_sample_percent - процент записываемых трасс, по умолчанию 100.
+func LoadConfig() (Config, error) {
+	l := &config.Loader{}
+	cfg := Config{
+		Exporter:    l.OneOf("tracing_exporter", ExporterNone, ExporterNone, ExporterStdout, ExporterOTLP),
+		SampleRatio: float64(l.IntOr("tracing_sample_percent", 100, 0, 100)) / 100,
+	}
+	if err := l.Err(); err != nil {
+		return cfg, fmt.Errorf("tracing/LoadConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+// Setup настраивает глобальные TracerProvider и propagator для сервиса
+// service. Возвращённая функция отправляет накопленные спаны и
+// останавливает экспорт, её нужно вызвать при остановке сервиса.
+func Setup(ctx context.Context, service string, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+		requestIDPropagator{},
+		actorPropagator{},
+	))
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing/Setup %s: %w", cfg.Exporter, err)
+	}
+	if exporter != nil {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		return errors.Join(provider.ForceFlush(ctx), provider.Shutdown(ctx))
+	}, nil
+}
+
+// Tracer возвращает tracer сервисов из глобального TracerProvider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// TraceID возвращает идентификатор трассы из ctx или пустую строку.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,182 @@
+package tracing_test
+
+import (
+	"common/tracing"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// ids - идентификаторы, которые увидел обработчик сервиса.
+type ids struct {
+	request, trace string
+}
+
+func setup(t *testing.T) {
+	t.Helper()
+	shutdown, err := tracing.Setup(context.Background(), "test", tracing.Config{Exporter: tracing.ExporterNone, SampleRatio: 1})
+	require.NoError(t, err)
+	t.Cleanup(func() { shutdown(context.Background()) })
+}
+
+func TestHandler(t *testing.T) {
+	setup(t)
+
+	// Цепочка как у входа: manage_service вызывает сервис по http
+	var downstream ids
+	service := httptest.NewServer(tracing.Handler("service", zerolog.Nop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = ids{tracing.RequestID(r.Context()), tracing.TraceID(r.Context())}
+	})))
+	defer service.Close()
+
+	var upstream ids
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	gateway := httptest.NewServer(tracing.Handler("gateway", zerolog.Nop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = ids{tracing.RequestID(r.Context()), tracing.TraceID(r.Context())}
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, service.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})))
+	defer gateway.Close()
+
+	get := func(requestID string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, gateway.URL, nil)
+		require.NoError(t, err)
+		if requestID != "" {
+			req.Header.Set(tracing.RequestIDHeader, requestID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Идентификатор клиента передаётся дальше и возвращается в ответе
+	resp := get("login-42")
+	assert.Equal(t, "login-42", resp.Header.Get(tracing.RequestIDHeader))
+	assert.Equal(t, "login-42", upstream.request)
+	assert.NotEmpty(t, upstream.trace)
+	assert.Equal(t, upstream, downstream)
+
+	// Без идентификатора и с недопустимым идентификатором создаётся новый
+	for _, requestID := range []string{"", "bad id"} {
+		resp = get(requestID)
+		generated := resp.Header.Get(tracing.RequestIDHeader)
+		assert.Len(t, generated, 32)
+		assert.Equal(t, generated, upstream.request)
+		assert.Equal(t, upstream, downstream)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	setup(t)
+
+	var seen ids
+	capture := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		seen = ids{tracing.RequestID(ctx), tracing.TraceID(ctx)}
+		return handler(ctx, req)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(tracing.ServerOption(), grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor(zerolog.Nop()), capture))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.DialOption(),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, span := tracing.Tracer().Start(tracing.WithRequestID(context.Background(), "login-42"), "test")
+	defer span.End()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	assert.Equal(t, ids{"login-42", tracing.TraceID(ctx)}, seen)
+}
+
+func TestActor(t *testing.T) {
+	setup(t)
+
+	var seen tracing.Actor
+	service := httptest.NewServer(tracing.Handler("service", zerolog.Nop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = tracing.ActorFrom(r.Context())
+	})))
+	defer service.Close()
+
+	// Шлюз пересылает запрос вместе с заголовками клиента, как Forward
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	gateway := httptest.NewServer(tracing.Handler("gateway", zerolog.Nop(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.WithActor(r.Context(), tracing.Actor{IP: "10.0.0.1", UserAgent: r.UserAgent()})
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, service.URL, nil)
+		require.NoError(t, err)
+		req.Header = r.Header.Clone()
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})))
+	defer gateway.Close()
+
+	// Подставленный клиентом Actor до сервиса не доходит
+	req, err := http.NewRequest(http.MethodGet, gateway.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "shop-app/1.0")
+	req.Header.Set(tracing.ActorIDHeader, "admin-guid")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, tracing.Actor{IP: "10.0.0.1", UserAgent: "shop-app/1.0"}, seen)
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	tracer := tracing.QueryTracer{}
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  "select guid from users where login = $1",
+		Args: []any{"secret"},
+	})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("нет соединения")})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "postgresql SELECT", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.query.text", "select guid from users where login = $1"))
+	for _, attr := range spans[0].Attributes() {
+		assert.NotEqual(t, "secret", attr.Value.Emit())
+	}
+}
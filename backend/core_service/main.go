@@ -1,64 +1,40 @@
 package main
 
 import (
-	"log"
-	"net/http"
-
-	"github.com/gorilla/mux"
-
-	"core-service/pkg/handlers"
+	"context"
+	"os/signal"
+	"syscall"
+
+	"common/health"
+	"common/logger"
+	"common/tracing"
+	"core-service/pkg/app"
 )
 
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Разрешаем конкретные origin вместо *
-		allowedOrigins := []string{
-			"http://manage_service:8080",
-		}
-
-		origin := r.Header.Get("Origin")
-		allowed := false
-
-		for _, o := range allowedOrigins {
-			if o == origin {
-				allowed = true
-				break
-			}
-		}
-
-		if allowed {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-		} else {
-			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-		}
-
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().
-			Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, x-amz-acl, x-amz-meta-*")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, ETag")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 func main() {
-
-	router := mux.NewRouter()
-	router.Use(CORS)
-	router.HandleFunc("/product", handlers.CreateProduct).Methods("POST")
-	router.HandleFunc("/product", handlers.ReadAllProduct).Methods("GET")
-	router.HandleFunc("/product/{id}", handlers.ReadProduct).Methods("GET")
-	router.HandleFunc("/product/{id}", handlers.DeleteProduct).Methods("DELETE")
-	router.HandleFunc("/product/change", handlers.ChangeCountProduct).Methods("PUT")
-	log.Println("Сервер запущен")
-
-	log.Fatal(http.ListenAndServe(":8082", router))
-
+	health.Probe()
+
+	log := logger.New("core_service")
+
+	ctx, stop := signal.NotifyContext(log.WithContext(context.Background()), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	tracingCfg, err := tracing.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации трассировки: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(ctx, "core_service", tracingCfg)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка настройки трассировки: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	application, err := app.New(ctx)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка запуска: %v", err)
+	}
+
+	if err = application.Run(ctx); err != nil {
+		log.Fatal().Msgf("Ошибка работы сервера: %v", err)
+	}
 }
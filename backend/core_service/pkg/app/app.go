@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+
+	"common/audit"
+	"common/config"
+	"common/health"
+	"common/metrics"
+	"common/openapi"
+	"common/proto/catalogpb"
+	"common/rpc"
+	"common/tracing"
+	"core-service/api"
+	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/dbwork"
+	"core-service/pkg/handlers"
+	"core-service/pkg/rpcserver"
+	"core-service/pkg/service"
+)
+
+const (
+	addr = ":8082"
+	// shutdownTimeout - сколько ждём завершения запросов, уже принятых
+	// сервером, после получения сигнала остановки
+	shutdownTimeout = 15 * time.Second
+	// readyTimeout ограничивает каждую проверку готовности
+	readyTimeout = 2 * time.Second
+)
+
+// App владеет всеми зависимостями core_service и отвечает за их
+// создание и закрытие.
+type App struct {
+	log     *zerolog.Logger
+	db      dbwork.DataBase
+	storage cloudstorage.CloudStorage
+	service *service.Service
+	server  *http.Server
+	// health - проверки готовности: бд и хранилище
+	health *health.Checker
+
+	// grpc - внутренний API каталога для manage_service
+	grpc     *grpc.Server
+	grpcAddr string
+}
+
+// New подключается к бд, применяет миграции и создаёт хранилище.
+// Ошибки возвращаются вызывающему, а не приводят к панике. Сервис пишет
+// в логгер из ctx.
+func New(ctx context.Context) (*App, error) {
+	logger := zerolog.Ctx(ctx)
+
+	dbCfg, err := config.LoadPostgreSQL()
+	if err != nil {
+		return nil, fmt.Errorf("New ошибка конфигурации бд: %w", err)
+	}
+
+	tlsCfg, err := rpc.LoadTLS()
+	if err != nil {
+		return nil, fmt.Errorf("New ошибка конфигурации gRPC: %w", err)
+	}
+	grpcServer, err := rpc.NewServer(tlsCfg, *logger)
+	if err != nil {
+		return nil, fmt.Errorf("New ошибка создания gRPC сервера: %w", err)
+	}
+
+	db, err := dbwork.NewPostgreSQL(ctx, dbCfg)
+	if err != nil {
+		return nil, fmt.Errorf("New ошибка подключения к бд: %w", err)
+	}
+
+	if err = db.RunMigrations(ctx, ""); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("New ошибка миграций: %w", err)
+	}
+
+	storage, err := cloudstorage.New(ctx, cloudstorage.LoadStorageConfig())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("New ошибка создания хранилища: %w", err)
+	}
+
+	app := &App{
+		log:     logger,
+		db:      db,
+		storage: storage,
+		service: service.NewService(db, storage, service.LoadConfig()),
+		health:  health.New(readyTimeout).Add("database", db.Ready).Add("storage", storage.Ping),
+
+		grpc:     grpcServer,
+		grpcAddr: (&config.Loader{}).StringOr("grpc_addr", ":9082"),
+	}
+	app.server = &http.Server{
+		Addr:    addr,
+		Handler: tracing.Handler("core_service", *logger, app.routes()),
+	}
+	catalogpb.RegisterCatalogServer(app.grpc, rpcserver.New(app.service))
+
+	return app, nil
+}
+
+func (app *App) routes() http.Handler {
+	handler := handlers.NewHandler(app.service)
+
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware(routeTemplate), CORS, traceRoute)
+	router.HandleFunc("/product", handler.CreateProduct).Methods("POST")
+	router.HandleFunc("/product", handler.ReadAllProduct).Methods("GET")
+	router.HandleFunc("/product/{id}", handler.ReadProduct).Methods("GET")
+	router.HandleFunc("/product/{id}", handler.DeleteProduct).Methods("DELETE")
+	router.HandleFunc("/product/change", handler.ChangeCountProduct).Methods("PUT")
+	// После /product/change, иначе "change" подходит под {id}
+	router.HandleFunc("/product/{id}", handler.UpdateProduct).Methods("PUT")
+	router.HandleFunc("/product/{id}/confirm", handler.ConfirmProductImages).Methods("POST")
+	router.HandleFunc("/product/{id}/images/order", handler.ReorderImages).Methods("PUT")
+	router.HandleFunc("/product/{id}/images/{image_id}/primary", handler.SetPrimaryImage).Methods("PUT")
+	router.HandleFunc("/product/{id}/images/{image_id}/alt", handler.SetImageAlt).Methods("PUT")
+	router.HandleFunc(service.ImagesPath+"{key}", handler.Image).Methods("GET", "HEAD")
+	router.Handle(audit.Path, audit.Handler("core_service", app.service.ListAudit)).Methods("GET")
+	router.Handle(openapi.Path, openapi.Handler(api.Spec)).Methods("GET")
+	router.Handle(metrics.Path, metrics.Handler()).Methods("GET")
+	router.Handle(health.LivePath, health.Live()).Methods("GET")
+	router.Handle(health.ReadyPath, app.health.Ready()).Methods("GET")
+
+	// Локальное и in-memory хранилища раздают файлы через сам core_service
+	if storage, ok := app.storage.(http.Handler); ok {
+		router.PathPrefix(cloudstorage.StoragePath).Handler(storage)
+	}
+
+	return router
+}
+
+// traceRoute называет спан запроса по шаблону маршрута.
+func traceRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracing.SetRoute(r, routeTemplate(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTemplate возвращает шаблон найденного маршрута, например
+// /product/{id}.
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, _ := route.GetPathTemplate()
+	return template
+}
+
+// Run запускает фоновые задачи, http и gRPC серверы и блокируется до
+// отмены ctx или падения одного из серверов. После этого серверы перестают
+// принимать соединения и дожидаются уже начатых запросов, затем
+// останавливаются фоновые задачи и закрывается бд.
+func (app *App) Run(ctx context.Context) error {
+	workersCtx, stopWorkers := context.WithCancel(app.log.WithContext(context.Background()))
+	var workers sync.WaitGroup
+
+	run := func(worker func(context.Context, time.Duration), interval time.Duration) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(workersCtx, interval)
+		}()
+	}
+	// Уменьшенные копии изображений создаются в фоне после загрузки оригинала
+	run(app.service.RunImageProcessing, 30*time.Second)
+	run(app.service.RunReconciler, 15*time.Minute)
+	run(app.service.RunStorageCleanup, time.Minute)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		app.log.Info().Str("addr", addr).Msg("Сервер запущен")
+		serverErr <- app.server.ListenAndServe()
+	}()
+	go func() {
+		app.log.Info().Str("addr", app.grpcAddr).Msg("gRPC сервер запущен")
+		serverErr <- rpc.ListenAndServe(app.grpc, app.grpcAddr)
+	}()
+
+	var err error
+	select {
+	case err = <-serverErr:
+		if errors.Is(err, http.ErrServerClosed) || errors.Is(err, grpc.ErrServerStopped) {
+			err = nil
+		}
+	case <-ctx.Done():
+		app.log.Info().Msg("Получен сигнал остановки, завершаем запросы")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if shutdownErr := app.server.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = fmt.Errorf("Run ошибка остановки сервера: %w", shutdownErr)
+	}
+	app.stopGRPC(shutdownCtx)
+
+	stopWorkers()
+	workers.Wait()
+	app.db.Close()
+	app.log.Info().Msg("Сервер остановлен")
+
+	return err
+}
+
+// stopGRPC дожидается начатых вызовов, пока не отменён ctx, затем
+// разрывает оставшиеся соединения.
+func (app *App) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		app.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		app.grpc.Stop()
+	}
+}
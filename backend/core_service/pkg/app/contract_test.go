@@ -0,0 +1,524 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"common/audit"
+	"common/health"
+	"common/openapi/contract"
+	"common/tracing"
+	"core-service/api"
+	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/dbwork"
+	"core-service/pkg/models"
+	"core-service/pkg/service"
+)
+
+// TestContract проходит по всем операциям core_service и сверяет
+// настоящие ответы обработчиков со спецификацией api/openapi.json.
+func TestContract(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.Start()
+	defer server.Close()
+
+	storage, err := cloudstorage.NewMemory(context.Background(), cloudstorage.LocalStorageConfig{BaseURL: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+
+	db := newFakeDB()
+	app := &App{
+		storage: storage,
+		service: service.NewService(db, storage, service.Config{ImagesURL: server.URL}),
+		health:  health.New(time.Second).Add("database", db.Ready).Add("storage", storage.Ping),
+	}
+	server.Config.Handler = app.routes()
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		var reader *bytes.Reader
+		switch body := body.(type) {
+		case nil:
+			reader = bytes.NewReader(nil)
+		case string:
+			reader = bytes.NewReader([]byte(body))
+		default:
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			reader = bytes.NewReader(data)
+		}
+
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		// Так manage_service передаёт, кто выполняет запрос
+		req = req.WithContext(tracing.WithActor(req.Context(), tracing.Actor{ID: "admin-guid", IP: "10.0.0.1"}))
+		rec, err := validator.Do(server.Config.Handler, req)
+		require.NoError(t, err)
+		return rec
+	}
+	problemCode := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		problem := struct {
+			Code string `json:"code"`
+		}{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		return problem.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("GET", "/openapi.json", nil).Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/healthz", nil).Code)
+	rec := do("GET", "/readyz", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"storage":{"status":"ok"`)
+	assert.Equal(t, http.StatusOK, do("GET", "/product", nil).Code)
+
+	// Создание товара
+	rec = do("POST", "/product", "{")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "BAD_REQUEST", problemCode(rec))
+
+	file := pngImage(t)
+	checksum := sha256.Sum256(file)
+	upload := models.ImageUpload{
+		Name:        "phone.png",
+		ContentType: "image/png",
+		Size:        int64(len(file)),
+		Checksum:    base64.StdEncoding.EncodeToString(checksum[:]),
+		Alt:         map[string]string{"ru": "Телефон"},
+	}
+
+	tooLarge := upload
+	tooLarge.Size = 100 << 20
+	rec = do("POST", "/product", models.RequestCreateProduct{Name: "Телефон", Count: 1, Images: []models.ImageUpload{tooLarge}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "INVALID_IMAGE", problemCode(rec))
+
+	rec = do("POST", "/product", models.RequestCreateProduct{
+		Name:   "Телефон",
+		Count:  5,
+		Price:  1000,
+		Images: []models.ImageUpload{upload},
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	created := models.ResponseCreateProduct{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Len(t, created.Uploads, 1)
+	productPath := "/product/" + strconv.Itoa(created.ID)
+	key := created.Uploads[0].FileID
+
+	uploadFile(t, created.Uploads[0], file)
+
+	// До подтверждения изображение нельзя сделать главным
+	pending, err := db.ReadProduct(context.Background(), created.ID)
+	require.NoError(t, err)
+	rec = do("PUT", productPath+"/images/"+strconv.Itoa(pending.Images[0].ID)+"/primary", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "IMAGE_NOT_FOUND", problemCode(rec))
+
+	rec = do("POST", productPath+"/confirm", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), key)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/product/999/confirm", nil).Code)
+
+	// Чтение
+	rec = do("GET", productPath, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	product := models.ResponseReadProduct{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &product))
+	require.Len(t, product.Images, 1)
+	imagePath := productPath + "/images/" + strconv.Itoa(product.Images[0].ID)
+
+	rec = do("GET", "/product/999", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "PRODUCT_NOT_FOUND", problemCode(rec))
+	assert.Equal(t, http.StatusBadRequest, do("GET", "/product/abc", nil).Code)
+
+	rec = do("GET", "/product", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"products"`)
+
+	// Изменение
+	update := models.RequestUpdateProduct{Name: "Смартфон", Description: "Новая модель", Price: 1200}
+	assert.Equal(t, http.StatusOK, do("PUT", productPath, update).Code)
+	rec = do("PUT", productPath, models.RequestUpdateProduct{Price: 1200})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "VALIDATION_FAILED", problemCode(rec))
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/product/999", update).Code)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", productPath, "{").Code)
+
+	// Изображения
+	rec = do("GET", "/images/"+key, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, file, rec.Body.Bytes())
+	assert.Equal(t, http.StatusOK, do("HEAD", "/images/"+key, nil).Code)
+
+	req := httptest.NewRequest("GET", "/images/"+key, nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec, err = validator.Do(server.Config.Handler, req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = do("GET", "/images/missing.png", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "IMAGE_NOT_FOUND", problemCode(rec))
+
+	assert.Equal(t, http.StatusOK, do("PUT", productPath+"/images/order", models.RequestReorderImages{ImageIDs: []int{product.Images[0].ID}}).Code)
+	rec = do("PUT", productPath+"/images/order", models.RequestReorderImages{ImageIDs: []int{999}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "IMAGE_ORDER_MISMATCH", problemCode(rec))
+	rec = do("PUT", "/product/999/images/order", models.RequestReorderImages{ImageIDs: []int{}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "PRODUCT_NOT_FOUND", problemCode(rec))
+
+	assert.Equal(t, http.StatusOK, do("PUT", imagePath+"/primary", nil).Code)
+	assert.Equal(t, http.StatusNotFound, do("PUT", productPath+"/images/999/primary", nil).Code)
+
+	assert.Equal(t, http.StatusOK, do("PUT", imagePath+"/alt", models.RequestImageAlt{Alt: map[string]string{"en": "Phone"}}).Code)
+	rec = do("PUT", imagePath+"/alt", models.RequestImageAlt{Alt: map[string]string{"Русский": "Телефон"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "VALIDATION_FAILED", problemCode(rec))
+	assert.Equal(t, http.StatusNotFound, do("PUT", productPath+"/images/999/alt", models.RequestImageAlt{}).Code)
+
+	// Остатки
+	assert.Equal(t, http.StatusOK, do("PUT", "/product/change", models.RequestChangeCount{ID: created.ID, Count: -1}).Code)
+	rec = do("PUT", "/product/change", models.RequestChangeCount{ID: created.ID, Count: -100})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "INSUFFICIENT_STOCK", problemCode(rec))
+	assert.Equal(t, http.StatusNotFound, do("PUT", "/product/change", models.RequestChangeCount{ID: 999, Count: 1}).Code)
+	assert.Equal(t, http.StatusBadRequest, do("PUT", "/product/change", "[").Code)
+
+	// Удаление
+	assert.Equal(t, http.StatusOK, do("DELETE", productPath, nil).Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", productPath, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do("DELETE", "/product/abc", nil).Code)
+
+	// Журнал аудита
+	rec = do("GET", "/audit?action=product&limit=50", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	log := audit.Result{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &log))
+	actions := make([]string, 0, len(log.Entries))
+	for _, entry := range log.Entries {
+		actions = append(actions, entry.Action)
+		assert.Equal(t, "core_service", entry.Service)
+		assert.Equal(t, "admin-guid", entry.Actor)
+		assert.Equal(t, "10.0.0.1", entry.IP)
+		assert.Equal(t, "product:"+strconv.Itoa(created.ID), entry.Target)
+	}
+	// Неудачные операции в журнал не попадают
+	assert.Equal(t, []string{
+		audit.ActionProductDelete,
+		audit.ActionProductStock,
+		audit.ActionProductImageAlt,
+		audit.ActionProductImagePrimary,
+		audit.ActionProductImagesOrder,
+		audit.ActionProductUpdate,
+		audit.ActionProductImagesConfirm,
+		audit.ActionProductCreate,
+	}, actions)
+	assert.JSONEq(t, `{"count":5}`, string(log.Entries[1].Before))
+	assert.JSONEq(t, `{"count":4}`, string(log.Entries[1].After))
+	assert.Contains(t, string(log.Entries[0].Before), `"name":"Смартфон"`)
+	// Изменения изображений записываются вместе с прежним состоянием
+	for _, entry := range log.Entries[2:5] {
+		assert.NotEmpty(t, entry.Before, entry.Action)
+	}
+	assert.Contains(t, string(log.Entries[2].Before), `"ru":"Телефон"`)
+	assert.Contains(t, string(log.Entries[5].Before), `"name":"Телефон"`)
+	assert.Contains(t, string(log.Entries[5].After), `"name":"Смартфон"`)
+
+	rec = do("GET", "/audit?from=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "VALIDATION_FAILED", problemCode(rec))
+}
+
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	return buf.Bytes()
+}
+
+// uploadFile загружает файл по подписанной ссылке, как это делает клиент.
+func uploadFile(t *testing.T, upload models.S3SImage, file []byte) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, upload.URL, bytes.NewReader(file))
+	require.NoError(t, err)
+	for header, value := range upload.Headers {
+		req.Header.Set(header, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// fakeDB хранит товары в памяти и повторяет ошибки настоящей бд,
+// которые влияют на ответы обработчиков.
+type fakeDB struct {
+	mu       sync.Mutex
+	products map[int]*models.Product
+	nextID   int
+	audit    []audit.Entry
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{products: make(map[int]*models.Product)}
+}
+
+func (db *fakeDB) CreateProduct(ctx context.Context, product models.Product) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.nextID++
+	product.ID = db.nextID
+	for i := range product.Images {
+		db.nextID++
+		product.Images[i].ID = db.nextID
+		product.Images[i].ProductID = product.ID
+		product.Images[i].Status = models.ImageStatusPending
+		product.Images[i].Position = i
+		product.Images[i].IsPrimary = i == 0
+		product.Images[i].CreatedAt = time.Now()
+	}
+	db.products[product.ID] = &product
+	return product.ID, nil
+}
+
+func (db *fakeDB) UpdateProduct(ctx context.Context, pr models.Product) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[pr.ID]
+	if !ok {
+		return dbwork.ErrProductNotFound
+	}
+	product.Name, product.Description, product.Parameters, product.Price = pr.Name, pr.Description, pr.Parameters, pr.Price
+	return nil
+}
+
+func (db *fakeDB) DeleteProduct(ctx context.Context, id int) ([]string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[id]
+	if !ok {
+		return nil, dbwork.ErrProductNotFound
+	}
+	delete(db.products, id)
+
+	keys := make([]string, 0, len(product.Images))
+	for _, image := range product.Images {
+		keys = append(keys, image.Key)
+	}
+	return keys, nil
+}
+
+func (db *fakeDB) ReadProduct(ctx context.Context, id int) (models.Product, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[id]
+	if !ok {
+		return models.Product{}, dbwork.ErrProductNotFound
+	}
+	return copyProduct(product), nil
+}
+
+func (db *fakeDB) ReadListProduct(ctx context.Context) ([]models.Product, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	products := make([]models.Product, 0, len(db.products))
+	for _, product := range db.products {
+		products = append(products, copyProduct(product))
+	}
+	return products, nil
+}
+
+func (db *fakeDB) RunMigrations(ctx context.Context, path string) error { return nil }
+
+func (db *fakeDB) Ready(ctx context.Context) error { return nil }
+
+func (db *fakeDB) ChangeCountProduct(ctx context.Context, id, changeCount int) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[id]
+	if !ok {
+		return 0, dbwork.ErrProductNotFound
+	}
+	if product.Count+changeCount < 0 {
+		return 0, dbwork.ErrInsufficientStock
+	}
+	product.Count += changeCount
+	return product.Count, nil
+}
+
+func (db *fakeDB) ListUnprocessedImages(ctx context.Context, limit int) ([]models.ProductImage, error) {
+	return nil, nil
+}
+
+func (db *fakeDB) SetImageVariants(ctx context.Context, image models.ProductImage) error { return nil }
+
+func (db *fakeDB) ListPendingImages(ctx context.Context, createdBefore time.Time, limit int) ([]models.ProductImage, error) {
+	return nil, nil
+}
+
+func (db *fakeDB) ConfirmImage(ctx context.Context, id int) error {
+	return db.updateImage(id, func(image *models.ProductImage) { image.Status = models.ImageStatusConfirmed })
+}
+
+func (db *fakeDB) RejectImage(ctx context.Context, image models.ProductImage) error {
+	return db.updateImage(image.ID, func(image *models.ProductImage) { image.Status = models.ImageStatusRejected })
+}
+
+func (db *fakeDB) DeleteImage(ctx context.Context, id int) error { return nil }
+
+func (db *fakeDB) ReorderImages(ctx context.Context, productID int, imageIDs []int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[productID]
+	if !ok || len(imageIDs) != len(product.Images) {
+		return dbwork.ErrImageOrderMismatch
+	}
+	for position, id := range imageIDs {
+		image := findImage(product, id)
+		if image == nil {
+			return dbwork.ErrImageOrderMismatch
+		}
+		image.Position = position
+	}
+	return nil
+}
+
+func (db *fakeDB) SetPrimaryImage(ctx context.Context, productID, imageID int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[productID]
+	if !ok {
+		return dbwork.ErrImageNotFound
+	}
+	if image := findImage(product, imageID); image == nil || image.Status != models.ImageStatusConfirmed {
+		return dbwork.ErrImageNotFound
+	}
+	for i := range product.Images {
+		product.Images[i].IsPrimary = product.Images[i].ID == imageID
+	}
+	return nil
+}
+
+func (db *fakeDB) SetImageAlt(ctx context.Context, productID, imageID int, alt map[string]string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	product, ok := db.products[productID]
+	if !ok {
+		return dbwork.ErrImageNotFound
+	}
+	image := findImage(product, imageID)
+	if image == nil {
+		return dbwork.ErrImageNotFound
+	}
+	image.Alt = alt
+	return nil
+}
+
+func (db *fakeDB) ListImageKeys(ctx context.Context) (map[string]struct{}, error) {
+	return map[string]struct{}{}, nil
+}
+
+func (db *fakeDB) ImageConfirmed(ctx context.Context, key string) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, product := range db.products {
+		for _, image := range product.Images {
+			if image.Status != models.ImageStatusConfirmed {
+				continue
+			}
+			if key == image.Key || key == image.ThumbnailKey || key == image.CardKey || key == image.FullKey {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (db *fakeDB) ListCleanupTasks(ctx context.Context, limit int) ([]models.CleanupTask, error) {
+	return nil, nil
+}
+
+func (db *fakeDB) CompleteCleanupTasks(ctx context.Context, ids []int) error { return nil }
+
+func (db *fakeDB) FailCleanupTasks(ctx context.Context, ids []int, reason string) error { return nil }
+
+func (db *fakeDB) RecordAudit(ctx context.Context, entry audit.Entry) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entry.ID = int64(len(db.audit) + 1)
+	db.audit = append(db.audit, entry)
+	return nil
+}
+
+// ListAudit не фильтрует записи: фильтры проверяет тест dbwork.
+func (db *fakeDB) ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entries := make([]audit.Entry, 0, len(db.audit))
+	for i := len(db.audit) - 1; i >= 0; i-- {
+		entries = append(entries, db.audit[i])
+	}
+	return entries, nil
+}
+
+func (db *fakeDB) Close() {}
+
+func (db *fakeDB) updateImage(id int, update func(image *models.ProductImage)) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, product := range db.products {
+		if image := findImage(product, id); image != nil {
+			update(image)
+			return nil
+		}
+	}
+	return dbwork.ErrImageNotFound
+}
+
+func findImage(product *models.Product, id int) *models.ProductImage {
+	for i := range product.Images {
+		if product.Images[i].ID == id {
+			return &product.Images[i]
+		}
+	}
+	return nil
+}
+
+func copyProduct(product *models.Product) models.Product {
+	copied := *product
+	copied.Images = append([]models.ProductImage(nil), product.Images...)
+	return copied
+}
@@ -0,0 +1,42 @@
+package app
+
+import "net/http"
+
+func CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Разрешаем конкретные origin вместо *
+		allowedOrigins := []string{
+			"http://manage_service:8080",
+		}
+
+		origin := r.Header.Get("Origin")
+		allowed := false
+
+		for _, o := range allowedOrigins {
+			if o == origin {
+				allowed = true
+				break
+			}
+		}
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().
+			Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, x-amz-acl, x-amz-meta-*")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, ETag, X-Request-ID")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
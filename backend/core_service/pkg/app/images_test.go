@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"common/health"
+	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/models"
+	"core-service/pkg/service"
+)
+
+// TestImage проверяет, что по постоянному адресу отдаются только
+// подтверждённые изображения и их варианты.
+func TestImage(t *testing.T) {
+	server := httptest.NewUnstartedServer(nil)
+	server.Start()
+	defer server.Close()
+
+	ctx := context.Background()
+	storage, err := cloudstorage.NewMemory(ctx, cloudstorage.LocalStorageConfig{BaseURL: server.URL, Secret: "secret"})
+	require.NoError(t, err)
+
+	db := newFakeDB()
+	app := &App{
+		storage: storage,
+		service: service.NewService(db, storage, service.Config{ImagesURL: server.URL}),
+		health:  health.New(time.Second).Add("database", db.Ready).Add("storage", storage.Ping),
+	}
+	server.Config.Handler = app.routes()
+
+	// Все файлы лежат в хранилище, но подтверждено только первое изображение
+	file := pngImage(t)
+	checksum := sha256.Sum256(file)
+	keys := make([]string, 4)
+	for i := range keys {
+		upload, err := storage.UploadURL(models.ImageUpload{
+			Name:        "phone.png",
+			ContentType: "image/png",
+			Size:        int64(len(file)),
+			Checksum:    base64.StdEncoding.EncodeToString(checksum[:]),
+		})
+		require.NoError(t, err)
+		uploadFile(t, upload, file)
+		keys[i] = upload.FileID
+	}
+	confirmed, thumbnail, pending, rejected := keys[0], keys[1], keys[2], keys[3]
+
+	id, err := db.CreateProduct(ctx, models.Product{Name: "Телефон", Images: []models.ProductImage{
+		{Key: confirmed, ThumbnailKey: thumbnail},
+		{Key: pending},
+		{Key: rejected},
+	}})
+	require.NoError(t, err)
+	product, err := db.ReadProduct(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, db.ConfirmImage(ctx, product.Images[0].ID))
+	require.NoError(t, db.RejectImage(ctx, product.Images[2]))
+
+	do := func(method, key string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/images/"+key, nil)
+		for header, value := range headers {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		server.Config.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, key := range []string{confirmed, thumbnail} {
+		rec := do("GET", key, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, key)
+		assert.Equal(t, file, rec.Body.Bytes())
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, `"`+key+`"`, rec.Header().Get("ETag"))
+	}
+
+	rec := do("HEAD", confirmed, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.Bytes())
+
+	rec = do("GET", confirmed, map[string]string{"If-None-Match": `"` + confirmed + `"`})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+
+	// Непроверенные загрузки не отдаются и не кешируются, даже если клиент
+	// знает ETag
+	for _, key := range []string{pending, rejected, "missing.png"} {
+		for _, headers := range []map[string]string{nil, {"If-None-Match": `"` + key + `"`}} {
+			rec = do("GET", key, headers)
+			assert.Equal(t, http.StatusNotFound, rec.Code, key)
+			assert.Contains(t, rec.Body.String(), `"IMAGE_NOT_FOUND"`)
+			assert.Empty(t, rec.Header().Get("Cache-Control"))
+			assert.Empty(t, rec.Header().Get("ETag"))
+		}
+	}
+	assert.Equal(t, http.StatusNotFound, do("HEAD", pending, nil).Code)
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"common/proto/catalogpb"
+	"common/response"
+	"common/rpc"
+	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/rpcserver"
+	"core-service/pkg/service"
+)
+
+// TestCatalogRPC проверяет внутренний gRPC API каталога поверх той же
+// бизнес-логики, что и http обработчики.
+func TestCatalogRPC(t *testing.T) {
+	storage, err := cloudstorage.NewMemory(context.Background(), cloudstorage.LocalStorageConfig{BaseURL: "http://storage", Secret: "secret"})
+	require.NoError(t, err)
+
+	server, err := rpc.NewServer(rpc.TLS{}, zerolog.Nop())
+	require.NoError(t, err)
+	catalogpb.RegisterCatalogServer(server, rpcserver.New(service.NewService(newFakeDB(), storage, service.Config{})))
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///catalog",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	catalog := catalogpb.NewCatalogClient(conn)
+	ctx := context.Background()
+	problemCode := func(err error) response.ErrorCode {
+		t.Helper()
+		problem, ok := rpc.Problem(err)
+		require.True(t, ok, "ожидалась ошибка gRPC, получено %v", err)
+		return problem.Code
+	}
+
+	_, err = catalog.CreateProduct(ctx, &catalogpb.CreateProductRequest{
+		Name:   "Телефон",
+		Images: []*catalogpb.ImageUpload{{Name: "phone.txt", ContentType: "text/plain", Size: 1}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, response.CodeInvalidImage, problemCode(err))
+
+	created, err := catalog.CreateProduct(ctx, &catalogpb.CreateProductRequest{Name: "Телефон", Count: 5, Price: 1000})
+	require.NoError(t, err)
+
+	product, err := catalog.GetProduct(ctx, &catalogpb.GetProductRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "Телефон", product.GetName())
+	assert.Equal(t, int32(5), product.GetCount())
+
+	_, err = catalog.GetProduct(ctx, &catalogpb.GetProductRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, response.CodeProductNotFound, problemCode(err))
+
+	list, err := catalog.ListProducts(ctx, &catalogpb.ListProductsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetProducts(), 1)
+
+	_, err = catalog.ChangeCount(ctx, &catalogpb.ChangeCountRequest{Id: created.GetId(), Count: -1})
+	require.NoError(t, err)
+	_, err = catalog.ChangeCount(ctx, &catalogpb.ChangeCountRequest{Id: created.GetId(), Count: -100})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, response.CodeInsufficientStock, problemCode(err))
+	problem, _ := rpc.Problem(err)
+	assert.Equal(t, http.StatusConflict, problem.Status)
+
+	_, err = catalog.DeleteProduct(ctx, &catalogpb.DeleteProductRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	_, err = catalog.DeleteProduct(ctx, &catalogpb.DeleteProductRequest{Id: created.GetId()})
+	assert.Equal(t, response.CodeProductNotFound, problemCode(err))
+}
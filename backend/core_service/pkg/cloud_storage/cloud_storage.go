@@ -3,11 +3,12 @@ package cloudstorage
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,20 +17,39 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
 	"core-service/pkg/models"
 )
 
 type CloudStorage interface {
-	UploadURL(filename string) (models.S3SImage, error)
+	UploadURL(upload models.ImageUpload) (models.S3SImage, error)
 	DownloadURL(key string) (models.S3SImage, error)
 	DeleteURL(key string) (models.S3SImage, error)
+	// HeadObject возвращает ErrObjectNotFound, если объекта нет.
+	HeadObject(ctx context.Context, key string) (ObjectInfo, error)
+	ListObjects(ctx context.Context) ([]ObjectInfo, error)
+	// DeleteObjects удаляет объекты пачкой и возвращает ключи, которые
+	// удалить не удалось. Отсутствующие объекты ошибкой не считаются.
+	DeleteObjects(ctx context.Context, keys []string) ([]string, error)
+	// Ping проверяет, что хранилище доступно.
+	Ping(ctx context.Context) error
 }
 
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// maxDeleteObjects - ограничение S3 на число ключей в одном DeleteObjects.
+const maxDeleteObjects = 1000
+
 type s3Storage struct {
 	*s3.Client
 	bucket string
 	folder string
+	log    *zerolog.Logger
 }
 
 type S3StorageConfig struct {
@@ -41,6 +61,46 @@ type S3StorageConfig struct {
 	Folder    string
 }
 
+// Config выбирает реализацию хранилища: "s3" (по умолчанию),
+// "local" - файлы на диске или "memory" - в памяти процесса.
+type Config struct {
+	Backend string
+	S3      S3StorageConfig
+	Local   LocalStorageConfig
+}
+
+func LoadStorageConfig() Config {
+	s3Config := LoadConfig()
+	return Config{
+		Backend: os.Getenv("storage_backend"),
+		S3:      s3Config,
+		Local: LocalStorageConfig{
+			Path:    os.Getenv("storage_path"),
+			BaseURL: os.Getenv("storage_url"),
+			Secret:  os.Getenv("storage_secret"),
+			Folder:  s3Config.Folder,
+		},
+	}
+}
+
+// New создаёт хранилище cfg.Backend. Хранилище пишет в логгер из ctx.
+func New(ctx context.Context, cfg Config) (CloudStorage, error) {
+	switch cfg.Backend {
+	case "", "s3":
+		return NewS3S(ctx, cfg.S3)
+	case "local":
+		return NewLocal(ctx, cfg.Local)
+	case "memory":
+		return NewMemory(ctx, cfg.Local)
+	}
+	return nil, fmt.Errorf("New неизвестное хранилище: %s", cfg.Backend)
+}
+
+// String описывает хранилище для лога без ключей доступа.
+func (cfg S3StorageConfig) String() string {
+	return fmt.Sprintf("%s/%s/%s (%s)", strings.TrimRight(cfg.Endpoint, "/"), cfg.Bucket, cfg.Folder, cfg.Region)
+}
+
 func LoadConfig() S3StorageConfig {
 	return S3StorageConfig{
 		AccessKey: os.Getenv("access_key"),
@@ -52,8 +112,9 @@ func LoadConfig() S3StorageConfig {
 	}
 }
 
-func NewS3S(cfg S3StorageConfig) (CloudStorage, error) {
-	log.Println("S3S: ", cfg)
+func NewS3S(ctx context.Context, cfg S3StorageConfig) (CloudStorage, error) {
+	logger := zerolog.Ctx(ctx)
+	logger.Info().Stringer("storage", cfg).Msg("Подключение к S3")
 
 	httpClient := &http.Client{
 		Transport: &http.Transport{
@@ -65,7 +126,7 @@ func NewS3S(cfg S3StorageConfig) (CloudStorage, error) {
 	}
 
 	clientCfg, err := config.LoadDefaultConfig(
-		context.TODO(),
+		ctx,
 		config.WithRegion(cfg.Region),
 		config.WithCredentialsProvider(
 			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
@@ -81,7 +142,7 @@ func NewS3S(cfg S3StorageConfig) (CloudStorage, error) {
 		o.BaseEndpoint = aws.String(cfg.Endpoint)
 	})
 
-	_, err = clientS3S.PutBucketCors(context.TODO(), &s3.PutBucketCorsInput{
+	_, err = clientS3S.PutBucketCors(ctx, &s3.PutBucketCorsInput{
 		Bucket: aws.String(cfg.Bucket),
 		CORSConfiguration: &types.CORSConfiguration{
 			CORSRules: []types.CORSRule{
@@ -105,32 +166,36 @@ func NewS3S(cfg S3StorageConfig) (CloudStorage, error) {
 	})
 
 	if err != nil {
-		log.Println("Ошибка настройки CORS S3: ", err)
+		logger.Error().Err(err).Msg("Ошибка настройки CORS S3")
 	} else {
-		log.Println("CORS S3 настроен успешно")
+		logger.Info().Msg("CORS S3 настроен успешно")
 	}
 
 	s3s := &s3Storage{
 		Client: clientS3S,
 		bucket: cfg.Bucket,
 		folder: cfg.Folder,
+		log:    logger,
 	}
 	return s3s, nil
 }
 
-func (s3s *s3Storage) UploadURL(filename string) (models.S3SImage, error) {
+// UploadURL подписывает тип, размер и контрольную сумму файла, поэтому
+// S3 отклонит загрузку любого другого содержимого по этой ссылке.
+func (s3s *s3Storage) UploadURL(upload models.ImageUpload) (models.S3SImage, error) {
 	presignClient := s3.NewPresignClient(s3s.Client)
 
-	mime := filepath.Ext(filename)
-	fileID := uuid.New().String() + mime
+	fileID := uuid.New().String() + filepath.Ext(upload.Name)
 	image := models.S3SImage{FileID: fileID}
 
 	expires := 10 * time.Minute
 
 	presignResult, err := presignClient.PresignPutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket:      aws.String(s3s.bucket),
-		Key:         aws.String(s3s.folder + "/" + fileID),
-		ContentType: aws.String(mime),
+		Bucket:         aws.String(s3s.bucket),
+		Key:            aws.String(s3s.folder + "/" + fileID),
+		ContentType:    aws.String(upload.ContentType),
+		ContentLength:  aws.Int64(upload.Size),
+		ChecksumSHA256: aws.String(upload.Checksum),
 	}, s3.WithPresignExpires(expires))
 
 	if err != nil {
@@ -138,6 +203,7 @@ func (s3s *s3Storage) UploadURL(filename string) (models.S3SImage, error) {
 	}
 
 	image.URL = presignResult.URL
+	image.Headers = map[string]string{"Content-Type": upload.ContentType}
 
 	return image, nil
 
@@ -188,3 +254,87 @@ func (s3s *s3Storage) DeleteURL(key string) (models.S3SImage, error) {
 	return image, nil
 
 }
+
+func (s3s *s3Storage) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	info := ObjectInfo{Key: key}
+
+	head, err := s3s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    aws.String(s3s.folder + "/" + key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return info, ErrObjectNotFound
+		}
+		return info, fmt.Errorf("HeadObject ошибка запроса: %w", err)
+	}
+
+	info.Size = aws.ToInt64(head.ContentLength)
+	info.LastModified = aws.ToTime(head.LastModified)
+	return info, nil
+}
+
+// Ping проверяет, что бакет существует и доступен с ключами сервиса.
+func (s3s *s3Storage) Ping(ctx context.Context) error {
+	_, err := s3s.Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s3s.bucket)})
+	if err != nil {
+		return fmt.Errorf("Ping ошибка запроса: %w", err)
+	}
+	return nil
+}
+
+func (s3s *s3Storage) ListObjects(ctx context.Context) ([]ObjectInfo, error) {
+	prefix := s3s.folder + "/"
+	objects := make([]ObjectInfo, 0)
+
+	paginator := s3.NewListObjectsV2Paginator(s3s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s3s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return objects, fmt.Errorf("ListObjects ошибка запроса страницы: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          strings.TrimPrefix(aws.ToString(object.Key), prefix),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (s3s *s3Storage) DeleteObjects(ctx context.Context, keys []string) ([]string, error) {
+	failed := make([]string, 0)
+
+	for start := 0; start < len(keys); start += maxDeleteObjects {
+		batch := keys[start:min(start+maxDeleteObjects, len(keys))]
+
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(s3s.folder + "/" + key)})
+		}
+
+		result, err := s3s.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s3s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return append(failed, keys[start:]...), fmt.Errorf("DeleteObjects ошибка запроса: %w", err)
+		}
+
+		for _, deleteErr := range result.Errors {
+			key := strings.TrimPrefix(aws.ToString(deleteErr.Key), s3s.folder+"/")
+			s3s.log.Error().Str("key", key).Str("reason", aws.ToString(deleteErr.Message)).Msg("Ошибка удаления объекта")
+			failed = append(failed, key)
+		}
+	}
+
+	return failed, nil
+}
@@ -2,14 +2,20 @@ package cloudstorage_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/models"
 )
 
 var cfg cloudstorage.S3StorageConfig = cloudstorage.S3StorageConfig{
@@ -27,7 +33,7 @@ type test struct {
 }
 
 func TestCloudStorage(t *testing.T) {
-	s3s, err := cloudstorage.NewS3S(cfg)
+	s3s, err := cloudstorage.NewS3S(context.Background(), cfg)
 	assert.NoError(t, err)
 
 	var tests = []test{
@@ -42,10 +48,71 @@ func TestCloudStorage(t *testing.T) {
 
 }
 
+func TestMemoryStorage(t *testing.T) {
+	testPresignedStorage(t, "memory", cloudstorage.LocalStorageConfig{Folder: "tests"})
+}
+
+func TestLocalStorage(t *testing.T) {
+	testPresignedStorage(t, "local", cloudstorage.LocalStorageConfig{Path: t.TempDir(), Folder: "tests"})
+}
+
+func testPresignedStorage(t *testing.T, backend string, localCfg cloudstorage.LocalStorageConfig) {
+	var handler http.Handler
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(rw, r)
+	}))
+	defer server.Close()
+
+	localCfg.BaseURL = server.URL
+	storage, err := cloudstorage.New(context.Background(), cloudstorage.Config{Backend: backend, Local: localCfg})
+	assert.NoError(t, err)
+	handler = storage.(http.Handler)
+
+	var tests = []test{
+		{"test1.txt", "lolsasdfadwdqwwd"},
+		{"test2.txt", "123fdfqsd"},
+		{"test3.txt", ">>/"},
+	}
+
+	fileId := UploadURL(storage, tests, t)
+	DownloadURL(storage, tests, fileId, t)
+	HeadAndListObjects(storage, tests, fileId, t)
+	DeleteURL(storage, tests, fileId, t)
+
+	_, err = storage.HeadObject(context.Background(), fileId[0])
+	assert.Equal(t, cloudstorage.ErrObjectNotFound, err)
+
+	DeleteObjects(storage, tests, t)
+	UploadConstraints(storage, t)
+
+	image, err := storage.DownloadURL(fileId[0])
+	assert.NoError(t, err)
+
+	resp, err := http.Get(image.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(image.URL + "0")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func upload(test test) models.ImageUpload {
+	sum := sha256.Sum256([]byte(test.data))
+	return models.ImageUpload{
+		Name:        test.fileName,
+		ContentType: "text/plain",
+		Size:        int64(len(test.data)),
+		Checksum:    base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
 func UploadURL(s3s cloudstorage.CloudStorage, tests []test, t *testing.T) []string {
 	fileId := make([]string, 0, len(tests))
 	for _, test := range tests {
-		image, err := s3s.UploadURL(test.fileName)
+		image, err := s3s.UploadURL(upload(test))
 		assert.NoError(t, err)
 		fileId = append(fileId, image.FileID)
 
@@ -67,6 +134,9 @@ func UploadURL(s3s cloudstorage.CloudStorage, tests []test, t *testing.T) []stri
 			bytes.NewReader(fileData),
 		)
 		assert.NoError(t, err)
+		for header, value := range image.Headers {
+			req.Header.Set(header, value)
+		}
 
 		resp, err := client.Do(req)
 		assert.NoError(t, err)
@@ -121,3 +191,63 @@ func DeleteURL(s3s cloudstorage.CloudStorage, tests []test, fileId []string, t *
 
 	}
 }
+
+func HeadAndListObjects(s3s cloudstorage.CloudStorage, tests []test, fileId []string, t *testing.T) {
+	for i, test := range tests {
+		info, err := s3s.HeadObject(context.Background(), fileId[i])
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(test.data)), info.Size)
+	}
+
+	objects, err := s3s.ListObjects(context.Background())
+	assert.NoError(t, err)
+
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	assert.ElementsMatch(t, fileId, keys)
+}
+
+func DeleteObjects(s3s cloudstorage.CloudStorage, tests []test, t *testing.T) {
+	fileId := UploadURL(s3s, tests, t)
+
+	// Отменённое удаление возвращает все ключи как неудалённые
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failed, err := s3s.DeleteObjects(ctx, fileId)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ElementsMatch(t, fileId, failed)
+
+	// Отсутствующий ключ не должен считаться ошибкой
+	failed, err = s3s.DeleteObjects(context.Background(), append(fileId, "missing.txt"))
+	assert.NoError(t, err)
+	assert.Empty(t, failed)
+
+	objects, err := s3s.ListObjects(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func UploadConstraints(s3s cloudstorage.CloudStorage, t *testing.T) {
+	image, err := s3s.UploadURL(upload(test{"test.txt", "ожидаемые данные"}))
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		contentType string
+		data        string
+	}{
+		{"text/plain", "подменённые данные"},
+		{"text/plain", "ожидаемые данныеX"},
+		{"text/html", "ожидаемые данные"},
+	} {
+		req, err := http.NewRequest("PUT", image.URL, strings.NewReader(tc.data))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", tc.contentType)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
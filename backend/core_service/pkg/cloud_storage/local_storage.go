@@ -0,0 +1,435 @@
+package cloudstorage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"core-service/pkg/models"
+)
+
+const (
+	// StoragePath - префикс, по которому core_service сам раздаёт файлы
+	// локального и in-memory хранилищ.
+	StoragePath = "/storage/"
+
+	maxObjectSize = 32 << 20
+)
+
+var ErrObjectNotFound = errors.New("Объект не найден")
+
+type LocalStorageConfig struct {
+	Path    string
+	BaseURL string
+	Secret  string
+	Folder  string
+}
+
+type blobStore interface {
+	put(key string, data []byte) error
+	get(key string) ([]byte, error)
+	delete(key string) error
+	stat(key string) (ObjectInfo, error)
+	list() ([]ObjectInfo, error)
+	ping() error
+}
+
+// presignedStorage повторяет поведение S3: выдаёт подписанные ссылки
+// с ограниченным сроком действия и сам обслуживает запросы по ним.
+type presignedStorage struct {
+	blobs   blobStore
+	baseURL string
+	secret  []byte
+	folder  string
+	log     *zerolog.Logger
+}
+
+func NewLocal(ctx context.Context, cfg LocalStorageConfig) (CloudStorage, error) {
+	if cfg.Path == "" {
+		cfg.Path = "storage"
+	}
+
+	root := filepath.Join(cfg.Path, cfg.Folder)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("NewLocal ошибка создания каталога: %w", err)
+	}
+
+	return newPresignedStorage(ctx, &diskBlobs{root: root}, cfg)
+}
+
+func NewMemory(ctx context.Context, cfg LocalStorageConfig) (CloudStorage, error) {
+	return newPresignedStorage(ctx, &memoryBlobs{objects: make(map[string]memoryObject)}, cfg)
+}
+
+func newPresignedStorage(ctx context.Context, blobs blobStore, cfg LocalStorageConfig) (*presignedStorage, error) {
+	logger := zerolog.Ctx(ctx)
+
+	secret := []byte(cfg.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("newPresignedStorage ошибка генерации секрета: %w", err)
+		}
+		logger.Warn().Msg("Секрет для подписи ссылок хранилища не задан, сгенерирован случайный")
+	}
+
+	return &presignedStorage{
+		blobs:   blobs,
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		secret:  secret,
+		folder:  cfg.Folder,
+		log:     logger,
+	}, nil
+}
+
+func (ps *presignedStorage) UploadURL(upload models.ImageUpload) (models.S3SImage, error) {
+	fileID := uuid.New().String() + filepath.Ext(upload.Name)
+
+	constraints := url.Values{}
+	constraints.Set("content_type", upload.ContentType)
+	constraints.Set("size", strconv.FormatInt(upload.Size, 10))
+	constraints.Set("checksum", upload.Checksum)
+
+	image := ps.presign(http.MethodPut, fileID, 10*time.Minute, constraints)
+	image.Headers = map[string]string{"Content-Type": upload.ContentType}
+	return image, nil
+}
+
+func (ps *presignedStorage) DownloadURL(key string) (models.S3SImage, error) {
+	return ps.presign(http.MethodGet, key, 30*time.Minute, nil), nil
+}
+
+func (ps *presignedStorage) DeleteURL(key string) (models.S3SImage, error) {
+	return ps.presign(http.MethodDelete, key, 15*time.Minute, nil), nil
+}
+
+func (ps *presignedStorage) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	if !validKey(key) {
+		return ObjectInfo{Key: key}, ErrObjectNotFound
+	}
+	return ps.blobs.stat(key)
+}
+
+func (ps *presignedStorage) ListObjects(ctx context.Context) ([]ObjectInfo, error) {
+	return ps.blobs.list()
+}
+
+func (ps *presignedStorage) DeleteObjects(ctx context.Context, keys []string) ([]string, error) {
+	failed := make([]string, 0)
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return append(failed, keys[i:]...), fmt.Errorf("DeleteObjects прерван: %w", err)
+		}
+		if !validKey(key) {
+			continue
+		}
+		if err := ps.blobs.delete(key); err != nil && err != ErrObjectNotFound {
+			ps.log.Error().Err(err).Str("key", key).Msg("Ошибка удаления объекта")
+			failed = append(failed, key)
+		}
+	}
+	return failed, nil
+}
+
+// Ping проверяет, что каталог с объектами доступен.
+func (ps *presignedStorage) Ping(ctx context.Context) error {
+	return ps.blobs.ping()
+}
+
+// presign подписывает метод, ключ, срок действия и ограничения на
+// загружаемый файл, если они заданы.
+func (ps *presignedStorage) presign(method, key string, expires time.Duration, constraints url.Values) models.S3SImage {
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+
+	query := url.Values{}
+	for name, values := range constraints {
+		query[name] = values
+	}
+	query.Set("method", method)
+	query.Set("expires", expiresAt)
+	query.Set("signature", ps.sign(method, key, expiresAt, constraints.Encode()))
+
+	return models.S3SImage{
+		FileID: key,
+		URL:    ps.baseURL + StoragePath + url.PathEscape(key) + "?" + query.Encode(),
+	}
+}
+
+func (ps *presignedStorage) sign(method, key, expiresAt, constraints string) string {
+	mac := hmac.New(sha256.New, ps.secret)
+	mac.Write([]byte(method + "\n" + ps.folder + "/" + key + "\n" + expiresAt + "\n" + constraints))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ps *presignedStorage) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, StoragePath)
+	if !validKey(key) {
+		http.Error(rw, "Неправильный ключ объекта", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	method := query.Get("method")
+	expiresAt := query.Get("expires")
+
+	requestMethod := r.Method
+	if requestMethod == http.MethodHead {
+		requestMethod = http.MethodGet
+	}
+
+	constraints := url.Values{}
+	for _, name := range []string{"content_type", "size", "checksum"} {
+		if query.Has(name) {
+			constraints.Set(name, query.Get(name))
+		}
+	}
+
+	expires, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil || method != requestMethod ||
+		!hmac.Equal([]byte(query.Get("signature")), []byte(ps.sign(method, key, expiresAt, constraints.Encode()))) {
+		http.Error(rw, "Неправильная подпись ссылки", http.StatusForbidden)
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		http.Error(rw, "Срок действия ссылки истёк", http.StatusForbidden)
+		return
+	}
+
+	switch method {
+	case http.MethodPut:
+		data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxObjectSize))
+		if err != nil {
+			http.Error(rw, "Ошибка чтения файла", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err = checkConstraints(constraints, r.Header.Get("Content-Type"), data); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = ps.blobs.put(key, data); err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Ошибка сохранения объекта")
+			http.Error(rw, "Ошибка сохранения файла", http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+
+	case http.MethodGet:
+		data, err := ps.blobs.get(key)
+		if err == ErrObjectNotFound {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Ошибка чтения объекта")
+			http.Error(rw, "Ошибка чтения файла", http.StatusInternalServerError)
+			return
+		}
+		if contentType := mime.TypeByExtension(filepath.Ext(key)); contentType != "" {
+			rw.Header().Set("Content-Type", contentType)
+		}
+		rw.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		rw.Write(data)
+
+	case http.MethodDelete:
+		if err := ps.blobs.delete(key); err != nil && err != ErrObjectNotFound {
+			zerolog.Ctx(r.Context()).Error().Err(err).Msg("Ошибка удаления объекта")
+			http.Error(rw, "Ошибка удаления файла", http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(rw, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkConstraints повторяет проверки, которые S3 выполняет для
+// подписанных Content-Type, Content-Length и x-amz-checksum-sha256.
+func checkConstraints(constraints url.Values, contentType string, data []byte) error {
+	if constraints.Has("content_type") && constraints.Get("content_type") != contentType {
+		return errors.New("Content-Type не совпадает с подписанным")
+	}
+	if constraints.Has("size") && constraints.Get("size") != strconv.Itoa(len(data)) {
+		return errors.New("Размер файла не совпадает с подписанным")
+	}
+	if constraints.Has("checksum") {
+		sum := sha256.Sum256(data)
+		if constraints.Get("checksum") != base64.StdEncoding.EncodeToString(sum[:]) {
+			return errors.New("Контрольная сумма файла не совпадает с подписанной")
+		}
+	}
+	return nil
+}
+
+// validKey не даёт выйти за пределы каталога хранилища через ключ.
+func validKey(key string) bool {
+	return key != "" && key != "." && key != ".." &&
+		!strings.ContainsAny(key, `/\`)
+}
+
+type diskBlobs struct {
+	root string
+}
+
+func (d *diskBlobs) put(key string, data []byte) error {
+	tmp, err := os.CreateTemp(d.root, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("diskBlobs ошибка создания файла: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("diskBlobs ошибка записи файла: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("diskBlobs ошибка закрытия файла: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(d.root, key))
+}
+
+func (d *diskBlobs) get(key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(d.root, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (d *diskBlobs) delete(key string) error {
+	err := os.Remove(filepath.Join(d.root, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func (d *diskBlobs) stat(key string) (ObjectInfo, error) {
+	info, err := os.Stat(filepath.Join(d.root, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{Key: key}, ErrObjectNotFound
+	}
+	if err != nil {
+		return ObjectInfo{Key: key}, err
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+func (d *diskBlobs) list() ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		return nil, fmt.Errorf("diskBlobs ошибка чтения каталога: %w", err)
+	}
+
+	objects := make([]ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Временные файлы незавершённых загрузок объектами не считаются
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, ObjectInfo{Key: entry.Name(), Size: info.Size(), LastModified: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// ping проверяет, что каталог хранилища на месте: том мог быть
+// отключён после запуска.
+func (d *diskBlobs) ping() error {
+	info, err := os.Stat(d.root)
+	if err != nil {
+		return fmt.Errorf("diskBlobs ошибка доступа к каталогу: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("diskBlobs %s не каталог", d.root)
+	}
+	return nil
+}
+
+type memoryObject struct {
+	data         []byte
+	lastModified time.Time
+}
+
+type memoryBlobs struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func (m *memoryBlobs) put(key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, lastModified: time.Now()}
+	return nil
+}
+
+func (m *memoryBlobs) get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return object.data, nil
+}
+
+func (m *memoryBlobs) delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		return ErrObjectNotFound
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memoryBlobs) stat(key string) (ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return ObjectInfo{Key: key}, ErrObjectNotFound
+	}
+	return ObjectInfo{Key: key, Size: int64(len(object.data)), LastModified: object.lastModified}, nil
+}
+
+func (m *memoryBlobs) list() ([]ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objects := make([]ObjectInfo, 0, len(m.objects))
+	for key, object := range m.objects {
+		objects = append(objects, ObjectInfo{Key: key, Size: int64(len(object.data)), LastModified: object.lastModified})
+	}
+	return objects, nil
+}
+
+func (m *memoryBlobs) ping() error {
+	return nil
+}
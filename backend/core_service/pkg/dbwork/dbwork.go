@@ -1,270 +1,699 @@
 package dbwork
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-
-	"github.com/golang-migrate/migrate/v4"
-	postgre "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
-
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/rs/zerolog"
+
+	"common/audit"
+	"common/config"
+	"common/database"
 	"core-service/pkg/models"
 )
 
+var (
+	ErrProductNotFound    = errors.New("Товар не найден")
+	ErrInsufficientStock  = errors.New("Недостаточно товара на складе")
+	ErrImageNotFound      = errors.New("Изображение не найдено")
+	ErrImageOrderMismatch = errors.New("Порядок должен содержать все загруженные изображения товара ровно по одному разу")
+)
+
+// checkViolation - код ошибки PostgreSQL при нарушении ограничения CHECK.
+const checkViolation = "23514"
+
 type DataBase interface {
-	CreateProduct(pr models.Product) (int, error)
-	UpdateProduct()
-	DeleteProduct(id int) ([]string, error)
-	ReadProduct(id int) (models.Product, error)
-	ReadListProduct() ([]models.Product, error)
-	RunMigrations(path string) error
-	ChangeCountProduct(id, changeCount int) error
+	CreateProduct(ctx context.Context, pr models.Product) (int, error)
+	// UpdateProduct заменяет название, описание, параметры и цену товара.
+	// Остаток и изображения меняются отдельно.
+	UpdateProduct(ctx context.Context, pr models.Product) error
+	DeleteProduct(ctx context.Context, id int) ([]string, error)
+	ReadProduct(ctx context.Context, id int) (models.Product, error)
+	ReadListProduct(ctx context.Context) ([]models.Product, error)
+	RunMigrations(ctx context.Context, path string) error
+	// Ready проверяет, что бд доступна и на ней применены все миграции.
+	Ready(ctx context.Context) error
+	// ChangeCountProduct меняет остаток на changeCount и возвращает новый
+	ChangeCountProduct(ctx context.Context, id, changeCount int) (int, error)
+	ListUnprocessedImages(ctx context.Context, limit int) ([]models.ProductImage, error)
+	SetImageVariants(ctx context.Context, image models.ProductImage) error
+	ListPendingImages(ctx context.Context, createdBefore time.Time, limit int) ([]models.ProductImage, error)
+	ConfirmImage(ctx context.Context, id int) error
+	RejectImage(ctx context.Context, image models.ProductImage) error
+	DeleteImage(ctx context.Context, id int) error
+	ReorderImages(ctx context.Context, productID int, imageIDs []int) error
+	SetPrimaryImage(ctx context.Context, productID, imageID int) error
+	SetImageAlt(ctx context.Context, productID, imageID int, alt map[string]string) error
+	ListImageKeys(ctx context.Context) (map[string]struct{}, error)
+	// ImageConfirmed проверяет, что key - ключ подтверждённого изображения
+	// или одного из его вариантов.
+	ImageConfirmed(ctx context.Context, key string) (bool, error)
+	ListCleanupTasks(ctx context.Context, limit int) ([]models.CleanupTask, error)
+	CompleteCleanupTasks(ctx context.Context, ids []int) error
+	FailCleanupTasks(ctx context.Context, ids []int, reason string) error
+	RecordAudit(ctx context.Context, entry audit.Entry) error
+	ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error)
 	Close()
 }
 
+// querier - общее у пула и транзакции, чтобы чтение изображений
+// работало в обоих случаях.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
 type postgreSQL struct {
-	*sql.DB
+	pool *pgxpool.Pool
+	log  *zerolog.Logger
+	// migrations - каталог миграций, по которому Ready сверяет версию схемы
+	migrations string
 }
 
-type PostgreSQLConfig struct {
-	User     string
-	Password string
-	Host     string
-	Port     int
-	DBName   string
-	SSLMode  string
+func (postgres *postgreSQL) Close() {
+	if postgres.pool != nil {
+		postgres.pool.Close()
+		postgres.log.Info().Msg("Соединение с базой данных закрыто")
+	}
 }
 
-func LoadPSQLConfig() PostgreSQLConfig {
-	port, err := strconv.Atoi(os.Getenv("port"))
+func NewPostgreSQL(ctx context.Context, cfg config.PostgreSQL) (DataBase, error) {
+	pool, err := database.Connect(ctx, cfg)
 	if err != nil {
-		fmt.Println(port)
-		log.Fatal(err)
-	}
-	return PostgreSQLConfig{
-		User:     os.Getenv("user"),
-		Password: os.Getenv("password"),
-		Host:     os.Getenv("host"),
-		Port:     port,
-		DBName:   os.Getenv("dbname"),
-		SSLMode:  os.Getenv("sslmode"),
+		return nil, fmt.Errorf("NewPostgreSQL: %w", err)
 	}
-}
 
-func (postgres *postgreSQL) Close() {
-	postgres.DB.Close()
+	return &postgreSQL{pool: pool, log: zerolog.Ctx(ctx)}, nil
 }
 
-func NewPostgreSQL(config PostgreSQLConfig) (DataBase, error) {
-	log.Println("Post cfg: ", config)
-	connStr := fmt.Sprintf(
-		"postgres://%v:%v@%v:%v/%v?sslmode=%s",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-		config.SSLMode,
-	)
-
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("Ошибка соединения с базой данных: %w", err)
+func (postgres *postgreSQL) RunMigrations(ctx context.Context, path string) error {
+	if path == "" {
+		path = "pkg/dbwork/migrations"
 	}
+	postgres.migrations = path
 
-	if err = db.Ping(); err != nil {
-		return nil, fmt.Errorf("Ошибка ping базы данных: %w", err)
+	if err := postgres.adoptMigrateHistory(ctx); err != nil {
+		return err
 	}
 
-	postgres := &postgreSQL{db}
+	return database.Migrate(ctx, postgres.pool, path)
+}
 
-	return postgres, nil
+func (postgres *postgreSQL) Ready(ctx context.Context) error {
+	return database.Ready(ctx, postgres.pool, postgres.migrations)
 }
 
-func (postgres *postgreSQL) RunMigrations(path string) error {
-	driver, err := postgre.WithInstance(postgres.DB, &postgre.Config{})
-	if err != nil {
-		return fmt.Errorf("Ошибка в создании драйвера для миграций: %w", err)
+// adoptMigrateHistory переносит номер версии из таблицы golang-migrate,
+// которым core_service применял миграции раньше, в таблицу goose. Иначе
+// goose начал бы с первой миграции и упал на уже созданных таблицах.
+func (postgres *postgreSQL) adoptMigrateHistory(ctx context.Context) error {
+	adopt := false
+	checkQuery := `SELECT to_regclass('schema_migrations') IS NOT NULL AND to_regclass('goose_db_version') IS NULL`
+	if err := postgres.pool.QueryRow(ctx, checkQuery).Scan(&adopt); err != nil {
+		return fmt.Errorf("adoptMigrateHistory ошибка проверки таблиц: %w", err)
 	}
-
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("Ошибка в Getwd, RunMigrations: %w", err)
+	if !adopt {
+		return nil
 	}
 
-	if path == "" {
-		path = "file://" + filepath.Join(wd, "pkg/dbwork/migrations")
+	version, dirty := int64(0), false
+	selectQuery := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	if err := postgres.pool.QueryRow(ctx, selectQuery).Scan(&version, &dirty); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("adoptMigrateHistory ошибка чтения версии: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("adoptMigrateHistory миграция %d golang-migrate не завершена, исправьте бд вручную", version)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		path,
-		"postgres", driver,
-	)
+	sqlDB := stdlib.OpenDBFromPool(postgres.pool)
+	defer sqlDB.Close()
 
-	if err != nil {
-		return fmt.Errorf("Ошибка миграции NewWithDatabaseInstance: %w", err)
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("adoptMigrateHistory ошибка установки диалекта: %w", err)
 	}
-
-	version, dirty, err := m.Version()
-	if err != nil && err != migrate.ErrNilVersion {
-		return fmt.Errorf("Ошибка в версии миграции, dirty: %w", err)
+	if _, err := goose.EnsureDBVersionContext(ctx, sqlDB); err != nil {
+		return fmt.Errorf("adoptMigrateHistory ошибка создания таблицы goose: %w", err)
 	}
 
-	if dirty {
-		if err := m.Force(int(version)); err != nil {
-			return fmt.Errorf("Невозможность исправить dirty миграции: %w", err)
-		}
+	insertQuery := `INSERT INTO goose_db_version (version_id, is_applied)
+	                SELECT generate_series(1, $1::bigint), TRUE`
+	if _, err := postgres.pool.Exec(ctx, insertQuery, version); err != nil {
+		return fmt.Errorf("adoptMigrateHistory ошибка переноса версии: %w", err)
 	}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		if dirtyErr, ok := err.(migrate.ErrDirty); ok {
-			if err := m.Force(int(dirtyErr.Version)); err != nil {
-				return fmt.Errorf("Dirty миграций: %w", err)
-			}
-			if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-				return fmt.Errorf("Невозможность поднять миграцию: %w", err)
-			}
-		}
-		return err
-	}
-	log.Println("Миграция прошла успешно")
+	postgres.log.Info().Int64("version", version).Msg("История миграций golang-migrate перенесена в goose")
 	return nil
 }
 
-func (postgres *postgreSQL) CreateProduct(pr models.Product) (int, error) {
+// CreateProduct добавляет товар вместе с изображениями в одной транзакции:
+// товар без части изображений в бд не попадёт.
+func (postgres *postgreSQL) CreateProduct(ctx context.Context, pr models.Product) (int, error) {
+	tx, err := postgres.pool.Begin(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("CreateProduct ошибка begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	createQueryProduct := `INSERT INTO product
 	                (name, description, parameters, count, price)
 	                VALUES($1, $2, $3, $4, $5) RETURNING id;`
 	id := -1
-	if err := postgres.QueryRow(createQueryProduct, pr.Name, pr.Description, pr.Parameters, pr.Count, pr.Price).Scan(&id); err != nil {
+	if err = tx.QueryRow(ctx, createQueryProduct, pr.Name, pr.Description, pr.Parameters, pr.Count, pr.Price).Scan(&id); err != nil {
 		return -1, fmt.Errorf("CreateProduct ошибка QueryRow: %w", err)
 	}
+
 	createQueryImage := `INSERT INTO product_image
-	                     (product_id, name, key)
-	                     VALUES($1, $2, $3);`
-
-	for _, image := range pr.Images {
-		_, err := postgres.Exec(createQueryImage, id, image.Name, image.Key)
-		if err != nil {
-			_, err = postgres.Exec(createQueryImage, id, image.Name, image.Key)
-			if err != nil {
-				return -1, fmt.Errorf("CreateProduct ошибка добавления изображений: %w", err)
-			}
+	                     (product_id, name, key, content_type, size, checksum, position, is_primary, alt)
+	                     VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+
+	batch := &pgx.Batch{}
+	for i, image := range pr.Images {
+		alt, err := json.Marshal(image.Alt)
+		if err != nil || image.Alt == nil {
+			alt = []byte("{}")
 		}
+
+		// Порядок совпадает с порядком загрузки, первое изображение главное
+		batch.Queue(createQueryImage, id, image.Name, image.Key, image.ContentType, image.Size, image.Checksum, i, i == 0, string(alt))
 	}
-	return id, nil
-}
 
-func (postgres *postgreSQL) UpdateProduct() {}
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return -1, fmt.Errorf("CreateProduct ошибка добавления изображений: %w", err)
+	}
 
-func (postgres *postgreSQL) ChangeCountProduct(id, countChange int) error {
-	updateQueryCount := `UPDATE product SET count = count + $1 WHERE id = $2`
-	if countChange == 0 {
-		return nil
+	if err = tx.Commit(ctx); err != nil {
+		return -1, fmt.Errorf("CreateProduct ошибка commit: %w", err)
 	}
-	if _, err := postgres.Exec(updateQueryCount, countChange, id); err != nil {
+
+	return id, nil
+}
+
+func (postgres *postgreSQL) UpdateProduct(ctx context.Context, pr models.Product) error {
+	updateQuery := `UPDATE product SET name=$1, description=$2, parameters=$3, price=$4 WHERE id=$5`
+	result, err := postgres.pool.Exec(ctx, updateQuery, pr.Name, pr.Description, pr.Parameters, pr.Price, pr.ID)
+	if err != nil {
 		return fmt.Errorf("UpdateProduct ошибка exec: %w", err)
 	}
+	if result.RowsAffected() == 0 {
+		return ErrProductNotFound
+	}
 
 	return nil
+}
+
+func (postgres *postgreSQL) ChangeCountProduct(ctx context.Context, id, countChange int) (int, error) {
+	updateQueryCount := `UPDATE product SET count = count + $1 WHERE id = $2 RETURNING count`
+	count := 0
+	if err := postgres.pool.QueryRow(ctx, updateQueryCount, countChange, id).Scan(&count); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrProductNotFound
+		}
+		// Остаток не может стать отрицательным, это проверяет CHECK на count
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == checkViolation {
+			return 0, ErrInsufficientStock
+		}
+		return 0, fmt.Errorf("ChangeCountProduct ошибка queryrow: %w", err)
+	}
 
+	return count, nil
 }
 
-func (postgres *postgreSQL) DeleteProduct(id int) ([]string, error) {
+// DeleteProduct удаляет товар и в той же транзакции ставит его файлы
+// в очередь на удаление из хранилища. Возвращает поставленные ключи.
+func (postgres *postgreSQL) DeleteProduct(ctx context.Context, id int) ([]string, error) {
 	keys := make([]string, 0)
 
-	selectQuery := `SELECT key FROM product_image WHERE product_id=$1`
+	tx, err := postgres.pool.Begin(ctx)
+	if err != nil {
+		return keys, fmt.Errorf("DeleteProduct ошибка begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	rows, err := postgres.Query(selectQuery, id)
+	selectQuery := `SELECT key, thumbnail_key, card_key, full_key FROM product_image WHERE product_id=$1`
+
+	rows, err := tx.Query(ctx, selectQuery, id)
 	if err != nil {
 		return keys, fmt.Errorf("DeleteProduct ошибка query: %w", err)
 	}
-	defer rows.Close()
 
 	for rows.Next() {
-		key := ""
-		if err = rows.Scan(&key); err != nil {
+		var key, thumbnailKey, cardKey, fullKey string
+		if err = rows.Scan(&key, &thumbnailKey, &cardKey, &fullKey); err != nil {
+			rows.Close()
 			return keys, fmt.Errorf("DeleteProduct ошибка scan product: %w", err)
 		}
 		keys = append(keys, key)
+		// Варианты удаляются из хранилища вместе с оригиналом
+		for _, variantKey := range []string{thumbnailKey, cardKey, fullKey} {
+			if variantKey != "" {
+				keys = append(keys, variantKey)
+			}
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return keys, fmt.Errorf("DeleteProduct ошибка rows: %w", err)
+	}
+
+	insertQueryCleanup := `INSERT INTO storage_cleanup (key)
+	                       SELECT unnest($1::varchar[])
+	                       ON CONFLICT (key) DO NOTHING`
+	if _, err = tx.Exec(ctx, insertQueryCleanup, keys); err != nil {
+		return keys, fmt.Errorf("DeleteProduct ошибка exec очереди удаления: %w", err)
 	}
 
 	deleteQueryImage := `DELETE FROM product_image WHERE product_id=$1`
-	if _, err := postgres.Exec(deleteQueryImage, id); err != nil {
+	if _, err := tx.Exec(ctx, deleteQueryImage, id); err != nil {
 		return keys, fmt.Errorf("DeleteProduct ошибка exec удаления image: %w", err)
 	}
 
 	deleteQueryProduct := `DELETE FROM product WHERE id=$1`
-	if _, err := postgres.Exec(deleteQueryProduct, id); err != nil {
+	tag, err := tx.Exec(ctx, deleteQueryProduct, id)
+	if err != nil {
 		return keys, fmt.Errorf("DeleteProduct ошибка exec удаления product: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return keys, ErrProductNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return keys, fmt.Errorf("DeleteProduct ошибка commit: %w", err)
+	}
 
 	return keys, nil
 }
 
-func (postgres *postgreSQL) ReadProduct(id int) (models.Product, error) {
+func (postgres *postgreSQL) ReadProduct(ctx context.Context, id int) (models.Product, error) {
 	selectQueryProduct := `SELECT id, name, description, parameters, count, price FROM product WHERE id=$1`
 	pr := models.Product{}
 
-	if err := postgres.QueryRow(selectQueryProduct, id).Scan(&pr.ID, &pr.Name, &pr.Description, &pr.Parameters, &pr.Count, &pr.Price); err != nil {
+	if err := postgres.pool.QueryRow(ctx, selectQueryProduct, id).Scan(&pr.ID, &pr.Name, &pr.Description, &pr.Parameters, &pr.Count, &pr.Price); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pr, ErrProductNotFound
+		}
 		return pr, fmt.Errorf("ReadProduct ошибка queryrow product: %w", err)
 	}
 
-	selectQueryImages := `SELECT id, product_id, name, key FROM product_image WHERE product_id=$1`
+	images, err := readImages(ctx, postgres.pool, []int{id})
+	if err != nil {
+		return pr, fmt.Errorf("ReadProduct ошибка чтения image: %w", err)
+	}
+	pr.Images = images[id]
+	if pr.Images == nil {
+		pr.Images = make([]models.ProductImage, 0)
+	}
+
+	return pr, nil
+}
+
+// ReadListProduct читает изображения всех товаров одним запросом,
+// а не отдельным запросом на каждый товар.
+func (postgres *postgreSQL) ReadListProduct(ctx context.Context) ([]models.Product, error) {
+	selectQueryProduct := `SELECT id, name, description, parameters, count, price FROM product ORDER BY id`
+	pr := make([]models.Product, 0)
+
+	rows, err := postgres.pool.Query(ctx, selectQueryProduct)
+	if err != nil {
+		return pr, fmt.Errorf("ReadListProduct ошибка Query product: %w", err)
+	}
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		product := models.Product{}
+		if err = rows.Scan(&product.ID, &product.Name, &product.Description, &product.Parameters, &product.Count, &product.Price); err != nil {
+			rows.Close()
+			return pr, fmt.Errorf("ReadListProduct ошибка scan product: %w", err)
+		}
+		pr = append(pr, product)
+		ids = append(ids, product.ID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return pr, fmt.Errorf("ReadListProduct ошибка rows: %w", err)
+	}
+
+	images, err := readImages(ctx, postgres.pool, ids)
+	if err != nil {
+		return pr, fmt.Errorf("ReadListProduct ошибка чтения image: %w", err)
+	}
+	for i := range pr {
+		pr[i].Images = images[pr[i].ID]
+		if pr[i].Images == nil {
+			pr[i].Images = make([]models.ProductImage, 0)
+		}
+	}
 
-	rows, err := postgres.Query(selectQueryImages, id)
+	return pr, nil
+}
+
+// readImages возвращает изображения товаров, сгруппированные по id товара,
+// в порядке, заданном администратором.
+func readImages(ctx context.Context, q querier, productIDs []int) (map[int][]models.ProductImage, error) {
+	selectQueryImages := `SELECT id, product_id, name, key, thumbnail_key, card_key, full_key,
+	                             content_type, size, checksum, status, created_at,
+	                             position, is_primary, alt
+	                      FROM product_image WHERE product_id = ANY($1) ORDER BY product_id, position, id`
+	images := make(map[int][]models.ProductImage, len(productIDs))
+	if len(productIDs) == 0 {
+		return images, nil
+	}
+
+	rows, err := q.Query(ctx, selectQueryImages, productIDs)
 	if err != nil {
-		return pr, fmt.Errorf("ReadProduct ошибка query image: %w", err)
+		return images, fmt.Errorf("readImages ошибка query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		image := models.ProductImage{}
+		alt := []byte{}
+		if err = rows.Scan(&image.ID, &image.ProductID, &image.Name, &image.Key,
+			&image.ThumbnailKey, &image.CardKey, &image.FullKey,
+			&image.ContentType, &image.Size, &image.Checksum, &image.Status, &image.CreatedAt,
+			&image.Position, &image.IsPrimary, &alt); err != nil {
+			return images, fmt.Errorf("readImages ошибка scan: %w", err)
+		}
+		if err = json.Unmarshal(alt, &image.Alt); err != nil {
+			return images, fmt.Errorf("readImages ошибка разбора alt: %w", err)
+		}
+
+		images[image.ProductID] = append(images[image.ProductID], image)
+	}
+
+	return images, rows.Err()
+}
+
+func (postgres *postgreSQL) ListUnprocessedImages(ctx context.Context, limit int) ([]models.ProductImage, error) {
+	selectQuery := `SELECT id, product_id, name, key
+	                FROM product_image
+	                WHERE processed_at IS NULL AND status='confirmed'
+	                ORDER BY id
+	                LIMIT $1`
+	images := make([]models.ProductImage, 0)
+
+	rows, err := postgres.pool.Query(ctx, selectQuery, limit)
+	if err != nil {
+		return images, fmt.Errorf("ListUnprocessedImages ошибка query: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		image := models.ProductImage{}
 		if err = rows.Scan(&image.ID, &image.ProductID, &image.Name, &image.Key); err != nil {
-			return pr, fmt.Errorf("ReadProduct ошибка scan image: %w", err)
+			return images, fmt.Errorf("ListUnprocessedImages ошибка scan: %w", err)
 		}
+		images = append(images, image)
+	}
 
-		pr.Images = append(pr.Images, image)
+	return images, rows.Err()
+}
+
+func (postgres *postgreSQL) SetImageVariants(ctx context.Context, image models.ProductImage) error {
+	updateQuery := `UPDATE product_image
+	                SET thumbnail_key=$1, card_key=$2, full_key=$3, processed_at=NOW()
+	                WHERE id=$4`
 
+	if _, err := postgres.pool.Exec(ctx, updateQuery, image.ThumbnailKey, image.CardKey, image.FullKey, image.ID); err != nil {
+		return fmt.Errorf("SetImageVariants ошибка exec: %w", err)
 	}
-	return pr, nil
+
+	return nil
 }
 
-func (postgres *postgreSQL) ReadListProduct() ([]models.Product, error) {
-	selectQueryProduct := `SELECT id, name, description, parameters, count, price FROM product`
-	pr := make([]models.Product, 0)
+func (postgres *postgreSQL) ListPendingImages(ctx context.Context, createdBefore time.Time, limit int) ([]models.ProductImage, error) {
+	selectQuery := `SELECT id, product_id, name, key, content_type, size, checksum, status, created_at
+	                FROM product_image
+	                WHERE status='pending' AND created_at < $1
+	                ORDER BY created_at
+	                LIMIT $2`
+	images := make([]models.ProductImage, 0)
 
-	rows, err := postgres.Query(selectQueryProduct)
+	rows, err := postgres.pool.Query(ctx, selectQuery, createdBefore, limit)
 	if err != nil {
-		return pr, fmt.Errorf("ReadListProduct ошибка Query product: %w", err)
+		return images, fmt.Errorf("ListPendingImages ошибка query: %w", err)
 	}
 	defer rows.Close()
+
 	for rows.Next() {
-		product := models.Product{}
-		if err = rows.Scan(&product.ID, &product.Name, &product.Description, &product.Parameters, &product.Count, &product.Price); err != nil {
-			return pr, fmt.Errorf("ReadListProduct ошибка scan product: %w", err)
+		image := models.ProductImage{}
+		if err = rows.Scan(&image.ID, &image.ProductID, &image.Name, &image.Key,
+			&image.ContentType, &image.Size, &image.Checksum, &image.Status, &image.CreatedAt); err != nil {
+			return images, fmt.Errorf("ListPendingImages ошибка scan: %w", err)
 		}
-		selectQueryImages := `SELECT id, product_id, name, key FROM product_image WHERE product_id=$1`
+		images = append(images, image)
+	}
+
+	return images, rows.Err()
+}
+
+func (postgres *postgreSQL) ConfirmImage(ctx context.Context, id int) error {
+	updateQuery := `UPDATE product_image SET status='confirmed', confirmed_at=NOW()
+	                WHERE id=$1 AND status='pending'`
+
+	if _, err := postgres.pool.Exec(ctx, updateQuery, id); err != nil {
+		return fmt.Errorf("ConfirmImage ошибка exec: %w", err)
+	}
+
+	return nil
+}
+
+// RejectImage отмечает файл, не прошедший проверку, и ставит его
+// в очередь на удаление из хранилища.
+func (postgres *postgreSQL) RejectImage(ctx context.Context, image models.ProductImage) error {
+	tx, err := postgres.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("RejectImage ошибка begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	updateQuery := `UPDATE product_image SET status='rejected' WHERE id=$1`
+	if _, err = tx.Exec(ctx, updateQuery, image.ID); err != nil {
+		return fmt.Errorf("RejectImage ошибка exec статуса: %w", err)
+	}
+
+	insertQueryCleanup := `INSERT INTO storage_cleanup (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`
+	if _, err = tx.Exec(ctx, insertQueryCleanup, image.Key); err != nil {
+		return fmt.Errorf("RejectImage ошибка exec очереди удаления: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("RejectImage ошибка commit: %w", err)
+	}
+
+	return nil
+}
+
+func (postgres *postgreSQL) DeleteImage(ctx context.Context, id int) error {
+	deleteQuery := `DELETE FROM product_image WHERE id=$1`
+
+	if _, err := postgres.pool.Exec(ctx, deleteQuery, id); err != nil {
+		return fmt.Errorf("DeleteImage ошибка exec: %w", err)
+	}
+
+	return nil
+}
+
+// ListImageKeys возвращает все ключи хранилища, на которые ссылается бд,
+// включая уменьшенные копии.
+func (postgres *postgreSQL) ListImageKeys(ctx context.Context) (map[string]struct{}, error) {
+	selectQuery := `SELECT key, thumbnail_key, card_key, full_key FROM product_image`
+	keys := make(map[string]struct{})
 
-		rowsImages, err := postgres.Query(selectQueryImages, product.ID)
-		if err != nil {
-			return pr, fmt.Errorf("ReadListProduct ошибка query image: %w", err)
+	rows, err := postgres.pool.Query(ctx, selectQuery)
+	if err != nil {
+		return keys, fmt.Errorf("ListImageKeys ошибка query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, thumbnailKey, cardKey, fullKey string
+		if err = rows.Scan(&key, &thumbnailKey, &cardKey, &fullKey); err != nil {
+			return keys, fmt.Errorf("ListImageKeys ошибка scan: %w", err)
 		}
-		defer rowsImages.Close()
-		for rowsImages.Next() {
-			image := models.ProductImage{}
-			if err = rowsImages.Scan(&image.ID, &image.ProductID, &image.Name, &image.Key); err != nil {
-				return pr, fmt.Errorf("ReadListProduct ошибка scan image: %w", err)
+		for _, k := range []string{key, thumbnailKey, cardKey, fullKey} {
+			if k != "" {
+				keys[k] = struct{}{}
 			}
-			product.Images = append(product.Images, image)
 		}
-		pr = append(pr, product)
 	}
 
-	return pr, nil
+	return keys, rows.Err()
+}
+
+func (postgres *postgreSQL) ImageConfirmed(ctx context.Context, key string) (bool, error) {
+	selectQuery := `SELECT EXISTS (SELECT 1 FROM product_image
+	                WHERE status='confirmed' AND $1 IN (key, thumbnail_key, card_key, full_key))`
+
+	confirmed := false
+	if err := postgres.pool.QueryRow(ctx, selectQuery, key).Scan(&confirmed); err != nil {
+		return false, fmt.Errorf("ImageConfirmed ошибка query: %w", err)
+	}
+
+	return confirmed, nil
+}
+
+func (postgres *postgreSQL) ListCleanupTasks(ctx context.Context, limit int) ([]models.CleanupTask, error) {
+	selectQuery := `SELECT id, key, attempts, last_error, next_attempt_at
+	                FROM storage_cleanup
+	                WHERE next_attempt_at <= NOW()
+	                ORDER BY next_attempt_at
+	                LIMIT $1`
+	tasks := make([]models.CleanupTask, 0)
+
+	rows, err := postgres.pool.Query(ctx, selectQuery, limit)
+	if err != nil {
+		return tasks, fmt.Errorf("ListCleanupTasks ошибка query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task := models.CleanupTask{}
+		if err = rows.Scan(&task.ID, &task.Key, &task.Attempts, &task.LastError, &task.NextAttemptAt); err != nil {
+			return tasks, fmt.Errorf("ListCleanupTasks ошибка scan: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (postgres *postgreSQL) CompleteCleanupTasks(ctx context.Context, ids []int) error {
+	deleteQuery := `DELETE FROM storage_cleanup WHERE id = ANY($1)`
+
+	if _, err := postgres.pool.Exec(ctx, deleteQuery, ids); err != nil {
+		return fmt.Errorf("CompleteCleanupTasks ошибка exec: %w", err)
+	}
+
+	return nil
+}
+
+// FailCleanupTasks откладывает повтор с экспоненциально растущей паузой,
+// но не больше чем на 6 часов.
+func (postgres *postgreSQL) FailCleanupTasks(ctx context.Context, ids []int, reason string) error {
+	updateQuery := `UPDATE storage_cleanup
+	                SET attempts = attempts + 1,
+	                    last_error = $1,
+	                    next_attempt_at = NOW() + LEAST(INTERVAL '1 minute' * POWER(2, attempts), INTERVAL '6 hours')
+	                WHERE id = ANY($2)`
+
+	if _, err := postgres.pool.Exec(ctx, updateQuery, reason, ids); err != nil {
+		return fmt.Errorf("FailCleanupTasks ошибка exec: %w", err)
+	}
+
+	return nil
+}
+
+// ReorderImages расставляет изображения товара в порядке imageIDs.
+// Список должен содержать каждое подтверждённое изображение товара ровно
+// один раз: только их видят клиенты.
+func (postgres *postgreSQL) ReorderImages(ctx context.Context, productID int, imageIDs []int) error {
+	seen := make(map[int]struct{}, len(imageIDs))
+	for _, imageID := range imageIDs {
+		if _, ok := seen[imageID]; ok {
+			return ErrImageOrderMismatch
+		}
+		seen[imageID] = struct{}{}
+	}
+
+	tx, err := postgres.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ReorderImages ошибка begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	count := 0
+	selectQuery := `SELECT COUNT(*) FROM product_image WHERE product_id=$1 AND status='confirmed'`
+	if err = tx.QueryRow(ctx, selectQuery, productID).Scan(&count); err != nil {
+		return fmt.Errorf("ReorderImages ошибка queryrow: %w", err)
+	}
+	if count != len(imageIDs) {
+		return ErrImageOrderMismatch
+	}
+
+	// Позиция - номер id в переданном массиве, начиная с нуля
+	updateQuery := `UPDATE product_image AS image SET position = ord.position - 1
+	                FROM unnest($1::int[]) WITH ORDINALITY AS ord(id, position)
+	                WHERE image.id = ord.id AND image.product_id=$2 AND image.status='confirmed'`
+	result, err := tx.Exec(ctx, updateQuery, imageIDs, productID)
+	if err != nil {
+		return fmt.Errorf("ReorderImages ошибка exec: %w", err)
+	}
+	if result.RowsAffected() != int64(len(imageIDs)) {
+		return ErrImageOrderMismatch
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ReorderImages ошибка commit: %w", err)
+	}
+
+	return nil
+}
+
+func (postgres *postgreSQL) SetPrimaryImage(ctx context.Context, productID, imageID int) error {
+	tx, err := postgres.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("SetPrimaryImage ошибка begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	resetQuery := `UPDATE product_image SET is_primary=FALSE WHERE product_id=$1 AND is_primary`
+	if _, err = tx.Exec(ctx, resetQuery, productID); err != nil {
+		return fmt.Errorf("SetPrimaryImage ошибка exec сброса: %w", err)
+	}
+
+	// Главным может быть только загруженное изображение, иначе у товара
+	// не останется видимого главного изображения
+	updateQuery := `UPDATE product_image SET is_primary=TRUE WHERE id=$1 AND product_id=$2 AND status='confirmed'`
+	result, err := tx.Exec(ctx, updateQuery, imageID, productID)
+	if err != nil {
+		return fmt.Errorf("SetPrimaryImage ошибка exec: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrImageNotFound
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("SetPrimaryImage ошибка commit: %w", err)
+	}
+
+	return nil
+}
+
+// SetImageAlt заменяет альтернативный текст изображения. Ключи alt - коды
+// языков, например "ru" или "en".
+func (postgres *postgreSQL) SetImageAlt(ctx context.Context, productID, imageID int, alt map[string]string) error {
+	data, err := json.Marshal(alt)
+	if err != nil || alt == nil {
+		data = []byte("{}")
+	}
+
+	updateQuery := `UPDATE product_image SET alt=$1 WHERE id=$2 AND product_id=$3`
+	result, err := postgres.pool.Exec(ctx, updateQuery, string(data), imageID, productID)
+	if err != nil {
+		return fmt.Errorf("SetImageAlt ошибка exec: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrImageNotFound
+	}
+
+	return nil
+}
+
+func (postgres *postgreSQL) RecordAudit(ctx context.Context, entry audit.Entry) error {
+	return audit.Insert(ctx, postgres.pool, entry)
+}
+
+func (postgres *postgreSQL) ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return audit.List(ctx, postgres.pool, filter)
 }
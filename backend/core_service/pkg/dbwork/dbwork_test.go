@@ -7,13 +7,15 @@ import (
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 
+	"common/audit"
+	"common/config"
 	"core-service/pkg/dbwork"
 	"core-service/pkg/models"
 )
@@ -43,7 +45,7 @@ func setupTestDB(ctx context.Context) (dbwork.DataBase, func(), error) {
 		return nil, nil, err
 	}
 
-	config := dbwork.PostgreSQLConfig{
+	cfg := config.PostgreSQL{
 		User:     dbUser,
 		Password: dbPassword,
 		Host:     host,
@@ -52,7 +54,7 @@ func setupTestDB(ctx context.Context) (dbwork.DataBase, func(), error) {
 		SSLMode:  "disable",
 	}
 
-	db, err := dbwork.NewPostgreSQL(config)
+	db, err := dbwork.NewPostgreSQL(ctx, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -63,8 +65,8 @@ func setupTestDB(ctx context.Context) (dbwork.DataBase, func(), error) {
 	}
 
 	dir := filepath.Dir(filename)
-	migrationsPath := "file://" + filepath.Join(dir, "migrations")
-	err = db.RunMigrations(migrationsPath)
+	migrationsPath := filepath.Join(dir, "migrations")
+	err = db.RunMigrations(ctx, migrationsPath)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -85,7 +87,7 @@ func TestPostgreSQL(t *testing.T) {
 	assert.NoError(t, err)
 	defer cleanup()
 
-	db.RunMigrations("")
+	db.RunMigrations(ctx, "")
 
 	testImage := models.ProductImage{
 		Name: "lol.txt",
@@ -99,7 +101,7 @@ func TestPostgreSQL(t *testing.T) {
 	}
 	testProduct.Images = append(testProduct.Images, testImage)
 
-	CreateAndReadProduct(t, testProduct, db)
+	CreateAndReadProduct(ctx, t, testProduct, db)
 
 	testProduct2 := models.Product{
 		Name:        "Рено логан",
@@ -119,20 +121,25 @@ func TestPostgreSQL(t *testing.T) {
 
 	testProduct2.Images = append(testProduct2.Images, testImage2, testImage3)
 
-	CreateAndReadProduct(t, testProduct2, db)
+	CreateAndReadProduct(ctx, t, testProduct2, db)
 
 	testProducts := make([]models.Product, 0)
 	testProducts = append(testProducts, testProduct, testProduct2)
-	ReadAllProduct(t, testProducts, db)
-	DeleteAndRead(t, db)
-	ChangeCountProduct(t, db)
+	ReadAllProduct(ctx, t, testProducts, db)
+	ConfirmAndDeleteImage(ctx, t, db)
+	DeleteAndRead(ctx, t, db)
+	CleanupTasks(ctx, t, db)
+	ChangeCountProduct(ctx, t, db)
+	UpdateProduct(ctx, t, db)
+	ImageOrderAndAlt(ctx, t, db)
+	AuditLog(ctx, t, db)
 
 }
 
-func CreateAndReadProduct(t *testing.T, TestProduct models.Product, db dbwork.DataBase) {
-	id, err := db.CreateProduct(TestProduct)
+func CreateAndReadProduct(ctx context.Context, t *testing.T, TestProduct models.Product, db dbwork.DataBase) {
+	id, err := db.CreateProduct(ctx, TestProduct)
 	assert.NoError(t, err)
-	DBProduct, err := db.ReadProduct(id)
+	DBProduct, err := db.ReadProduct(ctx, id)
 	assert.NoError(t, err)
 	EqualProduct(t, TestProduct, DBProduct)
 }
@@ -149,8 +156,8 @@ func EqualProduct(t *testing.T, TestProduct models.Product, DBProduct models.Pro
 
 }
 
-func ReadAllProduct(t *testing.T, TestProducts []models.Product, db dbwork.DataBase) {
-	DBProducts, err := db.ReadListProduct()
+func ReadAllProduct(ctx context.Context, t *testing.T, TestProducts []models.Product, db dbwork.DataBase) {
+	DBProducts, err := db.ReadListProduct(ctx)
 	assert.NoError(t, err)
 
 	for i, DBProduct := range DBProducts {
@@ -158,8 +165,8 @@ func ReadAllProduct(t *testing.T, TestProducts []models.Product, db dbwork.DataB
 	}
 }
 
-func DeleteAndRead(t *testing.T, db dbwork.DataBase) {
-	products, err := db.ReadListProduct()
+func DeleteAndRead(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	products, err := db.ReadListProduct(ctx)
 	assert.NoError(t, err)
 	count := len(products)
 	id := -1
@@ -167,15 +174,37 @@ func DeleteAndRead(t *testing.T, db dbwork.DataBase) {
 		id = products[0].ID
 	}
 
-	_, err = db.DeleteProduct(id)
+	_, err = db.DeleteProduct(ctx, id)
 	assert.NoError(t, err)
 
-	products, err = db.ReadListProduct()
+	products, err = db.ReadListProduct(ctx)
 	assert.Equal(t, count, len(products)+1)
 }
 
-func ChangeCountProduct(t *testing.T, db dbwork.DataBase) {
-	products, err := db.ReadListProduct()
+func UpdateProduct(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	products, err := db.ReadListProduct(ctx)
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, products) {
+		return
+	}
+
+	product := products[0]
+	product.Name = "Новое название"
+	product.Price = product.Price + 100
+	assert.NoError(t, db.UpdateProduct(ctx, product))
+
+	updated, err := db.ReadProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	EqualProduct(t, product, updated)
+	assert.Equal(t, product.Price, updated.Price)
+	assert.Equal(t, product.Count, updated.Count)
+
+	product.ID = -1
+	assert.ErrorIs(t, db.UpdateProduct(ctx, product), dbwork.ErrProductNotFound)
+}
+
+func ChangeCountProduct(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	products, err := db.ReadListProduct(ctx)
 	assert.NoError(t, err)
 
 	id := 1
@@ -185,24 +214,170 @@ func ChangeCountProduct(t *testing.T, db dbwork.DataBase) {
 		count = products[0].Count
 	}
 
-	err = db.ChangeCountProduct(id, 200)
+	newCount, err := db.ChangeCountProduct(ctx, id, 200)
 
 	assert.NoError(t, err)
+	assert.Equal(t, count+200, newCount)
 
-	product, err := db.ReadProduct(id)
+	product, err := db.ReadProduct(ctx, id)
 	assert.NoError(t, err)
 
 	assert.Equal(t, count+200, product.Count)
 
-	err = db.ChangeCountProduct(id, -1-product.Count)
-	assert.Error(t, err)
+	_, err = db.ChangeCountProduct(ctx, id, -1-product.Count)
+	assert.ErrorIs(t, err, dbwork.ErrInsufficientStock)
+
+	_, err = db.ChangeCountProduct(ctx, -1, 10)
+	assert.ErrorIs(t, err, dbwork.ErrProductNotFound)
 
 	count = product.Count
-	err = db.ChangeCountProduct(id, -10)
+	_, err = db.ChangeCountProduct(ctx, id, -10)
 
-	product, err = db.ReadProduct(id)
+	product, err = db.ReadProduct(ctx, id)
 	assert.NoError(t, err)
 
 	assert.Equal(t, product.Count, count-10)
 
 }
+
+func ConfirmAndDeleteImage(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	pending, err := db.ListPendingImages(ctx, time.Now().Add(time.Minute), 100)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 3)
+
+	err = db.ConfirmImage(ctx, pending[0].ID)
+	assert.NoError(t, err)
+
+	err = db.DeleteImage(ctx, pending[1].ID)
+	assert.NoError(t, err)
+
+	pending, err = db.ListPendingImages(ctx, time.Now().Add(time.Minute), 100)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	keys, err := db.ListImageKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	// Отдаются только подтверждённые изображения
+	confirmed, err := db.ImageConfirmed(ctx, pending[0].Key)
+	assert.NoError(t, err)
+	assert.False(t, confirmed)
+	for key := range keys {
+		if key != pending[0].Key {
+			confirmed, err = db.ImageConfirmed(ctx, key)
+			assert.NoError(t, err)
+			assert.True(t, confirmed)
+		}
+	}
+}
+
+func CleanupTasks(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	tasks, err := db.ListCleanupTasks(ctx, 100)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tasks)
+
+	err = db.FailCleanupTasks(ctx, []int{tasks[0].ID}, "хранилище недоступно")
+	assert.NoError(t, err)
+
+	ids := make([]int, 0, len(tasks)-1)
+	for _, task := range tasks[1:] {
+		ids = append(ids, task.ID)
+	}
+	err = db.CompleteCleanupTasks(ctx, ids)
+	assert.NoError(t, err)
+
+	// Неудачная задача отложена и до следующей попытки не выдаётся
+	tasks, err = db.ListCleanupTasks(ctx, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func ImageOrderAndAlt(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	product := models.Product{Name: "Наушники"}
+	for _, name := range []string{"front.jpg", "back.jpg", "side.jpg"} {
+		product.Images = append(product.Images, models.ProductImage{Name: name, Key: uuid.New().String()})
+	}
+	product.Images[0].Alt = map[string]string{"ru": "Вид спереди"}
+
+	id, err := db.CreateProduct(ctx, product)
+	assert.NoError(t, err)
+
+	DBProduct, err := db.ReadProduct(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, DBProduct.Images[0].IsPrimary)
+	assert.Equal(t, "Вид спереди", DBProduct.Images[0].Alt["ru"])
+
+	ids := make([]int, 0, len(DBProduct.Images))
+	for _, image := range DBProduct.Images {
+		assert.NoError(t, db.ConfirmImage(ctx, image.ID))
+		ids = append(ids, image.ID)
+	}
+
+	assert.Equal(t, dbwork.ErrImageOrderMismatch, db.ReorderImages(ctx, id, ids[:2]))
+	assert.Equal(t, dbwork.ErrImageOrderMismatch, db.ReorderImages(ctx, id, []int{ids[0], ids[0], ids[1]}))
+	assert.NoError(t, db.ReorderImages(ctx, id, []int{ids[2], ids[0], ids[1]}))
+
+	assert.NoError(t, db.SetPrimaryImage(ctx, id, ids[1]))
+	assert.Equal(t, dbwork.ErrImageNotFound, db.SetPrimaryImage(ctx, id, -1))
+
+	assert.NoError(t, db.SetImageAlt(ctx, id, ids[2], map[string]string{"ru": "Сбоку", "en": "Side"}))
+	assert.Equal(t, dbwork.ErrImageNotFound, db.SetImageAlt(ctx, id, -1, nil))
+
+	DBProduct, err = db.ReadProduct(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, ids[2], DBProduct.Images[0].ID)
+	assert.Equal(t, "Side", DBProduct.Images[0].Alt["en"])
+	assert.Equal(t, ids[0], DBProduct.Images[1].ID)
+	assert.False(t, DBProduct.Images[1].IsPrimary)
+	assert.True(t, DBProduct.Images[2].IsPrimary)
+
+	// Незагруженное изображение не становится главным
+	pending := models.Product{Name: "Колонка", Images: []models.ProductImage{
+		{Name: "front.jpg", Key: uuid.New().String()},
+		{Name: "back.jpg", Key: uuid.New().String()},
+	}}
+	pendingID, err := db.CreateProduct(ctx, pending)
+	assert.NoError(t, err)
+	DBProduct, err = db.ReadProduct(ctx, pendingID)
+	assert.NoError(t, err)
+	assert.NoError(t, db.ConfirmImage(ctx, DBProduct.Images[0].ID))
+	assert.NoError(t, db.SetPrimaryImage(ctx, pendingID, DBProduct.Images[0].ID))
+
+	assert.Equal(t, dbwork.ErrImageNotFound, db.SetPrimaryImage(ctx, pendingID, DBProduct.Images[1].ID))
+	DBProduct, err = db.ReadProduct(ctx, pendingID)
+	assert.NoError(t, err)
+	assert.True(t, DBProduct.Images[0].IsPrimary)
+	assert.False(t, DBProduct.Images[1].IsPrimary)
+}
+
+func AuditLog(ctx context.Context, t *testing.T, db dbwork.DataBase) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	entries := []audit.Entry{
+		{Time: now.Add(-time.Hour), Actor: "admin", Action: audit.ActionProductCreate, Target: "product:1", After: []byte(`{"count":5}`)},
+		{Time: now, Actor: "admin", Action: audit.ActionProductImageAlt, Target: "product:1", RequestID: "req-1"},
+		{Time: now, Actor: "apikey:3", Action: audit.ActionProductStock, Target: "product:1",
+			Before: []byte(`{"count":5}`), After: []byte(`{"count":4}`)},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, db.RecordAudit(ctx, entry))
+	}
+
+	all, err := db.ListAudit(ctx, audit.Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+	// Новые записи первыми
+	assert.Equal(t, audit.ActionProductStock, all[0].Action)
+	assert.JSONEq(t, `{"count":5}`, string(all[0].Before))
+	assert.Nil(t, all[1].Before)
+
+	found, err := db.ListAudit(ctx, audit.Filter{Actor: "admin", Action: "product.images"})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "req-1", found[0].RequestID)
+
+	found, err = db.ListAudit(ctx, audit.Filter{Target: "product:1", To: now, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, audit.ActionProductCreate, found[0].Action)
+}
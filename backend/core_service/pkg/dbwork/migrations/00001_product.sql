@@ -1,3 +1,5 @@
+-- +goose Up
+-- +goose StatementBegin
 CREATE TABLE product(
   id BIGSERIAL PRIMARY KEY,
   name TEXT,
@@ -13,3 +15,10 @@ CREATE TABLE product_image(
   name VARCHAR(255) NOT NULL,
   key VARCHAR(500) NOT NULL UNIQUE
 );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS product_image;
+DROP TABLE IF EXISTS product;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product_image
+  ADD COLUMN thumbnail_key VARCHAR(500) NOT NULL DEFAULT '',
+  ADD COLUMN card_key VARCHAR(500) NOT NULL DEFAULT '',
+  ADD COLUMN full_key VARCHAR(500) NOT NULL DEFAULT '',
+  ADD COLUMN processed_at TIMESTAMP;
+
+CREATE INDEX idx_product_image_unprocessed ON product_image (id) WHERE processed_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_product_image_unprocessed;
+
+ALTER TABLE product_image
+  DROP COLUMN IF EXISTS thumbnail_key,
+  DROP COLUMN IF EXISTS card_key,
+  DROP COLUMN IF EXISTS full_key,
+  DROP COLUMN IF EXISTS processed_at;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product_image
+  ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed')),
+  ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN confirmed_at TIMESTAMP;
+
+-- Изображения, загруженные до появления подтверждения, считаем загруженными
+UPDATE product_image SET status = 'confirmed', confirmed_at = NOW();
+
+CREATE INDEX idx_product_image_pending ON product_image (created_at) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_product_image_pending;
+
+ALTER TABLE product_image
+  DROP COLUMN IF EXISTS status,
+  DROP COLUMN IF EXISTS created_at,
+  DROP COLUMN IF EXISTS confirmed_at;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product_image
+  ADD COLUMN content_type VARCHAR(100) NOT NULL DEFAULT '',
+  ADD COLUMN size BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN checksum VARCHAR(64) NOT NULL DEFAULT '',
+  DROP CONSTRAINT product_image_status_check,
+  ADD CONSTRAINT product_image_status_check CHECK (status IN ('pending', 'confirmed', 'rejected'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM product_image WHERE status = 'rejected';
+
+ALTER TABLE product_image
+  DROP CONSTRAINT product_image_status_check,
+  ADD CONSTRAINT product_image_status_check CHECK (status IN ('pending', 'confirmed')),
+  DROP COLUMN IF EXISTS content_type,
+  DROP COLUMN IF EXISTS size,
+  DROP COLUMN IF EXISTS checksum;
+-- +goose StatementEnd
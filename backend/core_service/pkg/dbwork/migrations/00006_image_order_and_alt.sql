@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE product_image
+  ADD COLUMN position INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN alt JSONB NOT NULL DEFAULT '{}';
+
+-- Сохраняем прежний порядок: по id, первое изображение главное
+UPDATE product_image AS pi
+SET position = ordered.position, is_primary = ordered.position = 0
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY id) - 1 AS position
+  FROM product_image
+) AS ordered
+WHERE pi.id = ordered.id;
+
+CREATE UNIQUE INDEX idx_product_image_primary ON product_image (product_id) WHERE is_primary;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_product_image_primary;
+
+ALTER TABLE product_image
+  DROP COLUMN IF EXISTS position,
+  DROP COLUMN IF EXISTS is_primary,
+  DROP COLUMN IF EXISTS alt;
+-- +goose StatementEnd
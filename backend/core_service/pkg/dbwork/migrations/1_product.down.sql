@@ -1,3 +0,0 @@
-DROP TABLE product;
-DROP TABLE product_image;
-
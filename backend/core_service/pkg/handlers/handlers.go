@@ -2,101 +2,259 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
 
+	"common/response"
 	"core-service/pkg/models"
 	"core-service/pkg/service"
 )
 
-func CreateProduct(rw http.ResponseWriter, r *http.Request) {
+type Handler struct {
+	service *service.Service
+}
+
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (handler *Handler) CreateProduct(rw http.ResponseWriter, r *http.Request) {
 	resp := models.ResponseCreateProduct{}
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println(fmt.Errorf("Handler CreateProduct ошибка чтения данных: %w", err))
-		resp.Error(http.StatusBadRequest, "Ошибка чтения данных")
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Ошибка чтения данных")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения данных")
 		resp.Write(rw)
 		return
 	}
 
-	req := models.ProductResponse{}
+	req := models.RequestCreateProduct{}
 	err = json.Unmarshal(data, &req)
 	if err != nil {
-		log.Println(fmt.Errorf("Handlers create product ошибка декодирования json: %w", err))
-		resp.Error(http.StatusBadRequest, "Ошибка чтения json")
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Ошибка декодирования json")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения json")
 		resp.Write(rw)
 		return
 	}
 
-	resp = service.CreateProduct(req)
-	log.Println("RESP CREATE ", resp, "\n", "REQ CREATE ", req)
+	resp = handler.service.CreateProduct(r.Context(), req)
+	// Ответ не пишется целиком: в нём подписанные ссылки загрузки
+	log.Ctx(r.Context()).Debug().Int("product_id", resp.ID).Int("uploads", len(resp.Uploads)).Msg("Создание товара")
 
 	resp.Write(rw)
 }
 
-func ChangeCountProduct(rw http.ResponseWriter, r *http.Request) {
+func (handler *Handler) ChangeCountProduct(rw http.ResponseWriter, r *http.Request) {
 
 	resp := models.Response{}
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println(fmt.Errorf("Handler ChangeCountProduct: %w", err))
-		resp.Error(http.StatusBadRequest, "Ошибка чтения данных")
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Ошибка чтения данных")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения данных")
 		resp.Write(rw)
 		return
 	}
 	req := models.RequestChangeCount{}
 	if err = json.Unmarshal(data, &req); err != nil {
-		resp.Error(http.StatusBadRequest, "Ошибка чтения json")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения json")
 		resp.Write(rw)
 		return
 	}
 
-	resp = service.ChangeCountProduct(req)
+	resp = handler.service.ChangeCountProduct(r.Context(), req)
 	resp.Write(rw)
 }
 
-func ReadProduct(rw http.ResponseWriter, r *http.Request) {
+func (handler *Handler) ReadProduct(rw http.ResponseWriter, r *http.Request) {
 	resp := models.ResponseReadProduct{}
 	vars := mux.Vars(r)
 	strID := vars["id"]
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		resp.Error(http.StatusBadRequest, "Не найден ID в запросе")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
 		resp.Write(rw)
 		return
 	}
 
-	resp = service.ReadProduct(id)
+	resp = handler.service.ReadProduct(r.Context(), id)
 	resp.Write(rw)
 }
 
-func ReadAllProduct(rw http.ResponseWriter, r *http.Request) {
-	resp := service.ReadAllProduct()
+func (handler *Handler) ReadAllProduct(rw http.ResponseWriter, r *http.Request) {
+	resp := handler.service.ReadAllProduct(r.Context())
 	resp.Write(rw)
 }
 
-func DeleteProduct(rw http.ResponseWriter, r *http.Request) {
+func (handler *Handler) UpdateProduct(rw http.ResponseWriter, r *http.Request) {
+	resp := models.Response{}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
+		resp.Write(rw)
+		return
+	}
+
+	req := models.RequestUpdateProduct{}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения json")
+		resp.Write(rw)
+		return
+	}
+
+	resp = handler.service.UpdateProduct(r.Context(), id, req)
+	resp.Write(rw)
+}
+
+func (handler *Handler) DeleteProduct(rw http.ResponseWriter, r *http.Request) {
 	resp := models.Response{}
 	vars := mux.Vars(r)
 	strID := vars["id"]
 	id, err := strconv.Atoi(strID)
 	if err != nil {
-		resp.Error(http.StatusBadRequest, "Не найден ID в запросе")
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
 		resp.Write(rw)
 		return
 	}
 
-	resp = service.DeleteProduct(id)
+	resp = handler.service.DeleteProduct(r.Context(), id)
 	resp.Write(rw)
 }
 
-func HealthCheck(rw http.ResponseWriter, r *http.Request) {
+func (handler *Handler) ConfirmProductImages(rw http.ResponseWriter, r *http.Request) {
+	resp := models.ResponseConfirmImages{}
+	vars := mux.Vars(r)
+	strID := vars["id"]
+	id, err := strconv.Atoi(strID)
+	if err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
+		resp.Write(rw)
+		return
+	}
+
+	resp = handler.service.ConfirmProductImages(r.Context(), id)
+	resp.Write(rw)
+}
+
+func (handler *Handler) ReorderImages(rw http.ResponseWriter, r *http.Request) {
 	resp := models.Response{}
-	resp.StatusOK()
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
+		resp.Write(rw)
+		return
+	}
+
+	req := models.RequestReorderImages{}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения json")
+		resp.Write(rw)
+		return
+	}
+
+	resp = handler.service.ReorderImages(r.Context(), id, req)
 	resp.Write(rw)
 }
+
+func (handler *Handler) SetPrimaryImage(rw http.ResponseWriter, r *http.Request) {
+	resp := models.Response{}
+	id, imageID, err := productImageIDs(r)
+	if err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
+		resp.Write(rw)
+		return
+	}
+
+	resp = handler.service.SetPrimaryImage(r.Context(), id, imageID)
+	resp.Write(rw)
+}
+
+func (handler *Handler) SetImageAlt(rw http.ResponseWriter, r *http.Request) {
+	resp := models.Response{}
+	id, imageID, err := productImageIDs(r)
+	if err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Не найден ID в запросе")
+		resp.Write(rw)
+		return
+	}
+
+	req := models.RequestImageAlt{}
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.Error(http.StatusBadRequest, response.CodeBadRequest, "Ошибка чтения json")
+		resp.Write(rw)
+		return
+	}
+
+	resp = handler.service.SetImageAlt(r.Context(), id, imageID, req)
+	resp.Write(rw)
+}
+
+func productImageIDs(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, err
+	}
+	imageID, err := strconv.Atoi(vars["image_id"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return id, imageID, nil
+}
+
+// Image отдаёт подтверждённое изображение по постоянному адресу.
+// Содержимое объекта по ключу никогда не меняется, поэтому ответ
+// кешируется навсегда, а в качестве ETag достаточно самого ключа.
+func (handler *Handler) Image(rw http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	etag := `"` + key + `"`
+
+	if err := handler.service.FindImage(r.Context(), key); err != nil {
+		writeImageError(rw, r, err)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	rw.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	resp, err := handler.service.OpenImage(r.Context(), key)
+	if err != nil {
+		rw.Header().Del("Cache-Control")
+		rw.Header().Del("ETag")
+		writeImageError(rw, r, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Last-Modified"} {
+		if value := resp.Header.Get(header); value != "" {
+			rw.Header().Set(header, value)
+		}
+	}
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = io.Copy(rw, resp.Body); err != nil {
+		log.Ctx(r.Context()).Warn().Err(err).Msg("Ошибка передачи изображения")
+	}
+}
+
+func writeImageError(rw http.ResponseWriter, r *http.Request, err error) {
+	if err == service.ErrImageNotFound {
+		response.WriteProblem(rw, response.NewProblem(http.StatusNotFound, response.CodeImageNotFound, err.Error()))
+		return
+	}
+	log.Ctx(r.Context()).Error().Err(err).Msg("Ошибка получения изображения")
+	response.WriteProblem(rw, response.InternalServerError())
+}
@@ -0,0 +1,138 @@
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"slices"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+type Size string
+
+const (
+	Thumbnail Size = "thumbnail"
+	Card      Size = "card"
+	Full      Size = "full"
+)
+
+// Sizes - наибольшая сторона каждого варианта в пикселях.
+var Sizes = map[Size]int{
+	Thumbnail: 160,
+	Card:      480,
+	Full:      1600,
+}
+
+const jpegQuality = 82
+
+// MaxUploadSize - наибольший размер загружаемого оригинала.
+const MaxUploadSize = 10 << 20
+
+// MaxPixels - наибольшее число пикселей оригинала. Декодированное
+// изображение занимает 4 байта на пиксель, и сжатый файл в несколько
+// килобайт может развернуться в гигабайты памяти.
+const MaxPixels = 40_000_000
+
+// ContentTypes - форматы оригиналов, которые можно загрузить.
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+var (
+	ErrUnsupportedImage = errors.New("Неподдерживаемый формат изображения")
+	ErrImageTooLarge    = errors.New("Слишком большое изображение")
+)
+
+type Variant struct {
+	Size        Size
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// DetectContentType определяет формат по первым байтам файла и
+// возвращает пустую строку, если это не поддерживаемое изображение.
+func DetectContentType(header []byte) string {
+	contentType := http.DetectContentType(header)
+	if !slices.Contains(ContentTypes, contentType) {
+		return ""
+	}
+	return contentType
+}
+
+// Process декодирует оригинал и создаёт варианты всех размеров в JPEG.
+// WebP варианты не создаются: в golang.org/x/image есть только декодер.
+// Варианты кодируются заново, поэтому EXIF и прочие метаданные
+// оригинала в них не попадают. Размеры оригинала проверяются по
+// заголовку до декодирования.
+func Process(r io.Reader) ([]Variant, error) {
+	header := bytes.Buffer{}
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxPixels/cfg.Height {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	variants := make([]Variant, 0, len(Sizes))
+	for _, size := range []Size{Thumbnail, Card, Full} {
+		data, err := encodeJPEG(resize(src, Sizes[size]))
+		if err != nil {
+			return nil, fmt.Errorf("Process ошибка кодирования %s: %w", size, err)
+		}
+
+		variants = append(variants, Variant{
+			Size:        size,
+			Data:        data,
+			ContentType: "image/jpeg",
+			Ext:         ".jpg",
+		})
+	}
+
+	return variants, nil
+}
+
+// resize вписывает изображение в квадрат maxSide, не увеличивая его,
+// и накладывает на белый фон, так как в JPEG нет прозрачности.
+func resize(src image.Image, maxSide int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(1, height*maxSide/width)
+			width = maxSide
+		} else {
+			width = max(1, width*maxSide/height)
+			height = maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	return dst
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,95 @@
+package imageproc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"core-service/pkg/imageproc"
+)
+
+func TestProcess(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2000, 1000))
+	for x := 0; x < 2000; x++ {
+		src.Set(x, 500, color.NRGBA{R: 255, A: 255})
+	}
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, png.Encode(&buf, src))
+
+	variants, err := imageproc.Process(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, variants, 3)
+
+	for _, variant := range variants {
+		img, err := jpeg.Decode(bytes.NewReader(variant.Data))
+		assert.NoError(t, err)
+
+		maxSide := imageproc.Sizes[variant.Size]
+		assert.Equal(t, maxSide, img.Bounds().Dx())
+		assert.Equal(t, maxSide/2, img.Bounds().Dy())
+		assert.Equal(t, "image/jpeg", variant.ContentType)
+
+		// APP1 (0xFFE1) - сегмент, в котором хранится EXIF
+		assert.False(t, bytes.Contains(variant.Data, []byte{0xFF, 0xE1}))
+	}
+}
+
+func TestProcess_SmallImageIsNotUpscaled(t *testing.T) {
+	buf := bytes.Buffer{}
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 100, 50))))
+
+	variants, err := imageproc.Process(&buf)
+	assert.NoError(t, err)
+
+	for _, variant := range variants {
+		img, err := jpeg.Decode(bytes.NewReader(variant.Data))
+		assert.NoError(t, err)
+		assert.Equal(t, 100, img.Bounds().Dx())
+		assert.Equal(t, 50, img.Bounds().Dy())
+	}
+}
+
+func TestProcess_NotAnImage(t *testing.T) {
+	_, err := imageproc.Process(strings.NewReader("not an image"))
+	assert.ErrorIs(t, err, imageproc.ErrUnsupportedImage)
+}
+
+func TestProcess_DecompressionBomb(t *testing.T) {
+	// Заголовок PNG 100000x100000: декодер выделил бы 40 ГБ
+	ihdr := make([]byte, 0, 17)
+	ihdr = append(ihdr, "IHDR"...)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+
+	buf := bytes.Buffer{}
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)-4))
+	buf.Write(ihdr)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+
+	_, err := imageproc.Process(&buf)
+	assert.ErrorIs(t, err, imageproc.ErrImageTooLarge)
+}
+
+func TestDetectContentType(t *testing.T) {
+	buf := bytes.Buffer{}
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 10, 10))))
+	assert.Equal(t, "image/png", imageproc.DetectContentType(buf.Bytes()))
+
+	buf.Reset()
+	assert.NoError(t, jpeg.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 10, 10)), nil))
+	assert.Equal(t, "image/jpeg", imageproc.DetectContentType(buf.Bytes()))
+
+	assert.Empty(t, imageproc.DetectContentType([]byte("<html><script>alert(1)</script>")))
+	assert.Empty(t, imageproc.DetectContentType([]byte("%PDF-1.7")))
+}
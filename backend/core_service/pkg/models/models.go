@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Product struct {
 	ID          int            `json:"id"`
 	Name        string         `json:"name"`
@@ -11,15 +13,59 @@ type Product struct {
 }
 
 type ProductImage struct {
-	ID        int    `json:"id"`
-	ProductID int    `json:"product_id"`
-	Name      string `json:"name"`
-	Key       string `json:"key"`
+	ID           int               `json:"id"`
+	ProductID    int               `json:"product_id"`
+	Name         string            `json:"name"`
+	Key          string            `json:"key"`
+	ThumbnailKey string            `json:"thumbnail_key"`
+	CardKey      string            `json:"card_key"`
+	FullKey      string            `json:"full_key"`
+	ContentType  string            `json:"content_type"`
+	Size         int64             `json:"size"`
+	Checksum     string            `json:"checksum"`
+	Status       string            `json:"status"`
+	CreatedAt    time.Time         `json:"created_at"`
+	Position     int               `json:"position"`
+	IsPrimary    bool              `json:"is_primary"`
+	Alt          map[string]string `json:"alt"`
+}
+
+// Изображение остаётся в статусе pending, пока файл не появится в хранилище.
+// Файлы, не прошедшие проверку при подтверждении, получают статус rejected
+// и удаляются из хранилища.
+const (
+	ImageStatusPending   = "pending"
+	ImageStatusConfirmed = "confirmed"
+	ImageStatusRejected  = "rejected"
+)
+
+// ImageUpload описывает файл, который клиент собирается загрузить.
+// Checksum - sha256 содержимого в base64, как его ожидает S3.
+type ImageUpload struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	// Alt - альтернативный текст по кодам языков
+	Alt map[string]string `json:"alt,omitempty"`
+}
+
+// CleanupTask - объект хранилища, который нужно удалить после удаления
+// товара. Задача повторяется, пока удаление не пройдёт успешно.
+type CleanupTask struct {
+	ID            int       `json:"id"`
+	Key           string    `json:"key"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
 }
 
 type S3SImage struct {
 	URL    string `json:"url"`
 	FileID string `json:"file_id"`
+	// Headers - заголовки, которые клиент обязан передать вместе с файлом,
+	// так как они входят в подпись ссылки.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type CreateProductResponse struct {
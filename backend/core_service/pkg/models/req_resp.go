@@ -1,28 +1,85 @@
 package models
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"common/response"
 )
 
+// Response - общий для сервисов конверт ответа. Write отправляет его с
+// настоящим http статусом, а ошибки - в формате problem+json.
 type Response struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	response.Response
 }
 
 type ProductResponse struct {
-	ID          int      `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Parameters  string   `json:"parameters"`
-	Count       int      `json:"count"`
-	Price       int      `json:"price"`
-	Images      []string `json:"images"`
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  string          `json:"parameters"`
+	Count       int             `json:"count"`
+	Price       int             `json:"price"`
+	Images      []ImageResponse `json:"images"`
+}
+
+// ImageResponse - изображение товара в порядке, заданном администратором.
+// Ровно одно изображение товара отмечено главным.
+type ImageResponse struct {
+	ID        int               `json:"id"`
+	URL       string            `json:"url"`
+	Alt       map[string]string `json:"alt"`
+	Position  int               `json:"position"`
+	IsPrimary bool              `json:"is_primary"`
+	// Пока уменьшенные копии не готовы, в Variants указан оригинал
+	Variants ImageVariants `json:"variants"`
+}
+
+type ImageVariants struct {
+	Thumbnail string `json:"thumbnail"`
+	Card      string `json:"card"`
+	Full      string `json:"full"`
+}
+
+type RequestReorderImages struct {
+	ImageIDs []int `json:"image_ids"`
+}
+
+type RequestImageAlt struct {
+	Alt map[string]string `json:"alt"`
+}
+
+type RequestCreateProduct struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Parameters  string        `json:"parameters"`
+	Count       int           `json:"count"`
+	Price       int           `json:"price"`
+	Images      []ImageUpload `json:"images"`
+}
+
+// RequestUpdateProduct заменяет поля товара. Остаток меняется через
+// RequestChangeCount, изображения - отдельными запросами.
+type RequestUpdateProduct struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parameters  string `json:"parameters"`
+	Price       int    `json:"price"`
 }
 
 type ResponseCreateProduct struct {
 	Response
-	URLs []string `json:"urls"`
+	ID      int        `json:"id"`
+	URLs    []string   `json:"urls"`
+	Uploads []S3SImage `json:"uploads"`
+}
+
+// ResponseConfirmImages перечисляет ключи изображений, найденных в
+// хранилище, ещё не загруженных и отклонённых проверкой.
+type ResponseConfirmImages struct {
+	Response
+	Confirmed []string `json:"confirmed"`
+	Missing   []string `json:"missing"`
+	Rejected  []string `json:"rejected"`
 }
 
 type ResponseReadProduct struct {
@@ -32,46 +89,30 @@ type ResponseReadProduct struct {
 
 type ResponseReadAllProduct struct {
 	Response
-	Products []ProductResponse
+	Products []ProductResponse `json:"products"`
 }
 
 type RequestChangeCount struct {
-	ID    int
-	Count int
+	ID    int `json:"id"`
+	Count int `json:"count"`
 }
 
 func (resp *Response) Write(rw http.ResponseWriter) {
-	json.NewEncoder(rw).Encode(resp)
+	response.Write(rw, resp.Response, resp)
 }
 
 func (resp *ResponseReadAllProduct) Write(rw http.ResponseWriter) {
-	json.NewEncoder(rw).Encode(resp)
+	response.Write(rw, resp.Response.Response, resp)
 }
 
 func (resp *ResponseCreateProduct) Write(rw http.ResponseWriter) {
-	json.NewEncoder(rw).Encode(resp)
-}
-
-func (resp *ResponseReadProduct) Write(rw http.ResponseWriter) {
-	json.NewEncoder(rw).Encode(resp)
-}
-
-func (resp *Response) Error(code int, message string) {
-	resp.Code = code
-	resp.Message = message
+	response.Write(rw, resp.Response.Response, resp)
 }
 
-func (resp *Response) InternalError() {
-	resp.Code = http.StatusInternalServerError
-	resp.Message = "Внутренняя ошибка сервера"
+func (resp *ResponseConfirmImages) Write(rw http.ResponseWriter) {
+	response.Write(rw, resp.Response.Response, resp)
 }
 
-func (resp *Response) StatusOK() {
-	resp.Code = http.StatusOK
-	resp.Message = "Выполнение прошло успешно"
-}
-
-func (resp *Response) StatusCreated() {
-	resp.Code = http.StatusCreated
-	resp.Message = "Объект успешно создан"
+func (resp *ResponseReadProduct) Write(rw http.ResponseWriter) {
+	response.Write(rw, resp.Response.Response, resp)
 }
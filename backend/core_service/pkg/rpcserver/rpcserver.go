@@ -0,0 +1,130 @@
+// Package rpcserver - внутренний gRPC API каталога для manage_service.
+// Логика та же, что у http обработчиков: обе стороны вызывают service.
+package rpcserver
+
+import (
+	"context"
+
+	"common/proto/catalogpb"
+	"common/rpc"
+	"core-service/pkg/models"
+	"core-service/pkg/service"
+)
+
+type Server struct {
+	catalogpb.UnimplementedCatalogServer
+	service *service.Service
+}
+
+func New(service *service.Service) *Server {
+	return &Server{service: service}
+}
+
+func (s *Server) ListProducts(ctx context.Context, _ *catalogpb.ListProductsRequest) (*catalogpb.ListProductsResponse, error) {
+	resp := s.service.ReadAllProduct(ctx)
+	if err := rpc.FromResponse(resp.Response.Response); err != nil {
+		return nil, err
+	}
+
+	products := make([]*catalogpb.Product, 0, len(resp.Products))
+	for _, product := range resp.Products {
+		products = append(products, productToProto(product))
+	}
+	return &catalogpb.ListProductsResponse{Products: products}, nil
+}
+
+func (s *Server) GetProduct(ctx context.Context, req *catalogpb.GetProductRequest) (*catalogpb.Product, error) {
+	resp := s.service.ReadProduct(ctx, int(req.GetId()))
+	if err := rpc.FromResponse(resp.Response.Response); err != nil {
+		return nil, err
+	}
+	return productToProto(resp.ProductResponse), nil
+}
+
+func (s *Server) CreateProduct(ctx context.Context, req *catalogpb.CreateProductRequest) (*catalogpb.CreateProductResponse, error) {
+	product := models.RequestCreateProduct{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Parameters:  req.GetParameters(),
+		Count:       int(req.GetCount()),
+		Price:       int(req.GetPrice()),
+		Images:      make([]models.ImageUpload, 0, len(req.GetImages())),
+	}
+	for _, image := range req.GetImages() {
+		product.Images = append(product.Images, models.ImageUpload{
+			Name:        image.GetName(),
+			ContentType: image.GetContentType(),
+			Size:        image.GetSize(),
+			Checksum:    image.GetChecksum(),
+			Alt:         image.GetAlt(),
+		})
+	}
+
+	resp := s.service.CreateProduct(ctx, product)
+	if err := rpc.FromResponse(resp.Response.Response); err != nil {
+		return nil, err
+	}
+
+	uploads := make([]*catalogpb.Upload, 0, len(resp.Uploads))
+	for _, upload := range resp.Uploads {
+		uploads = append(uploads, &catalogpb.Upload{Url: upload.URL, FileId: upload.FileID, Headers: upload.Headers})
+	}
+	return &catalogpb.CreateProductResponse{Id: int32(resp.ID), Urls: resp.URLs, Uploads: uploads}, nil
+}
+
+func (s *Server) UpdateProduct(ctx context.Context, req *catalogpb.UpdateProductRequest) (*catalogpb.UpdateProductResponse, error) {
+	resp := s.service.UpdateProduct(ctx, int(req.GetId()), models.RequestUpdateProduct{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Parameters:  req.GetParameters(),
+		Price:       int(req.GetPrice()),
+	})
+	if err := rpc.FromResponse(resp.Response); err != nil {
+		return nil, err
+	}
+	return &catalogpb.UpdateProductResponse{}, nil
+}
+
+func (s *Server) DeleteProduct(ctx context.Context, req *catalogpb.DeleteProductRequest) (*catalogpb.DeleteProductResponse, error) {
+	resp := s.service.DeleteProduct(ctx, int(req.GetId()))
+	if err := rpc.FromResponse(resp.Response); err != nil {
+		return nil, err
+	}
+	return &catalogpb.DeleteProductResponse{}, nil
+}
+
+func (s *Server) ChangeCount(ctx context.Context, req *catalogpb.ChangeCountRequest) (*catalogpb.ChangeCountResponse, error) {
+	resp := s.service.ChangeCountProduct(ctx, models.RequestChangeCount{ID: int(req.GetId()), Count: int(req.GetCount())})
+	if err := rpc.FromResponse(resp.Response); err != nil {
+		return nil, err
+	}
+	return &catalogpb.ChangeCountResponse{}, nil
+}
+
+func productToProto(product models.ProductResponse) *catalogpb.Product {
+	images := make([]*catalogpb.Image, 0, len(product.Images))
+	for _, image := range product.Images {
+		images = append(images, &catalogpb.Image{
+			Id:        int32(image.ID),
+			Url:       image.URL,
+			Alt:       image.Alt,
+			Position:  int32(image.Position),
+			IsPrimary: image.IsPrimary,
+			Variants: &catalogpb.ImageVariants{
+				Thumbnail: image.Variants.Thumbnail,
+				Card:      image.Variants.Card,
+				Full:      image.Variants.Full,
+			},
+		})
+	}
+
+	return &catalogpb.Product{
+		Id:          int32(product.ID),
+		Name:        product.Name,
+		Description: product.Description,
+		Parameters:  product.Parameters,
+		Count:       int32(product.Count),
+		Price:       int32(product.Price),
+		Images:      images,
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"common/audit"
+	"core-service/pkg/models"
+)
+
+// productState - поля товара, которые попадают в журнал аудита.
+type productState struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Parameters  string   `json:"parameters"`
+	Count       int      `json:"count"`
+	Price       int      `json:"price"`
+	Images      []string `json:"images"`
+}
+
+func newProductState(product models.Product) productState {
+	state := productState{
+		Name:        product.Name,
+		Description: product.Description,
+		Parameters:  product.Parameters,
+		Count:       product.Count,
+		Price:       product.Price,
+		Images:      make([]string, 0, len(product.Images)),
+	}
+	for _, image := range product.Images {
+		state.Images = append(state.Images, image.Key)
+	}
+	return state
+}
+
+// imageOrderState - порядок загруженных изображений товара, в том же виде,
+// в каком его задаёт RequestReorderImages.
+func imageOrderState(product models.Product) map[string][]int {
+	ids := make([]int, 0, len(product.Images))
+	for _, image := range product.Images {
+		if image.Status == models.ImageStatusConfirmed {
+			ids = append(ids, image.ID)
+		}
+	}
+	return map[string][]int{"image_ids": ids}
+}
+
+// primaryImageState - главное изображение товара, пустое, если его нет.
+func primaryImageState(product models.Product) map[string]int {
+	for _, image := range product.Images {
+		if image.IsPrimary {
+			return map[string]int{"image_id": image.ID}
+		}
+	}
+	return nil
+}
+
+// imageAltState - альтернативный текст изображения. id вложен вместе
+// с текстом, чтобы Diff не убрал его как неизменившееся поле.
+func imageAltState(imageID int, alt map[string]string) map[string]any {
+	return map[string]any{"image": map[string]any{"id": imageID, "alt": alt}}
+}
+
+// imageAlt возвращает альтернативный текст изображения imageID товара.
+func imageAlt(product models.Product, imageID int) map[string]string {
+	for _, image := range product.Images {
+		if image.ID == imageID {
+			return image.Alt
+		}
+	}
+	return nil
+}
+
+func productTarget(id int) string {
+	return audit.Target("product", id)
+}
+
+// ListAudit читает журнал аудита core_service.
+func (s *Service) ListAudit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return s.db.ListAudit(ctx, filter)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const cleanupBatchSize = 500
+
+// wakeStorageCleanup запускает очистку сразу после удаления товара,
+// не дожидаясь следующего тика.
+func (s *Service) wakeStorageCleanup() {
+	select {
+	case s.cleanupWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RunStorageCleanup удаляет из хранилища файлы удалённых товаров.
+// Неудачные попытки остаются в очереди и повторяются с нарастающей паузой,
+// поэтому недоступность хранилища не мешает удалению товара.
+func (s *Service) RunStorageCleanup(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Пока пачки полные, в очереди могут оставаться готовые задачи
+		for s.processCleanupBatch(ctx) == cleanupBatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.cleanupWakeup:
+		}
+	}
+}
+
+// processCleanupBatch возвращает число обработанных задач, чтобы при
+// полной пачке сразу взять следующую.
+func (s *Service) processCleanupBatch(ctx context.Context) int {
+	tasks, err := s.db.ListCleanupTasks(ctx, cleanupBatchSize)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка получения задач очистки")
+		return 0
+	}
+	if len(tasks) == 0 {
+		return 0
+	}
+
+	keys := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		keys = append(keys, task.Key)
+	}
+
+	failedKeys, err := s.storage.DeleteObjects(ctx, keys)
+	reason := "объект не удалён"
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка удаления объектов")
+		reason = err.Error()
+	}
+
+	failed := make(map[string]struct{}, len(failedKeys))
+	for _, key := range failedKeys {
+		failed[key] = struct{}{}
+	}
+
+	done := make([]int, 0, len(tasks))
+	retry := make([]int, 0, len(failedKeys))
+	for _, task := range tasks {
+		if _, ok := failed[task.Key]; ok {
+			retry = append(retry, task.ID)
+		} else {
+			done = append(done, task.ID)
+		}
+	}
+
+	if len(done) > 0 {
+		if err = s.db.CompleteCleanupTasks(ctx, done); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка завершения задач очистки")
+		}
+	}
+	if len(retry) > 0 {
+		if err = s.db.FailCleanupTasks(ctx, retry, reason); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка отложения задач очистки")
+		}
+		// Повторы отложены, поэтому следующая пачка их не вернёт
+		// и цикл не зациклится на недоступном хранилище.
+	}
+
+	return len(tasks)
+}
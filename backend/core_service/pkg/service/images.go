@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"core-service/pkg/imageproc"
+	"core-service/pkg/models"
+)
+
+const (
+	imageBatchSize     = 20
+	maxOriginalSize    = 32 << 20
+	storageHTTPTimeout = 30 * time.Second
+)
+
+var errOriginalTooLarge = errors.New("Оригинал больше допустимого размера")
+
+// RunImageProcessing периодически ищет загруженные оригиналы без
+// вариантов и создаёт для них уменьшенные копии.
+func (s *Service) RunImageProcessing(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.processPendingImages(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) processPendingImages(ctx context.Context) {
+	images, err := s.db.ListUnprocessedImages(ctx, imageBatchSize)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка получения изображений для обработки")
+		return
+	}
+
+	for _, image := range images {
+		if ctx.Err() != nil {
+			return
+		}
+		if err = s.processImage(ctx, image); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка обработки изображения")
+		}
+	}
+}
+
+// processImage скачивает оригинал по подписанной ссылке, создаёт варианты и
+// загружает их обратно в хранилище. Если оригинал ещё не загружен
+// администратором, изображение остаётся в очереди до следующего прохода.
+func (s *Service) processImage(ctx context.Context, image models.ProductImage) error {
+	original, err := s.download(ctx, image.Key)
+	if err == errOriginalTooLarge {
+		log.Ctx(ctx).Warn().Int("image_id", image.ID).Msg("Оригинал слишком большой для обработки, клиенты получат оригинал")
+		return s.db.SetImageVariants(ctx, image)
+	}
+	if err != nil || original == nil {
+		return err
+	}
+
+	variants, err := imageproc.Process(bytes.NewReader(original))
+	if err != nil {
+		// Повторная обработка битого файла ничего не даст, поэтому
+		// отмечаем его обработанным, а клиенты получат оригинал.
+		log.Ctx(ctx).Warn().Err(err).Int("image_id", image.ID).Msg("Изображение не обработано, клиенты получат оригинал")
+		return s.db.SetImageVariants(ctx, image)
+	}
+
+	for _, variant := range variants {
+		name := strings.TrimSuffix(image.Name, filepath.Ext(image.Name)) + "_" + string(variant.Size) + variant.Ext
+
+		key, err := s.upload(ctx, name, variant)
+		if err != nil {
+			return fmt.Errorf("process ошибка загрузки варианта %s изображения %d: %w", variant.Size, image.ID, err)
+		}
+
+		switch variant.Size {
+		case imageproc.Thumbnail:
+			image.ThumbnailKey = key
+		case imageproc.Card:
+			image.CardKey = key
+		case imageproc.Full:
+			image.FullKey = key
+		}
+	}
+
+	return s.db.SetImageVariants(ctx, image)
+}
+
+// download скачивает оригинал. Оригинал больше maxOriginalSize не читается
+// целиком, а возвращается errOriginalTooLarge.
+func (s *Service) download(ctx context.Context, key string) ([]byte, error) {
+	s3Image, err := s.storage.DownloadURL(key)
+	if err != nil {
+		return nil, fmt.Errorf("download ошибка создания url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s3Image.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download ошибка создания запроса: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download ошибка запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download неожиданный статус %d для %s", resp.StatusCode, key)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxOriginalSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download ошибка чтения: %w", err)
+	}
+	if len(data) > maxOriginalSize {
+		return nil, errOriginalTooLarge
+	}
+	return data, nil
+}
+
+func (s *Service) upload(ctx context.Context, name string, variant imageproc.Variant) (string, error) {
+	sum := sha256.Sum256(variant.Data)
+	s3Image, err := s.storage.UploadURL(models.ImageUpload{
+		Name:        name,
+		ContentType: variant.ContentType,
+		Size:        int64(len(variant.Data)),
+		Checksum:    base64.StdEncoding.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s3Image.URL, bytes.NewReader(variant.Data))
+	if err != nil {
+		return "", err
+	}
+	for header, value := range s3Image.Headers {
+		req.Header.Set(header, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("неожиданный статус %d", resp.StatusCode)
+	}
+
+	return s3Image.FileID, nil
+}
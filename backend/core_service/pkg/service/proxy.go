@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ImagesPath - постоянный адрес изображений, который не зависит от срока
+// действия подписанных ссылок хранилища.
+const ImagesPath = "/images/"
+
+var ErrImageNotFound = errors.New("Изображение не найдено")
+
+func (s *Service) imageURL(key string) string {
+	return s.cfg.ImagesURL + ImagesPath + url.PathEscape(key)
+}
+
+// FindImage проверяет, что по ключу можно отдавать изображение. Пока
+// загрузка не подтверждена, файл никто не проверял, поэтому ожидающие
+// и отклонённые загрузки не отдаются.
+func (s *Service) FindImage(ctx context.Context, key string) error {
+	confirmed, err := s.db.ImageConfirmed(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+// OpenImage скачивает объект по подписанной ссылке и возвращает ответ
+// хранилища, тело которого нужно закрыть после чтения. Скачивание
+// прерывается вместе с ctx.
+func (s *Service) OpenImage(ctx context.Context, key string) (*http.Response, error) {
+	image, err := s.storage.DownloadURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, image.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OpenImage ошибка создания запроса: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OpenImage ошибка запроса: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound, http.StatusForbidden:
+		// S3 отвечает 403 на отсутствующий ключ, если нет права на листинг
+		resp.Body.Close()
+		return nil, ErrImageNotFound
+	}
+
+	resp.Body.Close()
+	return nil, fmt.Errorf("OpenImage неожиданный статус %d для %s", resp.StatusCode, key)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	cloudstorage "core-service/pkg/cloud_storage"
+	"core-service/pkg/imageproc"
+	"core-service/pkg/models"
+)
+
+const (
+	// uploadGrace с запасом больше срока действия ссылки из UploadURL:
+	// раньше этого времени отсутствие файла ещё ничего не значит.
+	uploadGrace        = time.Hour
+	reconcileBatchSize = 100
+	// sniffLen - столько байт использует http.DetectContentType
+	sniffLen = 512
+)
+
+// RunReconciler периодически сверяет бд с хранилищем: подтверждает
+// загруженные изображения, удаляет записи о файлах, которые так и не
+// были загружены, и объекты, на которые не ссылается ни одна запись.
+func (s *Service) RunReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.reconcilePending(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка сверки изображений")
+		}
+		if err := s.removeOrphanedObjects(ctx); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка удаления объектов без записи в бд")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) reconcilePending(ctx context.Context) error {
+	images, err := s.db.ListPendingImages(ctx, time.Now().Add(-uploadGrace), reconcileBatchSize)
+	if err != nil {
+		return fmt.Errorf("reconcilePending ошибка получения изображений: %w", err)
+	}
+
+	for _, image := range images {
+		status, err := s.confirmImage(ctx, image)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка проверки изображения")
+			continue
+		}
+		if status != models.ImageStatusPending {
+			continue
+		}
+
+		if err = s.db.DeleteImage(ctx, image.ID); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка удаления записи изображения")
+			continue
+		}
+		log.Ctx(ctx).Info().Str("key", image.Key).Int("product_id", image.ProductID).Msg("Удалена запись о незагруженном изображении")
+	}
+
+	return nil
+}
+
+func (s *Service) removeOrphanedObjects(ctx context.Context) error {
+	// Список объектов берём до ключей из бд, чтобы не удалить файл,
+	// запись о котором появилась между двумя запросами.
+	objects, err := s.storage.ListObjects(ctx)
+	if err != nil {
+		return fmt.Errorf("removeOrphanedObjects ошибка получения объектов: %w", err)
+	}
+
+	keys, err := s.db.ListImageKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("removeOrphanedObjects ошибка получения ключей: %w", err)
+	}
+
+	threshold := time.Now().Add(-uploadGrace)
+	orphans := make([]string, 0)
+	for _, object := range objects {
+		if _, ok := keys[object.Key]; ok || object.LastModified.After(threshold) {
+			continue
+		}
+		orphans = append(orphans, object.Key)
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	failed, err := s.storage.DeleteObjects(ctx, orphans)
+	if err != nil {
+		return fmt.Errorf("removeOrphanedObjects ошибка удаления: %w", err)
+	}
+	log.Ctx(ctx).Info().Int("deleted", len(orphans)-len(failed)).Int("failed", len(failed)).Msg("Удалены объекты без записи в бд")
+
+	return nil
+}
+
+// confirmImage подтверждает изображение, если его файл уже есть в хранилище
+// и по размеру и первым байтам совпадает с заявленным при создании товара.
+// Не прошедший проверку файл отклоняется и удаляется. Возвращает новый
+// статус изображения.
+func (s *Service) confirmImage(ctx context.Context, image models.ProductImage) (string, error) {
+	info, err := s.storage.HeadObject(ctx, image.Key)
+	if err != nil {
+		if err == cloudstorage.ErrObjectNotFound {
+			return models.ImageStatusPending, nil
+		}
+		return "", fmt.Errorf("confirmImage ошибка проверки объекта %s: %w", image.Key, err)
+	}
+
+	header, err := s.readObjectHeader(ctx, image.Key)
+	if err != nil {
+		return "", err
+	}
+
+	contentType := imageproc.DetectContentType(header)
+	if contentType == "" || contentType != image.ContentType || info.Size != image.Size {
+		log.Ctx(ctx).Warn().
+			Str("key", image.Key).
+			Str("declared_type", image.ContentType).
+			Int64("declared_size", image.Size).
+			Str("actual_type", contentType).
+			Int64("actual_size", info.Size).
+			Msg("Отклонено изображение")
+		if err = s.db.RejectImage(ctx, image); err != nil {
+			return "", err
+		}
+		s.wakeStorageCleanup()
+		return models.ImageStatusRejected, nil
+	}
+
+	if err = s.db.ConfirmImage(ctx, image.ID); err != nil {
+		return "", err
+	}
+	return models.ImageStatusConfirmed, nil
+}
+
+// readObjectHeader читает начало объекта, достаточное для определения
+// формата по сигнатуре.
+func (s *Service) readObjectHeader(ctx context.Context, key string) ([]byte, error) {
+	image, err := s.storage.DownloadURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, image.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("readObjectHeader ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffLen-1))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("readObjectHeader ошибка запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("readObjectHeader неожиданный статус %d для %s", resp.StatusCode, key)
+	}
+
+	header, err := io.ReadAll(io.LimitReader(resp.Body, sniffLen))
+	if err != nil {
+		return nil, fmt.Errorf("readObjectHeader ошибка чтения: %w", err)
+	}
+	return header, nil
+}
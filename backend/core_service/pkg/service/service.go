@@ -1,22 +1,71 @@
 package service
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/rs/zerolog/log"
+
+	"common/audit"
+	"common/metrics"
+	"common/response"
 	cloudstorage "core-service/pkg/cloud_storage"
-	"core-service/pkg/connectionpool"
 	"core-service/pkg/dbwork"
+	"core-service/pkg/imageproc"
 	"core-service/pkg/models"
 )
 
-func CreateProduct(product models.ProductResponse) models.ResponseCreateProduct {
+// Service содержит бизнес-логику core_service и фоновые задачи.
+// Все зависимости передаются при создании, глобального состояния нет.
+type Service struct {
+	db      dbwork.DataBase
+	storage cloudstorage.CloudStorage
+	client  *http.Client
+	cfg     Config
+
+	cleanupWakeup chan struct{}
+}
+
+type Config struct {
+	// ImagesURL позволяет раздавать изображения с другого адреса,
+	// например через CDN. По умолчанию ссылки относительные и ведут
+	// в manage_service.
+	ImagesURL string
+}
+
+func LoadConfig() Config {
+	return Config{
+		ImagesURL: strings.TrimRight(os.Getenv("images_url"), "/"),
+	}
+}
+
+func NewService(db dbwork.DataBase, storage cloudstorage.CloudStorage, cfg Config) *Service {
+	return &Service{
+		db:            db,
+		storage:       storage,
+		client:        &http.Client{Timeout: storageHTTPTimeout},
+		cfg:           cfg,
+		cleanupWakeup: make(chan struct{}, 1),
+	}
+}
+
+func (s *Service) CreateProduct(ctx context.Context, product models.RequestCreateProduct) models.ResponseCreateProduct {
 	resp := models.ResponseCreateProduct{}
 
-	pool := connectionpool.NewConnectionPool()
-	s3s := pool.GetS3Storage()
-	dataBase := pool.GetDataBase()
+	for _, upload := range product.Images {
+		if err := validateUpload(upload); err != nil {
+			resp.Error(http.StatusBadRequest, response.CodeInvalidImage, err.Error())
+			return resp
+		}
+	}
 
 	productDB := models.Product{
 		Description: product.Description,
@@ -27,63 +76,144 @@ func CreateProduct(product models.ProductResponse) models.ResponseCreateProduct
 		Images:      make([]models.ProductImage, len(product.Images)),
 	}
 	urls := make([]string, 0, len(product.Images))
-	for i, name := range product.Images {
-		image, err := s3s.UploadURL(name)
+	uploads := make([]models.S3SImage, 0, len(product.Images))
+	for i, upload := range product.Images {
+		image, err := s.storage.UploadURL(upload)
 		if err != nil {
-			log.Println(err)
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка создания ссылки загрузки")
 			resp.InternalError()
 			return resp
 		}
 		urls = append(urls, image.URL)
-		productDB.Images[i].Key = image.FileID
-		productDB.Images[i].Name = name
+		uploads = append(uploads, image)
+		productDB.Images[i] = models.ProductImage{
+			Name:        upload.Name,
+			Key:         image.FileID,
+			ContentType: upload.ContentType,
+			Size:        upload.Size,
+			Checksum:    upload.Checksum,
+			Alt:         upload.Alt,
+		}
 	}
 
-	_, err := dataBase.CreateProduct(productDB)
+	id, err := s.db.CreateProduct(ctx, productDB)
 	if err != nil {
-		log.Println(err)
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка создания товара")
 		resp.InternalError()
 		return resp
 	}
 
+	metrics.Event(metrics.EventProductCreated)
+	audit.Record(ctx, s.db, audit.ActionProductCreate, productTarget(id), nil, newProductState(productDB))
+
+	resp.ID = id
 	resp.URLs = urls
+	resp.Uploads = uploads
 	resp.StatusCreated()
 	return resp
 }
 
-func ReadProduct(id int) models.ResponseReadProduct {
-
-	resp := models.ResponseReadProduct{}
+var (
+	ErrUnsupportedContentType = errors.New("Недопустимый тип файла")
+	ErrInvalidUploadSize      = errors.New("Недопустимый размер файла")
+	ErrInvalidChecksum        = errors.New("Неправильная контрольная сумма файла")
+)
 
-	product := models.Product{}
+// validateUpload проверяет заявленные клиентом тип, размер и контрольную
+// сумму до выдачи ссылки. Совпадение файла с ними проверяет хранилище.
+func validateUpload(upload models.ImageUpload) error {
+	if !slices.Contains(imageproc.ContentTypes, upload.ContentType) {
+		return ErrUnsupportedContentType
+	}
+	if upload.Size <= 0 || upload.Size > imageproc.MaxUploadSize {
+		return ErrInvalidUploadSize
+	}
+	if sum, err := base64.StdEncoding.DecodeString(upload.Checksum); err != nil || len(sum) != sha256.Size {
+		return ErrInvalidChecksum
+	}
+	return nil
+}
 
-	pool := connectionpool.NewConnectionPool()
-	dataBase := pool.GetDataBase()
+// ConfirmProductImages проверяет наличие в хранилище файлов товара,
+// ожидающих загрузки, и подтверждает найденные, если они прошли проверку.
+func (s *Service) ConfirmProductImages(ctx context.Context, id int) models.ResponseConfirmImages {
+	resp := models.ResponseConfirmImages{
+		Confirmed: make([]string, 0),
+		Missing:   make([]string, 0),
+		Rejected:  make([]string, 0),
+	}
 
-	product, err := dataBase.ReadProduct(id)
+	product, err := s.db.ReadProduct(ctx, id)
 	if err != nil {
-		log.Println(err)
+		if errors.Is(err, dbwork.ErrProductNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка получения товара")
 		resp.InternalError()
 		return resp
 	}
 
-	s3s := pool.GetS3Storage()
+	// В журнал попадают и изображения, подтверждённые до ошибки
+	defer func() {
+		if len(resp.Confirmed) > 0 || len(resp.Rejected) > 0 {
+			audit.Record(ctx, s.db, audit.ActionProductImagesConfirm, productTarget(id), nil,
+				map[string][]string{"confirmed": resp.Confirmed, "rejected": resp.Rejected})
+		}
+	}()
+
+	for _, image := range product.Images {
+		if image.Status != models.ImageStatusPending {
+			continue
+		}
+
+		status, err := s.confirmImage(ctx, image)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Ошибка подтверждения изображения")
+			resp.InternalError()
+			return resp
+		}
+
+		switch status {
+		case models.ImageStatusConfirmed:
+			resp.Confirmed = append(resp.Confirmed, image.Key)
+		case models.ImageStatusRejected:
+			resp.Rejected = append(resp.Rejected, image.Key)
+		default:
+			resp.Missing = append(resp.Missing, image.Key)
+		}
+	}
+
+	resp.StatusOK()
+	return resp
+}
+
+func (s *Service) ReadProduct(ctx context.Context, id int) models.ResponseReadProduct {
+
+	resp := models.ResponseReadProduct{}
+
+	product := models.Product{}
 
-	resp.ProductResponse, err = createDownloadURLs(product, s3s)
+	product, err := s.db.ReadProduct(ctx, id)
 	if err != nil {
-		log.Println(err)
+		if errors.Is(err, dbwork.ErrProductNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка получения товара")
 		resp.InternalError()
 		return resp
 	}
+
+	resp.ProductResponse = s.createImageURLs(product)
 	resp.StatusOK()
 	return resp
 
 }
 
-func createDownloadURLs(
-	productDB models.Product,
-	s3s cloudstorage.CloudStorage,
-) (models.ProductResponse, error) {
+// createImageURLs подставляет постоянные ссылки на изображения. Ключи
+// объектов не меняются, поэтому ответы и сами файлы можно кешировать.
+func (s *Service) createImageURLs(productDB models.Product) models.ProductResponse {
 
 	product := models.ProductResponse{
 		ID:          productDB.ID,
@@ -94,31 +224,60 @@ func createDownloadURLs(
 		Price:       productDB.Price,
 	}
 
-	URLs := make([]string, 0, len(productDB.Images))
+	images := make([]models.ImageResponse, 0, len(productDB.Images))
+	hasPrimary := false
 	for _, image := range productDB.Images {
+		// Незагруженные файлы клиентам не показываем
+		if image.Status != models.ImageStatusConfirmed {
+			continue
+		}
 
-		image, err := s3s.DownloadURL(image.Key)
-		if err != nil {
-			return product, fmt.Errorf("createDownloadURLs ошибка создания url: %w", err)
+		alt := image.Alt
+		if alt == nil {
+			alt = map[string]string{}
 		}
 
-		URLs = append(URLs, image.FileID)
+		original := s.imageURL(image.Key)
+		images = append(images, models.ImageResponse{
+			ID:        image.ID,
+			URL:       original,
+			Alt:       alt,
+			Position:  image.Position,
+			IsPrimary: image.IsPrimary,
+			Variants: models.ImageVariants{
+				Thumbnail: s.variantURL(image.ThumbnailKey, original),
+				Card:      s.variantURL(image.CardKey, original),
+				Full:      s.variantURL(image.FullKey, original),
+			},
+		})
+		hasPrimary = hasPrimary || image.IsPrimary
+	}
 
+	// Главное изображение могло так и не загрузиться, тогда главным
+	// считаем первое из загруженных
+	if !hasPrimary && len(images) > 0 {
+		images[0].IsPrimary = true
 	}
-	product.Images = URLs
-	return product, nil
+
+	product.Images = images
+	return product
 }
 
-func ReadAllProduct() models.ResponseReadAllProduct {
-	resp := models.ResponseReadAllProduct{}
+// variantURL возвращает ссылку на вариант или на оригинал, если
+// вариант ещё не создан.
+func (s *Service) variantURL(key, original string) string {
+	if key == "" {
+		return original
+	}
+	return s.imageURL(key)
+}
 
-	pool := connectionpool.NewConnectionPool()
-	s3s := pool.GetS3Storage()
-	dataBase := pool.GetDataBase()
+func (s *Service) ReadAllProduct(ctx context.Context) models.ResponseReadAllProduct {
+	resp := models.ResponseReadAllProduct{}
 
-	products, err := dataBase.ReadListProduct()
+	products, err := s.db.ReadListProduct(ctx)
 	if err != nil {
-		log.Println(err)
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка получения списка товаров")
 		resp.InternalError()
 		return resp
 	}
@@ -126,84 +285,182 @@ func ReadAllProduct() models.ResponseReadAllProduct {
 	resp.Products = make([]models.ProductResponse, 0, len(products))
 
 	for _, product := range products {
-		tempProduct, err := createDownloadURLs(product, s3s)
-		if err != nil {
-			log.Println(err)
-			resp.InternalError()
-			return resp
-		}
-		resp.Products = append(resp.Products, tempProduct)
+		resp.Products = append(resp.Products, s.createImageURLs(product))
 	}
 
 	resp.StatusOK()
 	return resp
 }
 
-func ChangeCountProduct(req models.RequestChangeCount) models.Response {
+func (s *Service) ChangeCountProduct(ctx context.Context, req models.RequestChangeCount) models.Response {
 	resp := models.Response{}
 
-	psql, err := dbwork.NewPostgreSQL(dbwork.LoadPSQLConfig())
+	count, err := s.db.ChangeCountProduct(ctx, req.ID, req.Count)
 	if err != nil {
-		log.Println(err)
+		switch {
+		case errors.Is(err, dbwork.ErrProductNotFound):
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		case errors.Is(err, dbwork.ErrInsufficientStock):
+			resp.Error(http.StatusConflict, response.CodeInsufficientStock, err.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка изменения количества товара")
 		resp.InternalError()
 		return resp
 	}
+	metrics.Event(metrics.EventStockChanged)
+	audit.Record(ctx, s.db, audit.ActionProductStock, productTarget(req.ID),
+		map[string]int{"count": count - req.Count}, map[string]int{"count": count})
+
+	resp.StatusOK()
+	return resp
+}
+
+var ErrInvalidProduct = errors.New("Название товара не может быть пустым, цена - отрицательной")
+
+func (s *Service) UpdateProduct(ctx context.Context, id int, req models.RequestUpdateProduct) models.Response {
+	resp := models.Response{}
+
+	if strings.TrimSpace(req.Name) == "" || req.Price < 0 {
+		resp.Error(http.StatusBadRequest, response.CodeValidation, ErrInvalidProduct.Error())
+		return resp
+	}
 
-	err = psql.ChangeCountProduct(req.ID, req.Count)
+	// Товар читается до изменения, чтобы в журнале было прежнее состояние
+	product, err := s.db.ReadProduct(ctx, id)
+	before := newProductState(product)
+	product.Name, product.Description, product.Parameters, product.Price = req.Name, req.Description, req.Parameters, req.Price
+	if err == nil {
+		err = s.db.UpdateProduct(ctx, product)
+	}
 	if err != nil {
-		log.Println(err)
+		if errors.Is(err, dbwork.ErrProductNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка изменения товара")
 		resp.InternalError()
 		return resp
 	}
+	audit.Record(ctx, s.db, audit.ActionProductUpdate, productTarget(id), before, newProductState(product))
 
 	resp.StatusOK()
 	return resp
 }
 
-func DeleteProduct(id int) models.Response {
+func (s *Service) DeleteProduct(ctx context.Context, id int) models.Response {
 	resp := models.Response{}
 
-	pool := connectionpool.NewConnectionPool()
-	dataBase := pool.GetDataBase()
-	s3s := pool.GetS3Storage()
-
-	keys, err := dataBase.DeleteProduct(id)
+	// Товар читается до удаления, чтобы его последнее состояние осталось
+	// в журнале аудита
+	product, err := s.db.ReadProduct(ctx, id)
+	if err == nil {
+		_, err = s.db.DeleteProduct(ctx, id)
+	}
 	if err != nil {
-		log.Println(err)
+		if errors.Is(err, dbwork.ErrProductNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка удаления товара")
 		resp.InternalError()
 		return resp
 	}
+	audit.Record(ctx, s.db, audit.ActionProductDelete, productTarget(id), newProductState(product), nil)
 
-	for _, key := range keys {
-		image, err := s3s.DeleteURL(key)
-		if err != nil {
-			log.Println(err)
-			resp.InternalError()
+	// Файлы удаляются из хранилища фоновой задачей с повторами
+	s.wakeStorageCleanup()
+
+	resp.StatusOK()
+	return resp
+
+}
+
+func (s *Service) ReorderImages(ctx context.Context, productID int, req models.RequestReorderImages) models.Response {
+	resp := models.Response{}
+
+	// Товар читается до изменения, чтобы в журнале было прежнее состояние
+	product, err := s.db.ReadProduct(ctx, productID)
+	if err == nil {
+		err = s.db.ReorderImages(ctx, productID, req.ImageIDs)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, dbwork.ErrProductNotFound):
+			resp.Error(http.StatusNotFound, response.CodeProductNotFound, err.Error())
+			return resp
+		case errors.Is(err, dbwork.ErrImageOrderMismatch):
+			resp.Error(http.StatusBadRequest, response.CodeImageOrderMismatch, err.Error())
 			return resp
 		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка изменения порядка изображений")
+		resp.InternalError()
+		return resp
+	}
+	audit.Record(ctx, s.db, audit.ActionProductImagesOrder, productTarget(productID),
+		imageOrderState(product), map[string][]int{"image_ids": req.ImageIDs})
 
-		r, err := http.NewRequest("DELETE", image.URL, nil)
+	resp.StatusOK()
+	return resp
+}
 
-		client := http.Client{}
+func (s *Service) SetPrimaryImage(ctx context.Context, productID, imageID int) models.Response {
+	resp := models.Response{}
 
-		re, err := client.Do(r)
-		if err != nil {
-			log.Println(err)
-			resp.InternalError()
+	product, err := s.db.ReadProduct(ctx, productID)
+	if err == nil {
+		err = s.db.SetPrimaryImage(ctx, productID, imageID)
+	}
+	if err != nil {
+		if errors.Is(err, dbwork.ErrProductNotFound) || errors.Is(err, dbwork.ErrImageNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeImageNotFound, dbwork.ErrImageNotFound.Error())
 			return resp
 		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка выбора главного изображения")
+		resp.InternalError()
+		return resp
+	}
+	audit.Record(ctx, s.db, audit.ActionProductImagePrimary, productTarget(productID),
+		primaryImageState(product), map[string]int{"image_id": imageID})
 
-		defer re.Body.Close()
+	resp.StatusOK()
+	return resp
+}
 
-		if re.StatusCode != http.StatusNoContent {
-			log.Println(err)
-			resp.InternalError()
+const maxAltLength = 300
+
+var (
+	altLanguage   = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	ErrInvalidAlt = errors.New("Альтернативный текст задаётся по кодам языков и не длиннее 300 символов")
+)
+
+func (s *Service) SetImageAlt(ctx context.Context, productID, imageID int, req models.RequestImageAlt) models.Response {
+	resp := models.Response{}
+
+	for language, text := range req.Alt {
+		if !altLanguage.MatchString(language) || utf8.RuneCountInString(text) > maxAltLength {
+			resp.Error(http.StatusBadRequest, response.CodeValidation, ErrInvalidAlt.Error())
 			return resp
 		}
+	}
 
+	product, err := s.db.ReadProduct(ctx, productID)
+	if err == nil {
+		err = s.db.SetImageAlt(ctx, productID, imageID, req.Alt)
 	}
+	if err != nil {
+		if errors.Is(err, dbwork.ErrProductNotFound) || errors.Is(err, dbwork.ErrImageNotFound) {
+			resp.Error(http.StatusNotFound, response.CodeImageNotFound, dbwork.ErrImageNotFound.Error())
+			return resp
+		}
+		log.Ctx(ctx).Error().Err(err).Msg("Ошибка изменения описания изображения")
+		resp.InternalError()
+		return resp
+	}
+	audit.Record(ctx, s.db, audit.ActionProductImageAlt, productTarget(productID),
+		imageAltState(imageID, imageAlt(product, imageID)), imageAltState(imageID, req.Alt))
 
 	resp.StatusOK()
 	return resp
-
 }
@@ -1,43 +1,105 @@
 package main
 
 import (
-	"manage-service/pkg/handlers"
-	"manage-service/pkg/middleware"
-	"time"
+	"common/config"
+	"common/health"
+	"common/logger"
+	"common/metrics"
+	"common/tracing"
+	"context"
+	"manage-service/pkg/client"
+	"manage-service/pkg/discovery"
+	"manage-service/pkg/outbound"
+	"manage-service/pkg/ratelimit"
+	"manage-service/pkg/router"
+	"net/http"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 )
 
 func main() {
 
-	r := gin.Default()
+	health.Probe()
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	log := logger.New("manage_service")
 
-	public := r.Group("/")
-	{
-		public.POST("/registration", handlers.Registration)
-		public.POST("/login", handlers.Login)
-		public.GET("/product", handlers.GetAllProduct)
-		public.GET("/product/:id", handlers.GetProduct)
+	tracingCfg, err := tracing.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации трассировки: %v", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), "manage_service", tracingCfg)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка настройки трассировки: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	cfg, err := client.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации клиентов: %v", err)
 	}
 
-	protected := r.Group("/")
-	protected.Use(middleware.AuthMiddleware())
-	{
-		protected.POST("/logout", handlers.Logout)
-		protected.POST("/product")
-		protected.DELETE("/product/:id")
-		protected.PUT("/product/change")
+	limits, err := ratelimit.LoadConfig()
+	if err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации ограничения запросов: %v", err)
+	}
+	store, err := ratelimit.NewStore(limits)
+	if err != nil {
+		log.Fatal().Msgf("Ошибка подключения хранилища ограничения запросов: %v", err)
 	}
 
-	r.Run(":8080")
+	// Адреса экземпляров меняются на лету: http запросы распределяет
+	// Balancer, gRPC вызовы - балансировщик round_robin соединения
+	registry := discovery.NewRegistry(cfg.Services, cfg.Ejection, log)
+	if cfg.ServicesFile != "" {
+		go registry.Watch(context.Background(), cfg.ServicesFile, cfg.EnvServices, cfg.ServicesReload)
+	}
+
+	// У каждого сервиса своя цепь и свой лимит запросов: отказ одного
+	// не должен занимать соединения, нужные для других. Каждая попытка
+	// запроса получает свой спан и передаёт X-Request-ID и traceparent
+	auth := outbound.New("auth", cfg.Auth, tracing.Transport(registry.Balancer("auth")))
+	authoriz := outbound.New("authoriz", cfg.Authoriz, tracing.Transport(registry.Balancer("authoriz")))
+	core := outbound.New("core", cfg.Core, tracing.Transport(registry.Balancer("core")))
+
+	dial := func(transport *outbound.Transport) *grpc.ClientConn {
+		target, opts := registry.Target(transport.Name())
+		conn, err := client.Dial(target, cfg.TLS, transport, opts...)
+		if err != nil {
+			log.Fatal().Msgf("Ошибка подключения к %s: %v", transport.Name(), err)
+		}
+		return conn
+	}
+	authConn := dial(auth)
+	defer authConn.Close()
+	authorizConn := dial(authoriz)
+	defer authorizConn.Close()
+	coreConn := dial(core)
+	defer coreConn.Close()
+
+	// Хост в адресах условный: Balancer подставляет адрес экземпляра
+	r := router.New(
+		client.NewAuth("http://auth", auth.Client(), authConn),
+		client.NewAuthoriz("http://authoriz", authoriz.Client(), authorizConn),
+		client.NewCore("http://core", core.Client(), coreConn),
+		store, limits.Policies,
+		auth, authoriz, core,
+	)
+	// Адрес клиента для ограничения запросов берётся из X-Forwarded-For
+	// только у доверенных прокси
+	if err = r.SetTrustedProxies(limits.TrustedProxies); err != nil {
+		log.Fatal().Msgf("Ошибка конфигурации доверенных прокси: %v", err)
+	}
+
+	// Метрики отдаются на отдельном порту, который не публикуется наружу:
+	// в них названия маршрутов, задержки сервисов и счётчики магазина
+	metricsAddr := (&config.Loader{}).StringOr("metrics_addr", ":9090")
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, metrics.Handler()); err != nil {
+			log.Error().Msgf("Ошибка работы сервера метрик: %v", err)
+		}
+	}()
+
+	if err = http.ListenAndServe(":8080", tracing.Handler("manage_service", log, r)); err != nil {
+		log.Fatal().Msgf("Ошибка работы сервера: %v", err)
+	}
 }
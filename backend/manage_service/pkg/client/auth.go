@@ -0,0 +1,140 @@
+package client
+
+import (
+	"common/audit"
+	"common/proto/authpb"
+	"context"
+	"manage-service/pkg/models"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"google.golang.org/grpc"
+)
+
+// Auth - клиент authentication_service. Вход и регистрация идут через
+// gRPC, профиль и адреса - через http API.
+type Auth struct {
+	base
+	rpc authpb.AuthClient
+}
+
+func NewAuth(baseURL string, httpClient *http.Client, conn grpc.ClientConnInterface) *Auth {
+	return &Auth{base: base{url: baseURL, http: httpClient}, rpc: authpb.NewAuthClient(conn)}
+}
+
+// Register создаёт пользователя и возвращает его id и уровень доступа.
+func (a *Auth) Register(ctx context.Context, user models.User) (string, bool, error) {
+	resp, err := a.rpc.Register(ctx, &authpb.Credentials{Login: user.Login, Password: user.Password})
+	if err != nil {
+		return "", false, rpcError("Auth.Register", err)
+	}
+	return resp.GetId(), resp.GetAdmin(), nil
+}
+
+// Login проверяет логин и пароль и возвращает id и уровень доступа.
+func (a *Auth) Login(ctx context.Context, user models.User) (string, bool, error) {
+	resp, err := a.rpc.Login(ctx, &authpb.Credentials{Login: user.Login, Password: user.Password})
+	if err != nil {
+		return "", false, rpcError("Auth.Login", err)
+	}
+	return resp.GetId(), resp.GetAdmin(), nil
+}
+
+func (a *Auth) GetProfile(ctx context.Context, GUID string) (models.Profile, error) {
+	resp := models.ResponseProfile{}
+	if err := a.do(ctx, http.MethodGet, userPath(GUID), nil, &resp); err != nil {
+		return models.Profile{}, err
+	}
+	return resp.Profile, nil
+}
+
+func (a *Auth) UpdateProfile(ctx context.Context, GUID string, req models.RequestUpdateProfile) (models.Profile, error) {
+	resp := models.ResponseProfile{}
+	if err := a.do(ctx, http.MethodPatch, userPath(GUID), req, &resp); err != nil {
+		return models.Profile{}, err
+	}
+	return resp.Profile, nil
+}
+
+func (a *Auth) ChangePassword(ctx context.Context, GUID string, req models.RequestChangePassword) error {
+	return a.do(ctx, http.MethodPut, userPath(GUID)+"/password", req, nil)
+}
+
+func (a *Auth) GrantAdmin(ctx context.Context, GUID string) error {
+	return a.do(ctx, http.MethodPut, userPath(GUID)+"/admin", nil, nil)
+}
+
+func (a *Auth) ListAddresses(ctx context.Context, GUID string) ([]models.Address, error) {
+	resp := models.ResponseAddresses{}
+	if err := a.do(ctx, http.MethodGet, userPath(GUID)+"/address", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Addresses, nil
+}
+
+func (a *Auth) CreateAddress(ctx context.Context, GUID string, req models.RequestAddress) (models.Address, error) {
+	resp := models.ResponseAddress{}
+	if err := a.do(ctx, http.MethodPost, userPath(GUID)+"/address", req, &resp); err != nil {
+		return models.Address{}, err
+	}
+	return resp.Address, nil
+}
+
+func (a *Auth) UpdateAddress(ctx context.Context, GUID string, id int64, req models.RequestAddress) (models.Address, error) {
+	resp := models.ResponseAddress{}
+	if err := a.do(ctx, http.MethodPut, addressPath(GUID, id), req, &resp); err != nil {
+		return models.Address{}, err
+	}
+	return resp.Address, nil
+}
+
+func (a *Auth) DeleteAddress(ctx context.Context, GUID string, id int64) error {
+	return a.do(ctx, http.MethodDelete, addressPath(GUID, id), nil, nil)
+}
+
+func (a *Auth) SetDefaultAddress(ctx context.Context, GUID string, id int64) error {
+	return a.do(ctx, http.MethodPut, addressPath(GUID, id)+"/default", nil, nil)
+}
+
+// ExportUser возвращает все данные пользователя, которые хранит
+// authentication_service.
+func (a *Auth) ExportUser(ctx context.Context, GUID string) (models.ResponseUserExport, error) {
+	resp := models.ResponseUserExport{}
+	err := a.do(ctx, http.MethodGet, userPath(GUID)+"/export", nil, &resp)
+	return resp, err
+}
+
+func (a *Auth) GetDeletion(ctx context.Context, GUID string) (models.Deletion, error) {
+	resp := models.ResponseDeletion{}
+	if err := a.do(ctx, http.MethodGet, userPath(GUID)+"/deletion", nil, &resp); err != nil {
+		return models.Deletion{}, err
+	}
+	return resp.Deletion, nil
+}
+
+func (a *Auth) RequestDeletion(ctx context.Context, GUID string) (models.Deletion, error) {
+	resp := models.ResponseDeletion{}
+	if err := a.do(ctx, http.MethodPost, userPath(GUID)+"/deletion", nil, &resp); err != nil {
+		return models.Deletion{}, err
+	}
+	return resp.Deletion, nil
+}
+
+func (a *Auth) CancelDeletion(ctx context.Context, GUID string) error {
+	return a.do(ctx, http.MethodDelete, userPath(GUID)+"/deletion", nil, nil)
+}
+
+func userPath(GUID string) string {
+	return "/user/" + url.PathEscape(GUID)
+}
+
+func addressPath(GUID string, id int64) string {
+	return userPath(GUID) + "/address/" + strconv.FormatInt(id, 10)
+}
+
+// Audit читает журнал аудита authentication_service: регистрации, входы,
+// смены пароля и удаления аккаунтов.
+func (a *Auth) Audit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return a.auditLog(ctx, filter)
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"common/audit"
+	"common/proto/authzpb"
+	"context"
+	"manage-service/pkg/models"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"google.golang.org/grpc"
+)
+
+// Authoriz - клиент authorization_service. Токены и сессии - через gRPC,
+// чтобы токены не попадали в адреса запросов, API ключи - через http API.
+type Authoriz struct {
+	base
+	rpc authzpb.AuthzClient
+}
+
+func NewAuthoriz(baseURL string, httpClient *http.Client, conn grpc.ClientConnInterface) *Authoriz {
+	return &Authoriz{base: base{url: baseURL, http: httpClient}, rpc: authzpb.NewAuthzClient(conn)}
+}
+
+// Authorize открывает сессию пользователя и выдаёт пару токенов.
+func (a *Authoriz) Authorize(ctx context.Context, GUID string, admin bool) (models.Tokens, error) {
+	resp, err := a.rpc.IssueTokens(ctx, &authzpb.IssueTokensRequest{UserId: GUID, Admin: admin})
+	if err != nil {
+		return models.Tokens{}, rpcError("Authoriz.IssueTokens", err)
+	}
+	return models.Tokens{Access: resp.GetAccess(), Refresh: resp.GetRefresh()}, nil
+}
+
+// User возвращает id и уровень доступа владельца access токена,
+// если его сессия активна.
+func (a *Authoriz) User(ctx context.Context, access string) (string, bool, error) {
+	resp, err := a.rpc.Introspect(ctx, &authzpb.IntrospectRequest{Access: access})
+	if err != nil {
+		return "", false, rpcError("Authoriz.Introspect", err)
+	}
+	return resp.GetUserId(), resp.GetAdmin(), nil
+}
+
+func (a *Authoriz) Refresh(ctx context.Context, access, refresh string) (models.Tokens, error) {
+	resp, err := a.rpc.Refresh(ctx, &authzpb.Tokens{Access: access, Refresh: refresh})
+	if err != nil {
+		return models.Tokens{}, rpcError("Authoriz.Refresh", err)
+	}
+	return models.Tokens{Access: resp.GetAccess(), Refresh: resp.GetRefresh()}, nil
+}
+
+func (a *Authoriz) Logout(ctx context.Context, access string) error {
+	if _, err := a.rpc.Logout(ctx, &authzpb.LogoutRequest{Access: access}); err != nil {
+		return rpcError("Authoriz.Logout", err)
+	}
+	return nil
+}
+
+func (a *Authoriz) UserSessions(ctx context.Context, GUID string) (models.ResponseSessions, error) {
+	resp := models.ResponseSessions{}
+	err := a.do(ctx, http.MethodGet, "/user/"+url.PathEscape(GUID)+"/sessions", nil, &resp)
+	return resp, err
+}
+
+// CheckAPIKey проверяет ключ и адрес, с которого он передан.
+func (a *Authoriz) CheckAPIKey(ctx context.Context, key, ip string) (models.APIKey, error) {
+	resp := models.ResponseAPIKey{}
+	req := models.RequestCheckAPIKey{Key: key, IP: ip}
+	if err := a.do(ctx, http.MethodPost, "/apikey/check", req, &resp); err != nil {
+		return models.APIKey{}, err
+	}
+	return resp.APIKey, nil
+}
+
+// CreateAPIKey возвращает ключ целиком и его описание. Ключ целиком
+// больше нигде не хранится.
+func (a *Authoriz) CreateAPIKey(ctx context.Context, req models.RequestCreateAPIKey) (string, models.APIKey, error) {
+	resp := models.ResponseAPIKey{}
+	if err := a.do(ctx, http.MethodPost, "/apikey", req, &resp); err != nil {
+		return "", models.APIKey{}, err
+	}
+	return resp.Key, resp.APIKey, nil
+}
+
+func (a *Authoriz) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	resp := models.ResponseAPIKeys{}
+	if err := a.do(ctx, http.MethodGet, "/apikey", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.APIKeys, nil
+}
+
+func (a *Authoriz) RevokeAPIKey(ctx context.Context, id int64) error {
+	return a.do(ctx, http.MethodDelete, "/apikey/"+strconv.FormatInt(id, 10), nil, nil)
+}
+
+// Audit читает журнал аудита authorization_service: выдачу и отзыв API
+// ключей.
+func (a *Authoriz) Audit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return a.auditLog(ctx, filter)
+}
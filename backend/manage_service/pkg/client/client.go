@@ -0,0 +1,258 @@
+// Package client содержит типизированные клиенты сервисов, к которым
+// обращается manage_service. Методы повторяют операции из api/openapi.json
+// каждого сервиса.
+package client
+
+import (
+	"bytes"
+	"common/audit"
+	"common/config"
+	"common/health"
+	"common/response"
+	"common/rpc"
+	"common/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"manage-service/pkg/discovery"
+	"manage-service/pkg/outbound"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type Config struct {
+	// Адреса экземпляров сервисов: auth, authoriz и core
+	Services discovery.Services
+	// EnvServices - адреса из переменных окружения, поверх них читается
+	// yaml файл ServicesFile. Файл перечитывается раз в ServicesReload
+	EnvServices    discovery.Services
+	ServicesFile   string
+	ServicesReload time.Duration
+	Ejection       discovery.Ejection
+	TLS            rpc.TLS
+
+	Auth     outbound.Policy
+	Authoriz outbound.Policy
+	Core     outbound.Policy
+}
+
+// LoadConfig читает адреса сервисов auth_url, authoriz_url, core_url,
+// их gRPC API auth_grpc_addr, authoriz_grpc_addr, core_grpc_addr
+// (по умолчанию адреса в docker compose), настройки TLS из rpc.LoadTLS
+// и политики запросов к сервисам.
+// В каждой переменной адреса можно перечислить через запятую, если у
+// сервиса несколько экземпляров. Файл services_file задаёт адреса поверх
+// переменных и перечитывается раз в services_reload_seconds. Экземпляр,
+// который lb_eject_failures раз подряд не ответил, исключается из
+// балансировки на lb_eject_seconds.
+// Время попытки у каждого сервиса своё: auth_timeout_seconds,
+// authoriz_timeout_seconds и core_timeout_seconds, core отдаёт изображения
+// и поэтому ждёт дольше. Повторы, размыкание цепи и ограничение
+// одновременных запросов общие: client_retries, client_backoff_ms,
+// client_max_backoff_ms, breaker_failures, breaker_open_seconds и
+// client_max_concurrent.
+func LoadConfig() (Config, error) {
+	l := &config.Loader{}
+
+	shared := outbound.Policy{
+		Retries:          l.IntOr("client_retries", 2, 0, 10),
+		MinBackoff:       l.DurationOr("client_backoff_ms", time.Millisecond, 50*time.Millisecond, 1),
+		MaxBackoff:       l.DurationOr("client_max_backoff_ms", time.Millisecond, time.Second, 1),
+		MaxConcurrent:    l.IntOr("client_max_concurrent", 64, 1, 10000),
+		FailureThreshold: l.IntOr("breaker_failures", 5, 1, 1000),
+		OpenTimeout:      l.DurationOr("breaker_open_seconds", time.Second, 10*time.Second, 1),
+	}
+	withTimeout := func(key string, fallback time.Duration) outbound.Policy {
+		policy := shared
+		policy.Timeout = l.DurationOr(key, time.Second, fallback, 1)
+		return policy
+	}
+	service := func(httpKey, httpFallback, grpcKey, grpcFallback string) discovery.Service {
+		return discovery.Service{
+			HTTP: l.ListOr(httpKey, httpFallback),
+			GRPC: l.ListOr(grpcKey, grpcFallback),
+		}
+	}
+
+	cfg := Config{
+		ServicesFile:   l.StringOr("services_file", ""),
+		ServicesReload: l.DurationOr("services_reload_seconds", time.Second, 5*time.Second, 1),
+		Ejection: discovery.Ejection{
+			Failures: l.IntOr("lb_eject_failures", 3, 1, 1000),
+			Duration: l.DurationOr("lb_eject_seconds", time.Second, 30*time.Second, 1),
+		},
+
+		Auth:     withTimeout("auth_timeout_seconds", 6*time.Second),
+		Authoriz: withTimeout("authoriz_timeout_seconds", 6*time.Second),
+		Core:     withTimeout("core_timeout_seconds", 30*time.Second),
+	}
+	cfg.EnvServices = discovery.Services{
+		"auth":     service("auth_url", "http://auth_service:8081", "auth_grpc_addr", "auth_service:9081"),
+		"authoriz": service("authoriz_url", "http://autoriz_service:8083", "authoriz_grpc_addr", "autoriz_service:9083"),
+		"core":     service("core_url", "http://core_service:8082", "core_grpc_addr", "core_service:9082"),
+	}
+	tlsCfg, tlsErr := rpc.LoadTLS()
+	cfg.TLS = tlsCfg
+
+	services, servicesErr := discovery.Load(cfg.ServicesFile, cfg.EnvServices)
+	cfg.Services = services
+
+	if err := errors.Join(l.Err(), tlsErr, servicesErr); err != nil {
+		return cfg, fmt.Errorf("client/LoadConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+// Dial открывает соединение с gRPC API сервиса. Каждый вызов проходит
+// через transport: у него та же цепь и тот же лимит запросов, что у http
+// запросов к этому сервису, а срок попытки задаёт его политика.
+func Dial(target string, tlsCfg rpc.TLS, transport *outbound.Transport, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds, err := tlsCfg.ClientCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("client/Dial %s: %w", target, err)
+	}
+
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(transport.UnaryClientInterceptor()),
+		tracing.DialOption(),
+	}, opts...)
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("client/Dial %s: %w", target, err)
+	}
+	return conn, nil
+}
+
+// ResponseError - клиентская ошибка сервиса в формате problem+json,
+// которую можно вернуть клиенту как есть.
+type ResponseError struct {
+	Problem response.Problem
+}
+
+func (err *ResponseError) Error() string {
+	return err.Problem.Detail
+}
+
+// Code возвращает стабильный код клиентской ошибки сервиса или пустую
+// строку, если err не является ResponseError.
+func Code(err error) response.ErrorCode {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.Problem.Code
+	}
+	return ""
+}
+
+// base выполняет json запросы к одному сервису.
+type base struct {
+	url  string
+	http *http.Client
+}
+
+// Ready проверяет готовность сервиса по его /readyz.
+func (b base) Ready(ctx context.Context) error {
+	return ready(ctx, b.http, b.url)
+}
+
+func ready(ctx context.Context, httpClient *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+health.ReadyPath, nil)
+	if err != nil {
+		return fmt.Errorf("Ready http.NewRequest: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Ready: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Ready: сервис не готов, статус %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// do отправляет in в теле запроса, если он не nil, и разбирает ответ в out.
+// Ответы со статусом 4xx возвращаются как ResponseError, 5xx - как обычная
+// ошибка, чтобы наружу не уходили детали.
+func (b base) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("%s %s json.Marshal: %w", method, path, err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.url+path, body)
+	if err != nil {
+		return fmt.Errorf("%s %s http.NewRequest: %w", method, path, err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s io.ReadAll: %w", method, path, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return problemError(resp.StatusCode, data)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s json.Unmarshal: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// problemError превращает клиентские ошибки сервиса в ResponseError,
+// а серверные - в обычную ошибку.
+func problemError(status int, body []byte) error {
+	problem := response.Problem{}
+	if err := json.Unmarshal(body, &problem); err != nil || problem.Status != status {
+		problem = response.NewProblem(status, "", http.StatusText(status))
+	}
+
+	if status < http.StatusInternalServerError {
+		return &ResponseError{Problem: problem}
+	}
+
+	return fmt.Errorf("Ошибка на стороне сервиса: %d %v", status, problem.Detail)
+}
+
+// rpcError, как и problemError, возвращает клиентские ошибки сервиса как
+// ResponseError, а остальные оборачивает, сохраняя статус gRPC.
+func rpcError(method string, err error) error {
+	if problem, ok := rpc.Problem(err); ok && problem.Status < http.StatusInternalServerError {
+		return &ResponseError{Problem: problem}
+	}
+	return fmt.Errorf("%s: %w", method, err)
+}
+
+// auditLog читает журнал аудита сервиса, см. audit.Handler.
+func (b base) auditLog(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	result := audit.Result{}
+	if err := b.do(ctx, http.MethodGet, audit.Path+"?"+filter.Values().Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
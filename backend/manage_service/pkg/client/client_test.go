@@ -0,0 +1,379 @@
+package client_test
+
+import (
+	"common/proto/authpb"
+	"common/proto/authzpb"
+	"common/proto/catalogpb"
+	"common/response"
+	"common/rpc"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"manage-service/pkg/client"
+	"manage-service/pkg/discovery"
+	"manage-service/pkg/models"
+	"manage-service/pkg/outbound"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// request - то, что клиент отправил сервису.
+type request struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+// newServer отвечает на любой запрос статусом status и телом body и
+// запоминает последний запрос.
+func newServer(t *testing.T, status int, body string) (*httptest.Server, *request) {
+	got := &request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		*got = request{Method: r.Method, Path: r.URL.EscapedPath(), Body: string(data)}
+
+		contentType := "application/json"
+		if status >= http.StatusBadRequest {
+			contentType = response.ProblemContentType
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, got
+}
+
+func problemBody(status int, code response.ErrorCode, detail string) string {
+	data, _ := json.Marshal(response.NewProblem(status, code, detail))
+	return string(data)
+}
+
+// dial запускает gRPC сервер в памяти, регистрирует на нём сервисы и
+// возвращает соединение с ним.
+func dial(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	server := grpc.NewServer()
+	register(server)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// fakeAuth отвечает user или err и запоминает последние учётные данные.
+type fakeAuth struct {
+	authpb.UnimplementedAuthServer
+	user *authpb.User
+	err  error
+	got  *authpb.Credentials
+}
+
+func (f *fakeAuth) Register(_ context.Context, req *authpb.Credentials) (*authpb.User, error) {
+	f.got = req
+	return f.user, f.err
+}
+
+func (f *fakeAuth) Login(_ context.Context, req *authpb.Credentials) (*authpb.User, error) {
+	f.got = req
+	return f.user, f.err
+}
+
+func newAuth(t *testing.T, fake *fakeAuth) *client.Auth {
+	conn := dial(t, func(server *grpc.Server) { authpb.RegisterAuthServer(server, fake) })
+	return client.NewAuth("http://auth", http.DefaultClient, conn)
+}
+
+type fakeAuthz struct {
+	authzpb.UnimplementedAuthzServer
+	got proto.Message
+}
+
+func (f *fakeAuthz) Introspect(_ context.Context, req *authzpb.IntrospectRequest) (*authzpb.Principal, error) {
+	f.got = req
+	return &authzpb.Principal{UserId: "id", Admin: true}, nil
+}
+
+func (f *fakeAuthz) Refresh(_ context.Context, req *authzpb.Tokens) (*authzpb.Tokens, error) {
+	f.got = req
+	return &authzpb.Tokens{Access: "new access", Refresh: "new refresh"}, nil
+}
+
+func (f *fakeAuthz) Logout(_ context.Context, req *authzpb.LogoutRequest) (*authzpb.LogoutResponse, error) {
+	f.got = req
+	return nil, status.Error(codes.Internal, "подробности")
+}
+
+func newAuthoriz(t *testing.T, fake *fakeAuthz) *client.Authoriz {
+	conn := dial(t, func(server *grpc.Server) { authzpb.RegisterAuthzServer(server, fake) })
+	return client.NewAuthoriz("http://authoriz", http.DefaultClient, conn)
+}
+
+func TestAuthLogin(t *testing.T) {
+	fake := &fakeAuth{user: &authpb.User{Id: "0b6f2a52-3e0e-4b55-9c3d-8a1d6f0c1e11", Admin: true}}
+
+	GUID, admin, err := newAuth(t, fake).Login(context.Background(), models.User{Login: "user", Password: "pass"})
+	require.NoError(t, err)
+	assert.Equal(t, "0b6f2a52-3e0e-4b55-9c3d-8a1d6f0c1e11", GUID)
+	assert.True(t, admin)
+	assert.Equal(t, "user", fake.got.GetLogin())
+	assert.Equal(t, "pass", fake.got.GetPassword())
+}
+
+func TestAuthRegister(t *testing.T) {
+	fake := &fakeAuth{user: &authpb.User{Id: "id"}}
+
+	GUID, admin, err := newAuth(t, fake).Register(context.Background(), models.User{Login: "user", Password: "pass"})
+	require.NoError(t, err)
+	assert.Equal(t, "id", GUID)
+	assert.False(t, admin)
+}
+
+func TestAuthPaths(t *testing.T) {
+	server, got := newServer(t, http.StatusOK,
+		`{"code":200,"message":"ok","address":{"id":7,"city":"Москва","street":"Тверская","house":"1"}}`)
+	auth := client.NewAuth(server.URL, server.Client(), nil)
+	ctx := context.Background()
+
+	address, err := auth.UpdateAddress(ctx, "user", 7, models.RequestAddress{City: "Москва", Street: "Тверская", House: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), address.ID)
+	assert.Equal(t, "PUT", got.Method)
+	assert.Equal(t, "/user/user/address/7", got.Path)
+	assert.JSONEq(t, `{"city":"Москва","street":"Тверская","house":"1","apartment":"","postal_code":"","is_default":false}`, got.Body)
+
+	require.NoError(t, auth.SetDefaultAddress(ctx, "user", 7))
+	assert.Equal(t, request{"PUT", "/user/user/address/7/default", ""}, *got)
+
+	// id из токена не может выйти за пределы своего пути
+	require.NoError(t, auth.CancelDeletion(ctx, "../apikey"))
+	assert.Equal(t, request{"DELETE", "/user/..%2Fapikey/deletion", ""}, *got)
+}
+
+func TestAuthorizUser(t *testing.T) {
+	fake := &fakeAuthz{}
+
+	// Токен передаётся в теле сообщения, а не в адресе
+	GUID, admin, err := newAuthoriz(t, fake).User(context.Background(), "a.b/c")
+	require.NoError(t, err)
+	assert.Equal(t, "id", GUID)
+	assert.True(t, admin)
+	assert.Equal(t, "a.b/c", fake.got.(*authzpb.IntrospectRequest).GetAccess())
+}
+
+func TestAuthorizRefresh(t *testing.T) {
+	fake := &fakeAuthz{}
+
+	tokens, err := newAuthoriz(t, fake).Refresh(context.Background(), "access", "refresh")
+	require.NoError(t, err)
+	assert.Equal(t, models.Tokens{Access: "new access", Refresh: "new refresh"}, tokens)
+	assert.True(t, proto.Equal(&authzpb.Tokens{Access: "access", Refresh: "refresh"}, fake.got))
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("problem", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusConflict, problemBody(http.StatusConflict, response.CodeLoginTaken, "Логин занят"))
+		err := client.NewAuth(server.URL, server.Client(), nil).ChangePassword(ctx, "id", models.RequestChangePassword{})
+
+		respErr := &client.ResponseError{}
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusConflict, respErr.Problem.Status)
+		assert.Equal(t, "Логин занят", respErr.Problem.Detail)
+		assert.Equal(t, response.CodeLoginTaken, client.Code(err))
+	})
+
+	t.Run("rpc problem", func(t *testing.T) {
+		fake := &fakeAuth{err: rpc.Errorf(http.StatusConflict, response.CodeLoginTaken, "Логин занят")}
+		_, _, err := newAuth(t, fake).Register(ctx, models.User{})
+
+		respErr := &client.ResponseError{}
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusConflict, respErr.Problem.Status)
+		assert.Equal(t, "Логин занят", respErr.Problem.Detail)
+		assert.Equal(t, response.CodeLoginTaken, client.Code(err))
+	})
+
+	t.Run("rpc server error", func(t *testing.T) {
+		err := newAuthoriz(t, &fakeAuthz{}).Logout(ctx, "access")
+
+		require.Error(t, err)
+		assert.False(t, errors.As(err, new(*client.ResponseError)))
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("rpc unavailable", func(t *testing.T) {
+		fake := &fakeAuth{err: status.Error(codes.Unavailable, "connection refused")}
+		_, _, err := newAuth(t, fake).Login(ctx, models.User{})
+
+		assert.False(t, errors.As(err, new(*client.ResponseError)))
+		assert.True(t, outbound.Unavailable(err))
+	})
+
+	t.Run("not problem", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusNotFound, "404 page not found")
+		err := client.NewAuthoriz(server.URL, server.Client(), nil).RevokeAPIKey(ctx, 1)
+
+		respErr := &client.ResponseError{}
+		require.ErrorAs(t, err, &respErr)
+		assert.Equal(t, http.StatusNotFound, respErr.Problem.Status)
+		assert.Equal(t, response.CodeNotFound, client.Code(err))
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusInternalServerError,
+			problemBody(http.StatusInternalServerError, response.CodeInternal, "подробности"))
+		_, err := client.NewAuthoriz(server.URL, server.Client(), nil).ListAPIKeys(ctx)
+
+		require.Error(t, err)
+		assert.False(t, errors.As(err, new(*client.ResponseError)))
+		assert.Empty(t, client.Code(err))
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusOK, "{")
+		_, err := client.NewAuth(server.URL, server.Client(), nil).GetProfile(ctx, "id")
+		assert.Error(t, err)
+	})
+
+	t.Run("context", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusOK, `{}`)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := client.NewAuthoriz(server.URL, server.Client(), nil).ListAPIKeys(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+type fakeCatalog struct {
+	catalogpb.UnimplementedCatalogServer
+	got *catalogpb.ChangeCountRequest
+}
+
+func (f *fakeCatalog) GetProduct(_ context.Context, req *catalogpb.GetProductRequest) (*catalogpb.Product, error) {
+	if req.GetId() != 7 {
+		return nil, rpc.Errorf(http.StatusNotFound, response.CodeProductNotFound, "Товар не найден")
+	}
+	return &catalogpb.Product{Id: 7, Name: "Телефон", Count: 3}, nil
+}
+
+func (f *fakeCatalog) ChangeCount(_ context.Context, req *catalogpb.ChangeCountRequest) (*catalogpb.ChangeCountResponse, error) {
+	f.got = req
+	return &catalogpb.ChangeCountResponse{}, nil
+}
+
+func TestCoreCatalog(t *testing.T) {
+	fake := &fakeCatalog{}
+	conn := dial(t, func(server *grpc.Server) { catalogpb.RegisterCatalogServer(server, fake) })
+	core := client.NewCore("http://core", http.DefaultClient, conn)
+	ctx := context.Background()
+
+	product, err := core.GetProduct(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Телефон", product.GetName())
+	assert.Equal(t, int32(3), product.GetCount())
+
+	_, err = core.GetProduct(ctx, 8)
+	assert.Equal(t, response.CodeProductNotFound, client.Code(err))
+
+	require.NoError(t, core.ChangeCount(ctx, &catalogpb.ChangeCountRequest{Id: 7, Count: 2}))
+	assert.Equal(t, int32(2), fake.got.GetCount())
+}
+
+func TestCoreForward(t *testing.T) {
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("If-None-Match")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	core := client.NewCore(server.URL, server.Client(), nil)
+	resp, err := core.Forward(context.Background(), http.MethodGet, "/images/key.png", nil,
+		http.Header{"If-None-Match": {`"key.png"`}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// Ответы core_service возвращаются как есть, без разбора статуса
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, `"key.png"`, gotHeader)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("auth_url", "http://localhost:8081")
+	t.Setenv("authoriz_url", "")
+	t.Setenv("core_url", "")
+	t.Setenv("auth_timeout_seconds", "2")
+	t.Setenv("client_retries", "1")
+
+	cfg, err := client.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://localhost:8081"}, cfg.Services["auth"].HTTP)
+	assert.Equal(t, []string{"http://autoriz_service:8083"}, cfg.Services["authoriz"].HTTP)
+	assert.Equal(t, []string{"http://core_service:8082"}, cfg.Services["core"].HTTP)
+
+	assert.Equal(t, outbound.Policy{
+		Timeout:          2 * time.Second,
+		Retries:          1,
+		MinBackoff:       50 * time.Millisecond,
+		MaxBackoff:       time.Second,
+		MaxConcurrent:    64,
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+	}, cfg.Auth)
+	assert.Equal(t, 6*time.Second, cfg.Authoriz.Timeout)
+	assert.Equal(t, 30*time.Second, cfg.Core.Timeout)
+	assert.Equal(t, 1, cfg.Core.Retries)
+	assert.Equal(t, []string{"auth_service:9081"}, cfg.Services["auth"].GRPC)
+	assert.Equal(t, []string{"core_service:9082"}, cfg.Services["core"].GRPC)
+	assert.Equal(t, discovery.Ejection{Failures: 3, Duration: 30 * time.Second}, cfg.Ejection)
+
+	t.Setenv("auth_timeout_seconds", "0")
+	_, err = client.LoadConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadConfigServices(t *testing.T) {
+	t.Setenv("auth_url", "http://auth-1:8081, http://auth-2:8081")
+	t.Setenv("auth_grpc_addr", "auth-1:9081,auth-2:9081")
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("services:\n  core:\n    grpc: [core-1:9082, core-2:9082]\n"), 0o600))
+	t.Setenv("services_file", path)
+
+	cfg, err := client.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://auth-1:8081", "http://auth-2:8081"}, cfg.Services["auth"].HTTP)
+	assert.Equal(t, []string{"auth-1:9081", "auth-2:9081"}, cfg.Services["auth"].GRPC)
+	// Файл задаёт адреса поверх переменных окружения
+	assert.Equal(t, []string{"core-1:9082", "core-2:9082"}, cfg.Services["core"].GRPC)
+	assert.Equal(t, []string{"core_service:9082"}, cfg.EnvServices["core"].GRPC)
+	assert.Equal(t, []string{"http://core_service:8082"}, cfg.Services["core"].HTTP)
+
+	t.Setenv("core_url", "core_service:8082")
+	_, err = client.LoadConfig()
+	assert.ErrorContains(t, err, "core_service:8082")
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"common/audit"
+	"common/proto/catalogpb"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// Core - клиент core_service. Товары читаются и меняются через gRPC API
+// каталога, остальные запросы (изображения) пересылаются по http без
+// изменений: Core не разбирает их ответы, а отдаёт как есть.
+type Core struct {
+	url     string
+	http    *http.Client
+	catalog catalogpb.CatalogClient
+}
+
+// NewCore принимает http клиент без общего таймаута: изображения
+// передаются потоком, и срок запроса задаёт контекст.
+func NewCore(baseURL string, httpClient *http.Client, conn grpc.ClientConnInterface) *Core {
+	return &Core{url: baseURL, http: httpClient, catalog: catalogpb.NewCatalogClient(conn)}
+}
+
+// Ready проверяет готовность core_service по его /readyz.
+func (c *Core) Ready(ctx context.Context) error {
+	return ready(ctx, c.http, c.url)
+}
+
+func (c *Core) ListProducts(ctx context.Context) (*catalogpb.ListProductsResponse, error) {
+	resp, err := c.catalog.ListProducts(ctx, &catalogpb.ListProductsRequest{})
+	if err != nil {
+		return nil, rpcError("Catalog.ListProducts", err)
+	}
+	return resp, nil
+}
+
+func (c *Core) GetProduct(ctx context.Context, id int32) (*catalogpb.Product, error) {
+	resp, err := c.catalog.GetProduct(ctx, &catalogpb.GetProductRequest{Id: id})
+	if err != nil {
+		return nil, rpcError("Catalog.GetProduct", err)
+	}
+	return resp, nil
+}
+
+func (c *Core) CreateProduct(ctx context.Context, req *catalogpb.CreateProductRequest) (*catalogpb.CreateProductResponse, error) {
+	resp, err := c.catalog.CreateProduct(ctx, req)
+	if err != nil {
+		return nil, rpcError("Catalog.CreateProduct", err)
+	}
+	return resp, nil
+}
+
+func (c *Core) UpdateProduct(ctx context.Context, req *catalogpb.UpdateProductRequest) error {
+	if _, err := c.catalog.UpdateProduct(ctx, req); err != nil {
+		return rpcError("Catalog.UpdateProduct", err)
+	}
+	return nil
+}
+
+func (c *Core) DeleteProduct(ctx context.Context, id int32) error {
+	if _, err := c.catalog.DeleteProduct(ctx, &catalogpb.DeleteProductRequest{Id: id}); err != nil {
+		return rpcError("Catalog.DeleteProduct", err)
+	}
+	return nil
+}
+
+// ChangeCount меняет остаток товара на req.Count.
+func (c *Core) ChangeCount(ctx context.Context, req *catalogpb.ChangeCountRequest) error {
+	if _, err := c.catalog.ChangeCount(ctx, req); err != nil {
+		return rpcError("Catalog.ChangeCount", err)
+	}
+	return nil
+}
+
+// Forward выполняет запрос к core_service. Ответ любого статуса
+// возвращается вызывающему, который должен закрыть его тело.
+func (c *Core) Forward(ctx context.Context, method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s http.NewRequest: %w", method, path, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// Audit читает журнал аудита core_service: изменения товаров и
+// изображений.
+func (c *Core) Audit(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return base{url: c.url, http: c.http}.auditLog(ctx, filter)
+}
@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrNoEndpoints = errors.New("Нет адресов сервиса")
+
+// Ejection - когда экземпляр исключается из балансировки: после Failures
+// неудачных запросов подряд он не получает запросов Duration.
+type Ejection struct {
+	Failures int
+	Duration time.Duration
+}
+
+type endpoint struct {
+	url          *url.URL
+	failures     int
+	ejectedUntil time.Time
+}
+
+// Balancer распределяет http запросы между экземплярами сервиса по кругу.
+// Хост запроса заменяется адресом экземпляра, поэтому клиенты сервиса
+// могут обращаться к нему по любому условному адресу.
+// Экземпляры, которые подряд не отвечают или отвечают 5xx, на время
+// исключаются. Если исключены все, запрос получает тот, кто вернётся
+// раньше остальных: решать, что сервис недоступен целиком, - дело
+// outbound.Transport.
+type Balancer struct {
+	name     string
+	ejection Ejection
+	base     http.RoundTripper
+	log      zerolog.Logger
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+// NewBalancer оборачивает base, по умолчанию http.DefaultTransport.
+// Изменения списка экземпляров пишутся в log.
+func NewBalancer(name string, addrs []string, ejection Ejection, base http.RoundTripper, log zerolog.Logger) *Balancer {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	b := &Balancer{name: name, ejection: ejection, base: base, log: log}
+	b.Update(addrs)
+	return b
+}
+
+// Update заменяет список экземпляров. У оставшихся в списке экземпляров
+// сохраняется счётчик неудач и время исключения.
+func (b *Balancer) Update(addrs []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := make(map[string]*endpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		current[e.url.String()] = e
+	}
+
+	endpoints := make([]*endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		u, err := url.Parse(addr)
+		if err != nil {
+			b.log.Warn().Msgf("Сервис %s: пропущен неправильный адрес %q", b.name, addr)
+			continue
+		}
+		if e, ok := current[u.String()]; ok {
+			endpoints = append(endpoints, e)
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{url: u})
+	}
+	b.endpoints = endpoints
+}
+
+// Addrs возвращает адреса экземпляров, которые сейчас получают запросы.
+func (b *Balancer) Addrs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	addrs := []string{}
+	for _, e := range b.endpoints {
+		if !now.Before(e.ejectedUntil) {
+			addrs = append(addrs, e.url.String())
+		}
+	}
+	return addrs
+}
+
+func (b *Balancer) RoundTrip(req *http.Request) (*http.Response, error) {
+	e := b.pick()
+	if e == nil {
+		return nil, ErrNoEndpoints
+	}
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = e.url.Scheme
+	out.URL.Host = e.url.Host
+	out.URL.Path = strings.TrimSuffix(e.url.Path, "/") + req.URL.Path
+	out.URL.RawPath = ""
+	out.Host = ""
+
+	resp, err := b.base.RoundTrip(out)
+	b.report(e, err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+// pick выбирает следующий по кругу экземпляр, который не исключён.
+func (b *Balancer) pick() *endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.endpoints) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var soonest *endpoint
+	for range b.endpoints {
+		e := b.endpoints[b.next%len(b.endpoints)]
+		b.next = (b.next + 1) % len(b.endpoints)
+		if !now.Before(e.ejectedUntil) {
+			return e
+		}
+		if soonest == nil || e.ejectedUntil.Before(soonest.ejectedUntil) {
+			soonest = e
+		}
+	}
+	return soonest
+}
+
+func (b *Balancer) report(e *endpoint, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ok {
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if b.ejection.Failures > 0 && e.failures >= b.ejection.Failures {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(b.ejection.Duration)
+		b.log.Warn().Msgf("Сервис %s: экземпляр %s исключён из балансировки на %s", b.name, e.url, b.ejection.Duration)
+	}
+}
@@ -0,0 +1,108 @@
+// Package discovery хранит адреса экземпляров сервисов, к которым
+// обращается manage_service, и распределяет между ними запросы.
+// Адреса задаются переменными окружения или yaml файлом; файл
+// перечитывается на лету, и новые адреса применяются без перезапуска.
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Service - адреса экземпляров одного сервиса: базовые url http API
+// и host:port gRPC API.
+type Service struct {
+	HTTP []string `yaml:"http"`
+	GRPC []string `yaml:"grpc"`
+}
+
+// Services - адреса сервисов по именам: auth, authoriz, core.
+type Services map[string]Service
+
+// file - формат yaml файла с адресами.
+//
+//	services:
+//	  auth:
+//	    http: [http://auth-1:8081, http://auth-2:8081]
+//	    grpc: [auth-1:9081, auth-2:9081]
+type file struct {
+	Services Services `yaml:"services"`
+}
+
+// Load читает адреса из файла path поверх base. Сервисы и списки, которых
+// нет в файле, остаются из base. Пустой path означает, что файла нет.
+func Load(path string, base Services) (Services, error) {
+	services := base.clone()
+	if path == "" {
+		return services, services.validate()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("discovery/Load: %w", err)
+	}
+
+	f := file{}
+	if err = yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("discovery/Load %s: %w", path, err)
+	}
+	for name, service := range f.Services {
+		current, ok := services[name]
+		if !ok {
+			return nil, fmt.Errorf("discovery/Load %s: неизвестный сервис %q", path, name)
+		}
+		if len(service.HTTP) > 0 {
+			current.HTTP = service.HTTP
+		}
+		if len(service.GRPC) > 0 {
+			current.GRPC = service.GRPC
+		}
+		services[name] = current
+	}
+
+	if err = services.validate(); err != nil {
+		return nil, fmt.Errorf("discovery/Load %s: %w", path, err)
+	}
+	return services, nil
+}
+
+func (s Services) clone() Services {
+	services := make(Services, len(s))
+	for name, service := range s {
+		services[name] = Service{HTTP: slices.Clone(service.HTTP), GRPC: slices.Clone(service.GRPC)}
+	}
+	return services
+}
+
+// validate проверяет, что у каждого сервиса есть хотя бы один адрес
+// каждого API и все адреса правильные.
+func (s Services) validate() error {
+	var errs []error
+	for name, service := range s {
+		if len(service.HTTP) == 0 {
+			errs = append(errs, fmt.Errorf("у сервиса %s нет http адресов", name))
+		}
+		for _, addr := range service.HTTP {
+			u, err := url.Parse(addr)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("сервис %s: неправильный http адрес %q", name, addr))
+			}
+		}
+
+		if len(service.GRPC) == 0 {
+			errs = append(errs, fmt.Errorf("у сервиса %s нет gRPC адресов", name))
+		}
+		for _, addr := range service.GRPC {
+			if host, _, err := net.SplitHostPort(addr); err != nil || host == "" {
+				errs = append(errs, fmt.Errorf("сервис %s: неправильный gRPC адрес %q", name, addr))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,226 @@
+package discovery_test
+
+import (
+	"common/proto/authpb"
+	"common/rpc"
+	"context"
+	"io"
+	"manage-service/pkg/discovery"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var base = discovery.Services{
+	"auth": {HTTP: []string{"http://auth:8081"}, GRPC: []string{"auth:9081"}},
+	"core": {HTTP: []string{"http://core:8082"}, GRPC: []string{"core:9082"}},
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func TestLoad(t *testing.T) {
+	services, err := discovery.Load("", base)
+	require.NoError(t, err)
+	assert.Equal(t, base, services)
+
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	writeFile(t, path, `
+services:
+  auth:
+    http: [http://auth-1:8081, https://auth-2:8443/api]
+`)
+	services, err = discovery.Load(path, base)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://auth-1:8081", "https://auth-2:8443/api"}, services["auth"].HTTP)
+	assert.Equal(t, []string{"auth:9081"}, services["auth"].GRPC)
+	assert.Equal(t, base["core"], services["core"])
+	// base не меняется
+	assert.Equal(t, []string{"http://auth:8081"}, base["auth"].HTTP)
+
+	cases := map[string]string{
+		"unknown service": "services:\n  billing:\n    http: [http://billing:80]\n",
+		"http address":    "services:\n  auth:\n    http: [auth:8081]\n",
+		"grpc address":    "services:\n  core:\n    grpc: [core]\n",
+		"yaml":            "services: [",
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			writeFile(t, path, data)
+			_, err := discovery.Load(path, base)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = discovery.Load(filepath.Join(t.TempDir(), "missing.yaml"), base)
+	assert.Error(t, err)
+}
+
+// instance отвечает своим именем и статусом status.
+func instance(t *testing.T, name string, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, name+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, client *http.Client) string {
+	t.Helper()
+	resp, err := client.Get("http://auth/profile")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	first := instance(t, "first", http.StatusOK)
+	second := instance(t, "second", http.StatusOK)
+
+	balancer := discovery.NewBalancer("auth", []string{first.URL, second.URL}, discovery.Ejection{Failures: 1, Duration: time.Minute}, nil, zerolog.Nop())
+	client := &http.Client{Transport: balancer}
+
+	assert.Equal(t, "first/profile", get(t, client))
+	assert.Equal(t, "second/profile", get(t, client))
+	assert.Equal(t, "first/profile", get(t, client))
+
+	balancer.Update([]string{second.URL})
+	assert.Equal(t, "second/profile", get(t, client))
+	assert.Equal(t, "second/profile", get(t, client))
+
+	balancer.Update(nil)
+	_, err := client.Get("http://auth/profile")
+	assert.ErrorIs(t, err, discovery.ErrNoEndpoints)
+}
+
+func TestBalancerEjection(t *testing.T) {
+	broken := instance(t, "broken", http.StatusInternalServerError)
+	healthy := instance(t, "healthy", http.StatusOK)
+
+	balancer := discovery.NewBalancer("auth", []string{broken.URL, healthy.URL}, discovery.Ejection{Failures: 2, Duration: time.Minute}, nil, zerolog.Nop())
+	client := &http.Client{Transport: balancer}
+
+	// Две ошибки подряд исключают экземпляр
+	assert.Equal(t, "broken/profile", get(t, client))
+	assert.Equal(t, "healthy/profile", get(t, client))
+	assert.Equal(t, "broken/profile", get(t, client))
+	assert.Equal(t, []string{healthy.URL}, balancer.Addrs())
+	for range 3 {
+		assert.Equal(t, "healthy/profile", get(t, client))
+	}
+
+	// Исключение сохраняется при обновлении адресов, а если исключены
+	// все, запрос всё равно отправляется
+	balancer.Update([]string{broken.URL})
+	assert.Empty(t, balancer.Addrs())
+	assert.Equal(t, "broken/profile", get(t, client))
+}
+
+type authServer struct {
+	authpb.UnimplementedAuthServer
+	name string
+}
+
+func (s authServer) Login(context.Context, *authpb.Credentials) (*authpb.User, error) {
+	return &authpb.User{Id: s.name}, nil
+}
+
+func serve(t *testing.T, name string) (string, *grpc.Server) {
+	server, err := rpc.NewServer(rpc.TLS{}, zerolog.Nop())
+	require.NoError(t, err)
+	authpb.RegisterAuthServer(server, authServer{name: name})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), server
+}
+
+func TestRegistryGRPC(t *testing.T) {
+	first, firstServer := serve(t, "first")
+	second, _ := serve(t, "second")
+	third, _ := serve(t, "third")
+
+	registry := discovery.NewRegistry(discovery.Services{
+		"auth": {HTTP: []string{"http://auth:8081"}, GRPC: []string{first, second}},
+	}, discovery.Ejection{Failures: 1, Duration: time.Minute}, zerolog.Nop())
+	target, opts := registry.Target("auth")
+	conn, err := grpc.NewClient(target, append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))...)
+	require.NoError(t, err)
+	defer conn.Close()
+	auth := authpb.NewAuthClient(conn)
+
+	// login возвращает имя экземпляра. Вызов, который застал остановку
+	// экземпляра, завершается ошибкой: его повторяет outbound.Transport
+	login := func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		user, err := auth.Login(ctx, &authpb.Credentials{}, grpc.WaitForReady(true))
+		if err != nil {
+			return ""
+		}
+		return user.GetId()
+	}
+
+	// round_robin начинает распределять вызовы, когда подключится
+	// к обоим экземплярам
+	seen := map[string]bool{}
+	require.Eventually(t, func() bool {
+		seen[login()] = true
+		return seen["first"] && seen["second"]
+	}, 5*time.Second, time.Millisecond)
+
+	// Остановленный экземпляр больше не получает вызовов
+	firstServer.Stop()
+	require.Eventually(t, func() bool { return login() == "second" && login() == "second" }, 5*time.Second, time.Millisecond)
+
+	registry.Update(discovery.Services{
+		"auth": {HTTP: []string{"http://auth:8081"}, GRPC: []string{third}},
+	})
+	require.Eventually(t, func() bool { return login() == "third" }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, "third", login())
+}
+
+func TestRegistryWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	writeFile(t, path, "services: {}\n")
+	services, err := discovery.Load(path, base)
+	require.NoError(t, err)
+
+	registry := discovery.NewRegistry(services, discovery.Ejection{Failures: 1, Duration: time.Minute}, zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		registry.Watch(ctx, path, base, 10*time.Millisecond)
+		close(done)
+	}()
+
+	writeFile(t, path, "services:\n  core:\n    http: [http://core-1:8082, http://core-2:8082]\n")
+	assert.Eventually(t, func() bool {
+		return len(registry.Balancer("core").Addrs()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Неправильный файл не применяется
+	writeFile(t, path, "services:\n  core:\n    http: [core-3]\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"http://core-1:8082", "http://core-2:8082"}, registry.Balancer("core").Addrs())
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/health" // проверка экземпляров из healthCheckConfig
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// Scheme - схема gRPC адресов, которые разрешает Registry.
+const Scheme = "discovery"
+
+// ServiceConfig распределяет gRPC вызовы по кругу между экземплярами,
+// которые отвечают SERVING на grpc.health.v1.Health.
+const ServiceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"healthCheckConfig": {"serviceName": ""}
+}`
+
+// Registry хранит балансировщики http запросов и списки gRPC адресов
+// всех сервисов и обновляет их при изменении адресов.
+type Registry struct {
+	services  Services
+	balancers map[string]*Balancer
+	resolvers map[string]*manual.Resolver
+	log       zerolog.Logger
+}
+
+// NewRegistry создаёт балансировщики для services. Обновления адресов
+// и исключения экземпляров пишутся в log.
+func NewRegistry(services Services, ejection Ejection, log zerolog.Logger) *Registry {
+	r := &Registry{
+		log:       log,
+		services:  services,
+		balancers: make(map[string]*Balancer, len(services)),
+		resolvers: make(map[string]*manual.Resolver, len(services)),
+	}
+	for name, service := range services {
+		r.balancers[name] = NewBalancer(name, service.HTTP, ejection, nil, log)
+
+		// У каждого соединения должен быть свой resolver
+		r.resolvers[name] = manual.NewBuilderWithScheme(Scheme)
+		r.resolvers[name].InitialState(state(service.GRPC))
+	}
+	return r
+}
+
+// Balancer возвращает балансировщик http запросов к сервису name.
+func (r *Registry) Balancer(name string) *Balancer {
+	return r.balancers[name]
+}
+
+// Target возвращает gRPC адрес сервиса name и настройки соединения,
+// которые направляют вызовы его экземплярам.
+func (r *Registry) Target(name string) (string, []grpc.DialOption) {
+	return Scheme + ":///" + name, []grpc.DialOption{
+		grpc.WithResolvers(r.resolvers[name]),
+		grpc.WithDefaultServiceConfig(ServiceConfig),
+	}
+}
+
+// Update применяет новые адреса сервисов.
+func (r *Registry) Update(services Services) {
+	for name, service := range services {
+		if reflect.DeepEqual(r.services[name], service) {
+			continue
+		}
+		if balancer, ok := r.balancers[name]; ok {
+			balancer.Update(service.HTTP)
+			r.resolvers[name].UpdateState(state(service.GRPC))
+			r.log.Info().Msgf("Адреса сервиса %s обновлены: http %v, gRPC %v", name, service.HTTP, service.GRPC)
+		}
+	}
+	r.services = services
+}
+
+// Watch раз в interval проверяет файл path и, если он изменился,
+// перечитывает адреса поверх base. Неправильный файл не применяется:
+// остаются прежние адреса. Watch возвращается, когда завершается ctx.
+func (r *Registry) Watch(ctx context.Context, path string, base Services, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Первая проверка всегда перечитывает файл: он мог измениться после
+	// запуска. Неизменные адреса Update пропускает
+	var modTime time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := fileModTime(path)
+		if current.Equal(modTime) {
+			continue
+		}
+		modTime = current
+
+		services, err := Load(path, base)
+		if err != nil {
+			r.log.Error().Msgf("Адреса сервисов не обновлены: %v", err)
+			continue
+		}
+		r.Update(services)
+	}
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// state передаёт адреса gRPC соединению. Имя хоста каждого адреса
+// используется при проверке его TLS сертификата.
+func state(addrs []string) resolver.State {
+	s := resolver.State{}
+	for _, addr := range addrs {
+		host, _, _ := net.SplitHostPort(addr)
+		s.Addresses = append(s.Addresses, resolver.Address{Addr: addr, ServerName: host})
+	}
+	return s
+}
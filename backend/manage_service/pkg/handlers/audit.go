@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"common/audit"
+	"common/response"
+	"common/response/ginresponse"
+	"context"
+	"errors"
+	"manage-service/pkg/models"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// auditTimeout ограничивает чтение журналов сервисов.
+const auditTimeout = 10 * time.Second
+
+// AuditLog собирает журналы аудита auth, authoriz и core, объединяет их
+// по времени, новые первыми, и отдаёт первые limit записей. С format=csv
+// журнал выгружается файлом. Если журнал одного из сервисов недоступен,
+// запрос завершается ошибкой: по неполному журналу нельзя понять, что
+// действия не было.
+func (h *Handler) AuditLog(c *gin.Context) {
+	filter, err := audit.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, err.Error())
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = audit.DefaultLimit
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		ginresponse.SendProblem(c, http.StatusBadRequest, response.CodeValidation, "format должен быть json или csv")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), auditTimeout)
+	defer cancel()
+
+	sources := []func(context.Context, audit.Filter) ([]audit.Entry, error){h.auth.Audit, h.authoriz.Audit, h.core.Audit}
+	results := make([][]audit.Entry, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, list := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = list(ctx, filter)
+		}()
+	}
+	wg.Wait()
+	if err = errors.Join(errs...); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	entries := slices.Concat(results...)
+	slices.SortStableFunc(entries, func(a, b audit.Entry) int {
+		return b.Time.Compare(a.Time)
+	})
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+		c.Status(http.StatusOK)
+		if err = audit.WriteCSV(c.Writer, entries); err != nil {
+			log.Ctx(c.Request.Context()).Error().Msgf("Ошибка выгрузки журнала аудита: %v", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseAudit{
+		Response: models.Response{
+			Code:    http.StatusOK,
+			Message: "Журнал аудита получен",
+		},
+		Entries: entries,
+	})
+}
@@ -1,42 +1,51 @@
 package handlers
 
 import (
+	"common/proto/catalogpb"
+	"common/response"
+	"common/response/ginresponse"
+	"context"
+	"errors"
 	"io"
-	"manage-service/pkg/communication"
+	"manage-service/pkg/client"
 	"manage-service/pkg/models"
+	"manage-service/pkg/outbound"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
-func init() {
-	log.Logger = log.With().Str("package", "handlers").Logger()
+type Handler struct {
+	auth     *client.Auth
+	authoriz *client.Authoriz
+	core     *client.Core
 }
 
-func Registration(c *gin.Context) {
+func NewHandler(auth *client.Auth, authoriz *client.Authoriz, core *client.Core) *Handler {
+	return &Handler{auth: auth, authoriz: authoriz, core: core}
+}
+
+func (h *Handler) Registration(c *gin.Context) {
 	user := models.User{}
 	if err := c.ShouldBindJSON(&user); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
 		return
 	}
 
-	GUID, admin, err := communication.RegistrationRequest(c, user)
+	GUID, admin, err := h.auth.Register(c.Request.Context(), user)
 	if err != nil {
-		if err == communication.LoginBusy {
-			models.SendResponse(c, http.StatusConflict, err.Error())
-			return
-		}
-		models.SendInternalServerError(c)
-		log.Error().Msgf("ошибка communication: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
-	tokens, err := communication.AuthorizationRequest(GUID, admin)
+	tokens, err := h.authoriz.Authorize(c.Request.Context(), GUID, admin)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("ошибка communication: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
@@ -52,29 +61,23 @@ func Registration(c *gin.Context) {
 
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	user := models.User{}
 	if err := c.ShouldBindJSON(&user); err != nil {
-		models.SendBadRequest(c)
-		log.Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
 		return
 	}
 
-	GUID, admin, err := communication.LoginRequest(user)
+	GUID, admin, err := h.auth.Login(c.Request.Context(), user)
 	if err != nil {
-		if err == communication.LoginBusy {
-			models.SendResponse(c, http.StatusConflict, err.Error())
-			return
-		}
-		models.SendInternalServerError(c)
-		log.Error().Msgf("ошибка communication: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
-	tokens, err := communication.AuthorizationRequest(GUID, admin)
+	tokens, err := h.authoriz.Authorize(c.Request.Context(), GUID, admin)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("ошибка communication: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
@@ -90,10 +93,10 @@ func Login(c *gin.Context) {
 
 }
 
-func Logout(c *gin.Context) {
+func (h *Handler) Logout(c *gin.Context) {
 	access, ok := c.Get("access")
 	if !ok {
-		models.SendBadRequest(c)
+		ginresponse.SendBadRequest(c)
 		return
 	}
 	c.SetCookie(
@@ -107,54 +110,512 @@ func Logout(c *gin.Context) {
 	)
 
 	if strAccess, ok := access.(string); ok {
-		err := communication.LogoutRequest(strAccess)
+		err := h.authoriz.Logout(c.Request.Context(), strAccess)
 		if err != nil {
-			models.SendInternalServerError(c)
+			sendServiceError(c, err)
 			return
 		}
 
 	}
 
-	models.SendInternalServerError(c)
+	models.SendResponse(c, http.StatusOK, "Пользователь успешно вышел из аккаунта")
 }
 
-func GetAllProduct(c *gin.Context) {
-	resp, err := http.Get(
-		"http://core_service:8082/product")
+func (h *Handler) GetAllProduct(c *gin.Context) {
+	products, err := h.core.ListProducts(c.Request.Context())
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка получения ответа: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	models.SendProto(c, http.StatusOK, response.MessageOK, products)
+}
+
+func (h *Handler) GetProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка чтения ответа: %v", err)
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	product, err := h.core.GetProduct(c.Request.Context(), int32(id))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendProto(c, http.StatusOK, response.MessageOK, product)
+}
+
+// imageHeaders - заголовки ответа core_service, нужные для кеширования
+// изображений браузером.
+var imageHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Last-Modified",
+	"ETag",
+	"Cache-Control",
+	"X-Content-Type-Options",
+}
+
+// GetImage потоково проксирует изображение из core_service, сохраняя
+// заголовки кеширования, чтобы повторные запросы заканчивались 304.
+func (h *Handler) GetImage(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	header := http.Header{}
+	if etag := c.GetHeader("If-None-Match"); etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+
+	resp, err := h.core.Forward(ctx, c.Request.Method, "/images/"+url.PathEscape(c.Param("key")), nil, header)
+	if err != nil {
+		sendServiceError(c, err)
 		return
 	}
 	defer resp.Body.Close()
 
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	for _, header := range imageHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			c.Header(header, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка передачи изображения: %v", err)
+	}
 }
 
-func GetProduct(c *gin.Context) {
-	id := c.Param("id")
-	resp, err := http.Get(
-		"http://core_service:8082/product/" + id)
+func (h *Handler) ChangeCountProduct(c *gin.Context) {
+	req := &catalogpb.ChangeCountRequest{}
+	if err := models.BindProto(c, req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	if err := h.core.ChangeCount(c.Request.Context(), req); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, response.MessageOK)
+}
+
+// CreateProduct создаёт товар в core_service. В ответе ссылки, по которым
+// клиент сам загружает изображения в хранилище.
+func (h *Handler) CreateProduct(c *gin.Context) {
+	req := &catalogpb.CreateProductRequest{}
+	if err := models.BindProto(c, req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	created, err := h.core.CreateProduct(c.Request.Context(), req)
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка получения ответа: %v", err)
+		sendServiceError(c, err)
 		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	models.SendProto(c, http.StatusCreated, response.MessageCreated, created)
+}
+
+// UpdateProduct меняет поля товара в core_service. id берётся из пути,
+// а не из тела запроса.
+func (h *Handler) UpdateProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	req := &catalogpb.UpdateProductRequest{}
+	if err = models.BindProto(c, req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+	req.Id = int32(id)
+
+	if err = h.core.UpdateProduct(c.Request.Context(), req); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, response.MessageOK)
+}
+
+func (h *Handler) DeleteProduct(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	if err = h.core.DeleteProduct(c.Request.Context(), int32(id)); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, response.MessageOK)
+}
+
+// ConfirmProductImages просит core_service проверить, какие файлы товара
+// уже загружены в хранилище по выданным ссылкам.
+func (h *Handler) ConfirmProductImages(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		models.SendInternalServerError(c)
-		log.Error().Msgf("Ошибка чтения ответа: %v", err)
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	h.proxyToCore(c, http.MethodPost, "/product/"+strconv.Itoa(id)+"/confirm")
+}
+
+func (h *Handler) ReorderImages(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	h.proxyToCore(c, http.MethodPut, "/product/"+strconv.Itoa(id)+"/images/order")
+}
+
+func (h *Handler) SetPrimaryImage(c *gin.Context) {
+	id, errID := strconv.Atoi(c.Param("id"))
+	imageID, errImageID := strconv.Atoi(c.Param("image_id"))
+	if errID != nil || errImageID != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	h.proxyToCore(c, http.MethodPut, "/product/"+strconv.Itoa(id)+"/images/"+strconv.Itoa(imageID)+"/primary")
+}
+
+func (h *Handler) SetImageAlt(c *gin.Context) {
+	id, errID := strconv.Atoi(c.Param("id"))
+	imageID, errImageID := strconv.Atoi(c.Param("image_id"))
+	if errID != nil || errImageID != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	h.proxyToCore(c, http.MethodPut, "/product/"+strconv.Itoa(id)+"/images/"+strconv.Itoa(imageID)+"/alt")
+}
+
+// proxyToCore пересылает тело запроса в core_service и возвращает его
+// ответ клиенту без изменений.
+func (h *Handler) proxyToCore(c *gin.Context, method, path string) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 6*time.Second)
+	defer cancel()
+
+	resp, err := h.core.Forward(ctx, method, path, c.Request.Body, http.Header{"Content-Type": {"application/json"}})
+	if err != nil {
+		sendServiceError(c, err)
 		return
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка чтения ответа: %v", err)
+		return
+	}
+
 	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), body)
 }
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	req := models.RequestCreateAPIKey{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+	req.CreatedBy = c.GetString("GUID")
+
+	key, apiKey, err := h.authoriz.CreateAPIKey(c.Request.Context(), req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ResponseAPIKey{
+		Response: models.Response{
+			Code:    http.StatusCreated,
+			Message: "API ключ успешно создан, сохраните его: повторно он показан не будет",
+		},
+		Key:    key,
+		APIKey: apiKey,
+	})
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	apiKeys, err := h.authoriz.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseAPIKeys{
+		Response: models.Response{
+			Code:    http.StatusOK,
+			Message: "Список API ключей получен",
+		},
+		APIKeys: apiKeys,
+	})
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	err = h.authoriz.RevokeAPIKey(c.Request.Context(), id)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "API ключ успешно отозван")
+}
+
+func (h *Handler) GetMe(c *gin.Context) {
+	profile, err := h.auth.GetProfile(c.Request.Context(), c.GetString("GUID"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseProfile{
+		Response: models.Response{Code: http.StatusOK, Message: "Профиль пользователя получен"},
+		Profile:  profile,
+	})
+}
+
+func (h *Handler) UpdateMe(c *gin.Context) {
+	req := models.RequestUpdateProfile{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	profile, err := h.auth.UpdateProfile(c.Request.Context(), c.GetString("GUID"), req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseProfile{
+		Response: models.Response{Code: http.StatusOK, Message: "Профиль пользователя обновлён"},
+		Profile:  profile,
+	})
+}
+
+func (h *Handler) ChangePassword(c *gin.Context) {
+	req := models.RequestChangePassword{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	if err := h.auth.ChangePassword(c.Request.Context(), c.GetString("GUID"), req); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Пароль успешно изменён")
+}
+
+// GrantAdmin назначает пользователя администратором. id проверяет
+// authentication_service.
+func (h *Handler) GrantAdmin(c *gin.Context) {
+	if err := h.auth.GrantAdmin(c.Request.Context(), c.Param("id")); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Пользователь назначен администратором")
+}
+
+func (h *Handler) ListAddresses(c *gin.Context) {
+	addresses, err := h.auth.ListAddresses(c.Request.Context(), c.GetString("GUID"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseAddresses{
+		Response:  models.Response{Code: http.StatusOK, Message: "Список адресов получен"},
+		Addresses: addresses,
+	})
+}
+
+func (h *Handler) CreateAddress(c *gin.Context) {
+	req := models.RequestAddress{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	address, err := h.auth.CreateAddress(c.Request.Context(), c.GetString("GUID"), req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ResponseAddress{
+		Response: models.Response{Code: http.StatusCreated, Message: "Адрес успешно добавлен"},
+		Address:  address,
+	})
+}
+
+func (h *Handler) UpdateAddress(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	req := models.RequestAddress{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginresponse.SendBadRequest(c)
+		log.Ctx(c.Request.Context()).Error().Msgf("Ошибка парсинга данных из json: %v", err)
+		return
+	}
+
+	address, err := h.auth.UpdateAddress(c.Request.Context(), c.GetString("GUID"), id, req)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseAddress{
+		Response: models.Response{Code: http.StatusOK, Message: "Адрес успешно обновлён"},
+		Address:  address,
+	})
+}
+
+func (h *Handler) DeleteAddress(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	if err := h.auth.DeleteAddress(c.Request.Context(), c.GetString("GUID"), id); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Адрес успешно удалён")
+}
+
+func (h *Handler) SetDefaultAddress(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ginresponse.SendBadRequest(c)
+		return
+	}
+
+	if err := h.auth.SetDefaultAddress(c.Request.Context(), c.GetString("GUID"), id); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Адрес по умолчанию изменён")
+}
+
+func (h *Handler) ExportMe(c *gin.Context) {
+	GUID := c.GetString("GUID")
+
+	export, err := h.auth.ExportUser(c.Request.Context(), GUID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	sessions, err := h.authoriz.UserSessions(c.Request.Context(), GUID)
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="personal_data.json"`)
+	c.JSON(http.StatusOK, models.PersonalData{
+		ExportedAt:    time.Now(),
+		Profile:       export.Profile,
+		Addresses:     export.Addresses,
+		Deletion:      export.Deletion,
+		Sessions:      sessions.Sessions,
+		RefreshTokens: sessions.RefreshTokens,
+	})
+}
+
+func (h *Handler) GetDeletion(c *gin.Context) {
+	deletion, err := h.auth.GetDeletion(c.Request.Context(), c.GetString("GUID"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseDeletion{
+		Response: models.Response{Code: http.StatusOK, Message: "Статус удаления аккаунта получен"},
+		Deletion: deletion,
+	})
+}
+
+func (h *Handler) RequestDeletion(c *gin.Context) {
+	deletion, err := h.auth.RequestDeletion(c.Request.Context(), c.GetString("GUID"))
+	if err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.ResponseDeletion{
+		Response: models.Response{
+			Code:    http.StatusAccepted,
+			Message: "Удаление аккаунта запланировано, до указанной даты его можно отменить",
+		},
+		Deletion: deletion,
+	})
+}
+
+func (h *Handler) CancelDeletion(c *gin.Context) {
+	if err := h.auth.CancelDeletion(c.Request.Context(), c.GetString("GUID")); err != nil {
+		sendServiceError(c, err)
+		return
+	}
+
+	models.SendResponse(c, http.StatusOK, "Удаление аккаунта отменено")
+}
+
+// sendServiceError возвращает клиенту ошибку сервиса в формате
+// problem+json. Недоступность сервиса превращается в 503, остальные
+// серверные ошибки скрываются за 500.
+func sendServiceError(c *gin.Context, err error) {
+	var respErr *client.ResponseError
+	if errors.As(err, &respErr) {
+		ginresponse.WriteProblem(c, respErr.Problem)
+		return
+	}
+
+	if outbound.Unavailable(err) {
+		models.SendServiceUnavailable(c)
+		log.Ctx(c.Request.Context()).Warn().Msgf("Сервис недоступен: %v", err)
+		return
+	}
+
+	ginresponse.SendInternalServerError(c)
+	log.Ctx(c.Request.Context()).Error().Msgf("Ошибка запроса к сервису: %v", err)
+}
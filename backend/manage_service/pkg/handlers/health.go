@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"manage-service/pkg/outbound"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseHealth - состояние шлюза и цепей до каждого сервиса.
+type ResponseHealth struct {
+	Status       string                    `json:"status"`
+	Dependencies map[string]outbound.Stats `json:"dependencies"`
+}
+
+// Health отвечает 200, пока шлюз работает. Если хотя бы одна цепь не
+// замкнута, статус degraded: часть запросов сейчас отклоняется сразу.
+func Health(dependencies ...*outbound.Transport) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		health := ResponseHealth{Status: "ok", Dependencies: make(map[string]outbound.Stats, len(dependencies))}
+		for _, dependency := range dependencies {
+			stats := dependency.Stats()
+			if stats.State != outbound.StateClosed {
+				health.Status = "degraded"
+			}
+			health.Dependencies[dependency.Name()] = stats
+		}
+
+		c.JSON(http.StatusOK, health)
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"common/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actor записывает в контекст запроса, кто его выполняет: адрес и
+// User-Agent клиента. AuthMiddleware дополняет его GUID пользователя или
+// номером API ключа. Сервисы получают Actor вместе с X-Request-ID и пишут
+// его в журнал аудита. Заголовки X-Actor-* от клиента заменяются, поэтому
+// подменить исполнителя снаружи нельзя.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setActor(c, "")
+		c.Next()
+	}
+}
+
+func setActor(c *gin.Context, id string) {
+	actor := tracing.Actor{ID: id, IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	c.Request = c.Request.WithContext(tracing.WithActor(c.Request.Context(), actor))
+}
@@ -1,32 +1,50 @@
 package middleware
 
 import (
-	"manage-service/pkg/communication"
+	"common/audit"
+	"common/response"
+	"common/response/ginresponse"
+	"errors"
+	"manage-service/pkg/client"
 	"manage-service/pkg/models"
+	"manage-service/pkg/outbound"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+const (
+	ScopeProductRead  = "product:read"
+	ScopeProductWrite = "product:write"
+	ScopeProductCount = "product:count"
+
+	APIKeyHeader = "X-API-Key"
+)
+
+func AuthMiddleware(authoriz *client.Authoriz) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			if !authenticateAPIKey(c, authoriz, key) {
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, models.Response{
-				Code:    http.StatusUnauthorized,
-				Message: "Не найден заголовок Authorization",
-			})
+			ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "Не найден заголовок Authorization")
 			c.Abort()
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, models.Response{
-				Code:    http.StatusUnauthorized,
-				Message: "Не найден токен в заголовке Authorization Bearer",
-			})
+			ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "Не найден токен в заголовке Authorization Bearer")
 			c.Abort()
 			return
 		}
@@ -34,16 +52,25 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		refresh, err := c.Cookie("refreshToken")
 		if err != nil {
-			models.SendInternalServerError(c)
+			ginresponse.SendProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "Не найден refresh токен")
 			c.Abort()
 			return
 		}
 
-		GUID, admin, err := communication.GUIDRequest(access)
+		GUID, admin, err := authoriz.User(c.Request.Context(), access)
 		if err != nil {
-			tokens, err := communication.RefreshRequest(access, refresh)
+			// Обновлять токены имеет смысл только у истёкшего access токена
+			if client.Code(err) != response.CodeTokenExpired {
+				sendServiceError(c, err)
+				c.Abort()
+				return
+			}
+
+			tokens, err := authoriz.Refresh(c.Request.Context(), access, refresh)
 			if err != nil {
-				models.SendResponse(c, http.StatusUnauthorized, "Токены не действительный")
+				sendServiceError(c, err)
+				c.Abort()
+				return
 			}
 			c.SetCookie("refreshToken",
 				tokens.Refresh,
@@ -52,7 +79,8 @@ func AuthMiddleware() gin.HandlerFunc {
 				"localhost",
 				true,
 				true)
-			models.SendAccess(c, http.StatusContinue, tokens.Access)
+			// Запрос не выполнен, клиент повторяет его с новым access токеном
+			models.SendTokensRefreshed(c, tokens.Access)
 			c.Abort()
 			return
 		}
@@ -61,6 +89,125 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("admin", admin)
 		c.Set("access", access)
 		c.Set("refresh", refresh)
+		setActor(c, GUID)
+		c.Next()
+	}
+}
+
+// OptionalAPIKey пропускает анонимные запросы, но если клиент передал
+// X-API-Key, то ключ должен быть действительным и иметь нужное право.
+func OptionalAPIKey(authoriz *client.Authoriz, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if !authenticateAPIKey(c, authoriz, key) || !hasScope(c, scope) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope ограничивает доступ для клиентов с API ключом.
+// Запросы пользователей с access токеном проходят без изменений.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiKey"); !ok {
+			c.Next()
+			return
+		}
+
+		if !hasScope(c, scope) {
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// AdminOrScope пропускает администраторов с access токеном и клиентов
+// с API ключом, у которого есть право scope.
+func AdminOrScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiKey"); !ok {
+			AdminOnly()(c)
+			return
+		}
+
+		if !hasScope(c, scope) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.GetBool("admin") {
+			ginresponse.SendProblem(c, http.StatusForbidden, response.CodeForbidden, "Недостаточно прав")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserOnly пропускает только пользователей, вошедших по access токену.
+func UserOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("GUID") == "" {
+			ginresponse.SendProblem(c, http.StatusForbidden, response.CodeForbidden, "Доступно только пользователям")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, authoriz *client.Authoriz, key string) bool {
+	apiKey, err := authoriz.CheckAPIKey(c.Request.Context(), key, c.ClientIP())
+	if err != nil {
+		sendServiceError(c, err)
+		return false
+	}
+
+	c.Set("apiKey", apiKey.ID)
+	c.Set("scopes", apiKey.Scopes)
+	c.Set("admin", false)
+	setActor(c, audit.Target("apikey", apiKey.ID))
+	return true
+}
+
+func hasScope(c *gin.Context, scope string) bool {
+	scopes := c.GetStringSlice("scopes")
+	if !slices.Contains(scopes, scope) {
+		ginresponse.SendProblem(c, http.StatusForbidden, response.CodeAPIKeyScope, "У API ключа нет права "+scope)
+		return false
+	}
+	return true
+}
+
+// sendServiceError возвращает клиенту ошибку сервиса в формате
+// problem+json. Недоступность сервиса превращается в 503, остальные
+// серверные ошибки скрываются за 500.
+func sendServiceError(c *gin.Context, err error) {
+	var respErr *client.ResponseError
+	if errors.As(err, &respErr) {
+		ginresponse.WriteProblem(c, respErr.Problem)
+		return
+	}
+
+	if outbound.Unavailable(err) {
+		models.SendServiceUnavailable(c)
+		log.Ctx(c.Request.Context()).Warn().Msgf("Сервис недоступен: %v", err)
+		return
+	}
+
+	ginresponse.SendInternalServerError(c)
+	log.Ctx(c.Request.Context()).Error().Msgf("Ошибка запроса к сервису: %v", err)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"common/response"
+	"common/response/ginresponse"
+	"fmt"
+	"manage-service/pkg/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// RateLimit ограничивает частоту запросов по policy. Авторизованные
+// клиенты ограничиваются по GUID или API ключу, анонимные - по адресу.
+// Состояние корзины возвращается в заголовках RateLimit-*. Если
+// хранилище недоступно, запрос пропускается: ограничение не должно
+// останавливать магазин.
+func RateLimit(store ratelimit.Store, policy ratelimit.Policy) gin.HandlerFunc {
+	policyHeader := fmt.Sprintf("%d;w=%d;burst=%d", policy.Limit, int(policy.Period.Seconds()), policy.Burst)
+
+	return func(c *gin.Context) {
+		result, err := store.Take(c.Request.Context(), policy.Name+":"+rateLimitKey(c), policy, time.Now())
+		if err != nil {
+			log.Ctx(c.Request.Context()).Warn().Msgf("Ограничение запросов пропущено: %v", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			ginresponse.SendProblem(c, http.StatusTooManyRequests, response.CodeRateLimited, "Слишком много запросов, повторите позже")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitKey(c *gin.Context) string {
+	if GUID := c.GetString("GUID"); GUID != "" {
+		return "user:" + GUID
+	}
+	if id, ok := c.Get("apiKey"); ok {
+		return fmt.Sprintf("apikey:%v", id)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -1,15 +1,20 @@
 package models
 
 import (
+	"common/audit"
+	"common/response"
+	"common/response/ginresponse"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
-type Response struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+// Response - общий для сервисов конверт ответа
+type Response = response.Response
 
 type User struct {
 	Login    string `json:"login"`
@@ -35,9 +40,154 @@ type ResponseAccess struct {
 	Response
 	Access string `json:"access"`
 }
-type ResponseAuth struct {
-	ResponseUUID
-	Admin bool `json:"admin"`
+
+// ProblemTokensRefreshed - ответ на запрос с истёкшим access токеном.
+// Запрос не выполнен, клиент повторяет его с новым access токеном.
+type ProblemTokensRefreshed struct {
+	response.Problem
+	Access string `json:"access"`
+}
+
+type Profile struct {
+	ID      string    `json:"id"`
+	Login   string    `json:"login"`
+	Admin   bool      `json:"admin"`
+	RegData time.Time `json:"registration_date"`
+	Name    string    `json:"name"`
+	Phone   string    `json:"phone"`
+}
+
+type Address struct {
+	ID         int64  `json:"id"`
+	UserID     string `json:"user_id"`
+	City       string `json:"city"`
+	Street     string `json:"street"`
+	House      string `json:"house"`
+	Apartment  string `json:"apartment"`
+	PostalCode string `json:"postal_code"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+type RequestUpdateProfile struct {
+	Name  *string `json:"name"`
+	Phone *string `json:"phone"`
+}
+
+type RequestChangePassword struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RequestAddress struct {
+	City       string `json:"city"`
+	Street     string `json:"street"`
+	House      string `json:"house"`
+	Apartment  string `json:"apartment"`
+	PostalCode string `json:"postal_code"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+type ResponseProfile struct {
+	Response
+	Profile Profile `json:"profile"`
+}
+
+type ResponseAddress struct {
+	Response
+	Address Address `json:"address"`
+}
+
+type ResponseAddresses struct {
+	Response
+	Addresses []Address `json:"addresses"`
+}
+
+type Deletion struct {
+	RequestedAt *time.Time `json:"requested_at"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+type Session struct {
+	ID     int64 `json:"id"`
+	Active bool  `json:"active"`
+}
+
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Active    bool       `json:"active"`
+}
+
+type ResponseDeletion struct {
+	Response
+	Deletion Deletion `json:"deletion"`
+}
+
+type ResponseUserExport struct {
+	Response
+	Profile   Profile   `json:"profile"`
+	Addresses []Address `json:"addresses"`
+	Deletion  Deletion  `json:"deletion"`
+}
+
+type ResponseSessions struct {
+	Response
+	Sessions      []Session      `json:"sessions"`
+	RefreshTokens []RefreshToken `json:"refresh_tokens"`
+}
+
+// PersonalData - архив, который пользователь получает по запросу
+// "выгрузить мои данные".
+type PersonalData struct {
+	ExportedAt    time.Time      `json:"exported_at"`
+	Profile       Profile        `json:"profile"`
+	Addresses     []Address      `json:"addresses"`
+	Deletion      Deletion       `json:"deletion"`
+	Sessions      []Session      `json:"sessions"`
+	RefreshTokens []RefreshToken `json:"refresh_tokens"`
+}
+
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type RequestCreateAPIKey struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	AllowedIPs []string `json:"allowed_ips"`
+	ExpiresIn  int      `json:"expires_in"`
+	CreatedBy  string   `json:"created_by"`
+}
+
+type RequestCheckAPIKey struct {
+	Key string `json:"key"`
+	IP  string `json:"ip"`
+}
+
+type ResponseAPIKey struct {
+	Response
+	Key    string `json:"key,omitempty"`
+	APIKey APIKey `json:"api_key"`
+}
+
+type ResponseAPIKeys struct {
+	Response
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+type ResponseAudit struct {
+	Response
+	Entries []audit.Entry `json:"entries"`
 }
 
 func SendAccess(c *gin.Context, code int, access string) {
@@ -51,20 +201,6 @@ func SendAccess(c *gin.Context, code int, access string) {
 	)
 }
 
-func SendTokensRefresh(c *gin.Context, access, refresh string) {
-	c.JSON(http.StatusContinue, ResponseTokens{
-		Response: Response{
-			Code:    http.StatusContinue,
-			Message: "Токены устарели и были обновлены",
-		},
-		Tokens: Tokens{
-			Access:  access,
-			Refresh: refresh,
-		},
-	},
-	)
-}
-
 func SendTokens(c *gin.Context, access, refresh string) {
 	c.JSON(http.StatusOK, ResponseTokens{
 		Response: Response{
@@ -94,16 +230,50 @@ func SendResponse(c *gin.Context, code int, message string) {
 	})
 }
 
-func SendBadRequest(c *gin.Context) {
-	c.JSON(http.StatusBadRequest, Response{
-		Code:    http.StatusBadRequest,
-		Message: "Ошибка запроса, невозможно прочитать данные",
-	})
+// protoJSON пишет поля под именами из .proto и не пропускает пустые
+// значения, как json ответы сервисов.
+var protoJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// SendProto отправляет конверт code/message вместе с полями m.
+func SendProto(c *gin.Context, code int, message string, m proto.Message) {
+	data, err := protoJSON.Marshal(m)
+	if err != nil {
+		ginresponse.SendInternalServerError(c)
+		return
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		ginresponse.SendInternalServerError(c)
+		return
+	}
+	fields["code"], _ = json.Marshal(code)
+	fields["message"], _ = json.Marshal(message)
+
+	c.JSON(code, fields)
 }
 
-func SendInternalServerError(c *gin.Context) {
-	c.JSON(http.StatusInternalServerError, Response{
-		Code:    http.StatusInternalServerError,
-		Message: "Неизвестная ошибка сервера",
+// BindProto разбирает json тело запроса в m. Неизвестные поля
+// пропускаются, как при ShouldBindJSON.
+func BindProto(c *gin.Context, m proto.Message) error {
+	data, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m)
+}
+
+// SendTokensRefreshed отвечает 401 TOKEN_EXPIRED вместе с новым access
+// токеном. Новый refresh токен записывается в cookie до вызова.
+func SendTokensRefreshed(c *gin.Context, access string) {
+	c.Header("Content-Type", response.ProblemContentType)
+	c.JSON(http.StatusUnauthorized, ProblemTokensRefreshed{
+		Problem: response.NewProblem(http.StatusUnauthorized, response.CodeTokenExpired,
+			"Токены устарели и были обновлены, повторите запрос с новым access токеном"),
+		Access: access,
 	})
 }
+
+func SendServiceUnavailable(c *gin.Context) {
+	ginresponse.SendProblem(c, http.StatusServiceUnavailable, response.CodeUnavailable, "Сервис временно недоступен, повторите запрос позже")
+}
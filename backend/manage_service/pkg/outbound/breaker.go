@@ -0,0 +1,100 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// breaker размыкает цепь после threshold неудачных попыток подряд.
+// Через openTimeout пропускает одну пробную попытку: если она успешна,
+// цепь замыкается, иначе снова размыкается.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// skip завершает попытку, результат которой ничего не говорит о сервисе.
+func (b *breaker) skip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.current() == StateHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// current возвращает состояние с учётом истёкшего openTimeout.
+// Вызывается под mu.
+func (b *breaker) current() State {
+	if b.state == "" {
+		b.state = StateClosed
+	}
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.openTimeout {
+		b.state = StateHalfOpen
+	}
+	return b.state
+}
+
+type Stats struct {
+	State    State `json:"state"`
+	Failures int   `json:"failures"`
+	InFlight int   `json:"in_flight"`
+}
+
+// Stats возвращает состояние цепи и число выполняемых запросов.
+func (t *Transport) Stats() Stats {
+	t.breaker.mu.Lock()
+	defer t.breaker.mu.Unlock()
+
+	return Stats{
+		State:    t.breaker.current(),
+		Failures: t.breaker.failures,
+		InFlight: len(t.slots),
+	}
+}
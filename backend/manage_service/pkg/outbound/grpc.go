@@ -0,0 +1,123 @@
+package outbound
+
+import (
+	"common/metrics"
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// UnaryClientInterceptor применяет к gRPC вызовам ту же политику, что
+// RoundTrip к http запросам, с общими цепью и лимитом запросов. Повторяются
+// только методы, отмеченные в .proto как idempotency_level NO_SIDE_EFFECTS
+// или IDEMPOTENT.
+func (t *Transport) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		start := time.Now()
+		defer func() {
+			metrics.ObserveDownstream(t.name, method, callStatus(err), time.Since(start))
+		}()
+
+		select {
+		case t.slots <- struct{}{}:
+		default:
+			return ErrBulkheadFull
+		}
+		defer func() { <-t.slots }()
+
+		retries := 0
+		if idempotent(method) {
+			retries = t.policy.Retries
+		}
+
+		for attempt := 0; ; attempt++ {
+			if !t.breaker.allow() {
+				return ErrCircuitOpen
+			}
+
+			err = t.invoke(ctx, method, req, reply, cc, invoker, opts)
+			// Отмену вызова клиентом не считаем ни отказом, ни успехом сервиса
+			switch {
+			case err != nil && ctx.Err() != nil:
+				t.breaker.skip()
+			default:
+				t.breaker.record(!failedCode(status.Code(err)))
+			}
+
+			if attempt >= retries || !retryCode(status.Code(err)) || ctx.Err() != nil {
+				return err
+			}
+
+			select {
+			case <-time.After(t.backoff(attempt)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (t *Transport) invoke(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption) error {
+	if t.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.policy.Timeout)
+		defer cancel()
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// idempotent ищет описание метода /package.Service/Method среди
+// зарегистрированных .proto файлов.
+func idempotent(method string) bool {
+	name := strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", 1)
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return false
+	}
+	methodDescriptor, ok := descriptor.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+	options, ok := methodDescriptor.Options().(*descriptorpb.MethodOptions)
+	if !ok {
+		return false
+	}
+
+	switch options.GetIdempotencyLevel() {
+	case descriptorpb.MethodOptions_NO_SIDE_EFFECTS, descriptorpb.MethodOptions_IDEMPOTENT:
+		return true
+	}
+	return false
+}
+
+// callStatus - значение метки status для gRPC вызова: код ответа
+// сервиса или причина, по которой вызов не был отправлен.
+func callStatus(err error) string {
+	if _, ok := status.FromError(err); ok {
+		return status.Code(err).String()
+	}
+	return errorStatus(err)
+}
+
+// failedCode - коды, которые говорят о сбое сервиса, а не об ошибке в
+// запросе. Аналог статусов 5xx.
+func failedCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.DataLoss, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// retryCode - аналог 502, 503 и 504: сервис не получил вызов или не
+// успел на него ответить.
+func retryCode(code codes.Code) bool {
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
@@ -0,0 +1,113 @@
+package outbound_test
+
+import (
+	"common/proto/authzpb"
+	"context"
+	"manage-service/pkg/outbound"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// authzServer отвечает кодами codes по очереди, последний повторяется.
+type authzServer struct {
+	authzpb.UnimplementedAuthzServer
+	codes []codes.Code
+	calls atomic.Int32
+}
+
+func (s *authzServer) reply() error {
+	n := int(s.calls.Add(1)) - 1
+	return status.Error(s.codes[min(n, len(s.codes)-1)], "")
+}
+
+func (s *authzServer) Introspect(context.Context, *authzpb.IntrospectRequest) (*authzpb.Principal, error) {
+	if err := s.reply(); status.Code(err) != codes.OK {
+		return nil, err
+	}
+	return &authzpb.Principal{UserId: "id"}, nil
+}
+
+func (s *authzServer) Logout(context.Context, *authzpb.LogoutRequest) (*authzpb.LogoutResponse, error) {
+	if err := s.reply(); status.Code(err) != codes.OK {
+		return nil, err
+	}
+	return &authzpb.LogoutResponse{}, nil
+}
+
+func newAuthz(t *testing.T, transport *outbound.Transport, replies ...codes.Code) (authzpb.AuthzClient, *authzServer) {
+	t.Helper()
+	fake := &authzServer{codes: replies}
+	server := grpc.NewServer()
+	authzpb.RegisterAuthzServer(server, fake)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///authz",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(transport.UnaryClientInterceptor()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return authzpb.NewAuthzClient(conn), fake
+}
+
+func TestGRPCRetryIdempotent(t *testing.T) {
+	client, fake := newAuthz(t, outbound.New("authoriz", testPolicy, nil), codes.Unavailable, codes.Unavailable, codes.OK)
+
+	principal, err := client.Introspect(context.Background(), &authzpb.IntrospectRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "id", principal.GetUserId())
+	assert.Equal(t, int32(3), fake.calls.Load())
+}
+
+func TestGRPCNoRetryWithSideEffects(t *testing.T) {
+	client, fake := newAuthz(t, outbound.New("authoriz", testPolicy, nil), codes.Unavailable, codes.OK)
+
+	_, err := client.Logout(context.Background(), &authzpb.LogoutRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.True(t, outbound.Unavailable(err))
+	assert.Equal(t, int32(1), fake.calls.Load())
+}
+
+func TestGRPCNoRetryOnClientError(t *testing.T) {
+	transport := outbound.New("authoriz", testPolicy, nil)
+	client, fake := newAuthz(t, transport, codes.Unauthenticated)
+
+	for range 5 {
+		_, err := client.Introspect(context.Background(), &authzpb.IntrospectRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.False(t, outbound.Unavailable(err))
+	}
+	assert.Equal(t, int32(5), fake.calls.Load())
+	assert.Equal(t, outbound.StateClosed, transport.Stats().State)
+}
+
+func TestGRPCBreakerOpens(t *testing.T) {
+	policy := testPolicy
+	policy.Retries = 0
+	transport := outbound.New("authoriz", policy, nil)
+	client, fake := newAuthz(t, transport, codes.Internal)
+
+	for range policy.FailureThreshold {
+		_, err := client.Logout(context.Background(), &authzpb.LogoutRequest{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	}
+	assert.Equal(t, outbound.StateOpen, transport.Stats().State)
+
+	_, err := client.Logout(context.Background(), &authzpb.LogoutRequest{})
+	assert.ErrorIs(t, err, outbound.ErrCircuitOpen)
+	assert.True(t, outbound.Unavailable(err))
+	assert.Equal(t, int32(policy.FailureThreshold), fake.calls.Load())
+}
@@ -0,0 +1,244 @@
+// Package outbound - общий слой исходящих http запросов manage_service.
+// Transport ограничивает время и число одновременных запросов к сервису,
+// повторяет неудачные идемпотентные запросы и размыкает цепь, если сервис
+// перестал отвечать, чтобы запросы клиентов не ждали его впустую.
+package outbound
+
+import (
+	"common/metrics"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrCircuitOpen  = errors.New("Сервис временно недоступен: цепь разомкнута")
+	ErrBulkheadFull = errors.New("Сервис перегружен: слишком много одновременных запросов")
+)
+
+type Policy struct {
+	// Timeout ограничивает одну попытку запроса вместе с чтением ответа.
+	Timeout time.Duration
+	// Retries - число повторов после первой неудачной попытки.
+	Retries    int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxConcurrent - число одновременных запросов к сервису.
+	MaxConcurrent int
+	// FailureThreshold подряд неудачных попыток размыкают цепь на OpenTimeout.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// Unavailable сообщает, что запрос не выполнен из-за состояния сервиса:
+// цепь разомкнута, очередь переполнена, истекло время ожидания или
+// gRPC сервер недоступен.
+func Unavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// Transport выполняет запросы к одному сервису.
+type Transport struct {
+	name    string
+	policy  Policy
+	base    http.RoundTripper
+	breaker *breaker
+	slots   chan struct{}
+}
+
+// New оборачивает base, по умолчанию http.DefaultTransport.
+func New(name string, policy Policy, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		name:    name,
+		policy:  policy,
+		base:    base,
+		breaker: &breaker{threshold: policy.FailureThreshold, openTimeout: policy.OpenTimeout},
+		slots:   make(chan struct{}, policy.MaxConcurrent),
+	}
+}
+
+func (t *Transport) Name() string {
+	return t.name
+}
+
+// Client возвращает http клиент поверх Transport. Время запроса
+// ограничивает Policy.Timeout каждой попытки.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	select {
+	case t.slots <- struct{}{}:
+	default:
+		metrics.ObserveDownstream(t.name, req.Method, errorStatus(ErrBulkheadFull), time.Since(start))
+		return nil, ErrBulkheadFull
+	}
+
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		metrics.ObserveDownstream(t.name, req.Method, errorStatus(err), time.Since(start))
+		<-t.slots
+		return nil, err
+	}
+	metrics.ObserveDownstream(t.name, req.Method, strconv.Itoa(resp.StatusCode), time.Since(start))
+
+	// Место освобождается, когда тело ответа прочитано и закрыто
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { <-t.slots }}
+	return resp, nil
+}
+
+func (t *Transport) roundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if retryable(req) {
+		retries = t.policy.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if !t.breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		resp, cancel, err := t.attempt(req, attempt)
+		// Отмену запроса клиентом не считаем ни отказом, ни успехом сервиса
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			t.breaker.skip()
+		default:
+			t.breaker.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+		}
+
+		if attempt >= retries || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		select {
+		case <-time.After(t.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func (t *Transport) attempt(req *http.Request, attempt int) (*http.Response, context.CancelFunc, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if t.policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), t.policy.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Context())
+	}
+
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err := t.base.RoundTrip(attemptReq)
+	if err != nil {
+		cancel()
+		return nil, cancel, err
+	}
+	return resp, cancel, nil
+}
+
+// errorStatus - значение метки status для запроса, который завершился
+// ошибкой без ответа сервиса.
+func errorStatus(err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrBulkheadFull):
+		return "bulkhead_full"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "error"
+}
+
+// backoff - экспоненциальная задержка с полным случайным разбросом, чтобы
+// повторы разных запросов не приходили в сервис одновременно.
+func (t *Transport) backoff(attempt int) time.Duration {
+	limit := t.policy.MinBackoff << attempt
+	if limit <= 0 || limit > t.policy.MaxBackoff {
+		limit = t.policy.MaxBackoff
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) + 1
+}
+
+// retryable разрешает повторять только безопасные запросы. POST, PUT
+// и DELETE не повторяются: если ответ потерян, изменение уже могло быть
+// выполнено, и повтор выполнил бы его дважды.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// releaseBody вызывает release один раз при закрытии тела ответа.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,219 @@
+package outbound_test
+
+import (
+	"context"
+	"io"
+	"manage-service/pkg/outbound"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = outbound.Policy{
+	Timeout:          time.Second,
+	Retries:          2,
+	MinBackoff:       time.Millisecond,
+	MaxBackoff:       5 * time.Millisecond,
+	MaxConcurrent:    4,
+	FailureThreshold: 3,
+	OpenTimeout:      50 * time.Millisecond,
+}
+
+// newServer отвечает статусами statuses по очереди, последний повторяется.
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		w.WriteHeader(statuses[min(n, len(statuses)-1)])
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func do(t *testing.T, client *http.Client, method, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	server, calls := newServer(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+	client := outbound.New("core", testPolicy, nil).Client()
+
+	resp, err := do(t, client, http.MethodGet, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestNoRetryNonIdempotent(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		server, calls := newServer(t, http.StatusServiceUnavailable, http.StatusOK)
+		client := outbound.New("core", testPolicy, nil).Client()
+
+		resp, err := do(t, client, method, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, method)
+		assert.Equal(t, int32(1), calls.Load(), method)
+	}
+}
+
+func TestNoRetryClientError(t *testing.T) {
+	server, calls := newServer(t, http.StatusNotFound)
+	client := outbound.New("core", testPolicy, nil).Client()
+
+	resp, err := do(t, client, http.MethodGet, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestRetryBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	client := outbound.New("core", testPolicy, nil).Client()
+
+	req, err := http.NewRequest(http.MethodOptions, server.URL, strings.NewReader("body"))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{"body", "body"}, bodies)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, calls := newServer(t, http.StatusInternalServerError)
+	policy := testPolicy
+	policy.Retries = 0
+	transport := outbound.New("authoriz", policy, nil)
+	client := transport.Client()
+
+	for range policy.FailureThreshold {
+		_, err := do(t, client, http.MethodGet, server.URL)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, outbound.StateOpen, transport.Stats().State)
+
+	// Разомкнутая цепь отвечает сразу, не обращаясь к сервису
+	_, err := do(t, client, http.MethodGet, server.URL)
+	assert.ErrorIs(t, err, outbound.ErrCircuitOpen)
+	assert.True(t, outbound.Unavailable(err))
+	assert.Equal(t, int32(policy.FailureThreshold), calls.Load())
+
+	// После OpenTimeout одна пробная попытка; неудачная снова размыкает цепь
+	time.Sleep(policy.OpenTimeout)
+	assert.Equal(t, outbound.StateHalfOpen, transport.Stats().State)
+	_, err = do(t, client, http.MethodGet, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, outbound.StateOpen, transport.Stats().State)
+}
+
+func TestCircuitBreakerRecovers(t *testing.T) {
+	server, _ := newServer(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK)
+	policy := testPolicy
+	policy.Retries = 0
+	policy.FailureThreshold = 2
+	transport := outbound.New("auth", policy, nil)
+	client := transport.Client()
+
+	do(t, client, http.MethodGet, server.URL)
+	do(t, client, http.MethodGet, server.URL)
+	require.Equal(t, outbound.StateOpen, transport.Stats().State)
+
+	time.Sleep(policy.OpenTimeout)
+	resp, err := do(t, client, http.MethodGet, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, outbound.Stats{State: outbound.StateClosed}, transport.Stats())
+}
+
+func TestBulkhead(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	policy := testPolicy
+	policy.MaxConcurrent = 1
+	transport := outbound.New("core", policy, nil)
+	client := transport.Client()
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		close(started)
+		_, err := do(t, client, http.MethodGet, server.URL)
+		done <- err
+	}()
+	<-started
+	require.Eventually(t, func() bool { return transport.Stats().InFlight == 1 }, time.Second, time.Millisecond)
+
+	_, err := do(t, client, http.MethodGet, server.URL)
+	assert.ErrorIs(t, err, outbound.ErrBulkheadFull)
+
+	release <- struct{}{}
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, transport.Stats().InFlight)
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	policy := testPolicy
+	policy.Timeout = 20 * time.Millisecond
+	policy.Retries = 1
+	client := outbound.New("core", policy, nil).Client()
+
+	start := time.Now()
+	_, err := do(t, client, http.MethodGet, server.URL)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, outbound.Unavailable(err))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// Отмена запроса клиентом не размыкает цепь
+func TestCanceledRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	policy := testPolicy
+	policy.FailureThreshold = 1
+	transport := outbound.New("core", policy, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	_, err = transport.Client().Do(req)
+	assert.Error(t, err)
+	assert.Equal(t, outbound.StateClosed, transport.Stats().State)
+}
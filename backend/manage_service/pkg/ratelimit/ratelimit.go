@@ -0,0 +1,135 @@
+// Package ratelimit ограничивает частоту запросов клиентов по алгоритму
+// корзины токенов. Состояние корзин хранит Store: в памяти, если
+// экземпляр manage_service один, или в redis, общем для всех реплик.
+package ratelimit
+
+import (
+	"common/config"
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Policy - корзина на Burst запросов, которая пополняется на Limit
+// запросов за Period.
+type Policy struct {
+	// Name отделяет корзины разных групп маршрутов.
+	Name   string
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// rate - число токенов, которое корзина получает за секунду.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// Result - решение по запросу и состояние корзины после него.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset - через сколько корзина снова будет полной.
+	Reset time.Duration
+	// RetryAfter - через сколько появится токен, если запрос отклонён.
+	RetryAfter time.Duration
+}
+
+// Store забирает токен из корзины key, если он есть.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+}
+
+// refill пополняет корзину, в которой было tokens токенов, за время
+// elapsed. У новой корзины tokens < 0, она сразу полная.
+func refill(tokens float64, elapsed time.Duration, policy Policy) float64 {
+	if tokens < 0 {
+		return float64(policy.Burst)
+	}
+	return math.Min(float64(policy.Burst), tokens+max(elapsed.Seconds(), 0)*policy.rate())
+}
+
+// result описывает корзину, в которой после запроса осталось tokens токенов.
+func result(tokens float64, allowed bool, policy Policy) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     policy.Burst,
+		Remaining: int(tokens),
+		Reset:     seconds((float64(policy.Burst) - tokens) / policy.rate()),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / policy.rate())
+	}
+	return r
+}
+
+// seconds округляет s до миллисекунд, чтобы погрешность дробей
+// не превращалась в лишнюю секунду в заголовках.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s*1000)) * time.Millisecond
+}
+
+// Policies - политики групп маршрутов: вход и регистрация, каталог
+// и запросы авторизованных клиентов. Token ограничивает по адресу
+// проверку access токенов и API ключей, чтобы их нельзя было
+// подбирать без ограничений.
+type Policies struct {
+	Auth    Policy
+	Catalog Policy
+	Token   Policy
+	User    Policy
+}
+
+type Config struct {
+	Store    string
+	RedisURL string
+	// TrustedProxies - адреса прокси, которым можно верить в заголовке
+	// X-Forwarded-For. Без них клиентом считается адрес соединения.
+	TrustedProxies []string
+	Policies       Policies
+}
+
+// LoadConfig читает хранилище ratelimit_store (memory или redis, тогда
+// нужен ratelimit_redis_url), доверенные прокси trusted_proxies и
+// политики групп: ratelimit_auth_per_minute и ratelimit_auth_burst
+// для входа и регистрации по адресу клиента, ratelimit_catalog_* для
+// каталога и изображений, ratelimit_token_* для проверки токена по адресу
+// клиента и ratelimit_user_* для запросов с access токеном или API ключом.
+func LoadConfig() (Config, error) {
+	l := &config.Loader{}
+
+	policy := func(name string, perMinute, burst int) Policy {
+		return Policy{
+			Name:   name,
+			Limit:  l.IntOr("ratelimit_"+name+"_per_minute", perMinute, 1, 1000000),
+			Period: time.Minute,
+			Burst:  l.IntOr("ratelimit_"+name+"_burst", burst, 1, 1000000),
+		}
+	}
+
+	cfg := Config{
+		Store:          l.OneOf("ratelimit_store", StoreMemory, StoreMemory, StoreRedis),
+		TrustedProxies: l.ListOr("trusted_proxies"),
+		Policies: Policies{
+			Auth:    policy("auth", 10, 5),
+			Catalog: policy("catalog", 300, 60),
+			Token:   policy("token", 300, 60),
+			User:    policy("user", 120, 30),
+		},
+	}
+	if cfg.Store == StoreRedis {
+		cfg.RedisURL = l.String("ratelimit_redis_url")
+	}
+
+	if err := l.Err(); err != nil {
+		return cfg, fmt.Errorf("ratelimit/LoadConfig: %w", err)
+	}
+	return cfg, nil
+}
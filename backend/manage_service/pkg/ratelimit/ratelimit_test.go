@@ -0,0 +1,128 @@
+package ratelimit_test
+
+import (
+	"context"
+	"manage-service/pkg/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// policy пропускает 3 запроса подряд и затем один запрос в 10 секунд.
+var policy = ratelimit.Policy{Name: "test", Limit: 6, Period: time.Minute, Burst: 3}
+
+// testStore проверяет поведение корзины, общее для всех хранилищ.
+func testStore(t *testing.T, store ratelimit.Store) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	take := func(key string, now time.Time) ratelimit.Result {
+		t.Helper()
+		result, err := store.Take(ctx, key, policy, now)
+		require.NoError(t, err)
+		return result
+	}
+
+	result := take("a", start)
+	assert.Equal(t, ratelimit.Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 10 * time.Second}, result)
+	take("a", start)
+	result = take("a", start)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 30*time.Second, result.Reset)
+
+	result = take("a", start.Add(4*time.Second))
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 6*time.Second, result.RetryAfter)
+
+	// У другого ключа своя корзина
+	assert.True(t, take("b", start).Allowed)
+
+	// Через 10 секунд появляется один токен
+	result = take("a", start.Add(10*time.Second))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.False(t, take("a", start.Add(10*time.Second)).Allowed)
+
+	// Корзина не переполняется
+	result = take("a", start.Add(time.Hour))
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, ratelimit.NewMemoryStore())
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	start := time.Now()
+
+	for _, key := range []string{"a", "b"} {
+		_, err := store.Take(context.Background(), key, policy, start)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, store.Len())
+
+	// Пополнившиеся корзины удаляются, остаётся только новая
+	_, err := store.Take(context.Background(), "c", policy, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	testStore(t, ratelimit.NewRedisStore(client, "ratelimit:"))
+
+	// Пустая корзина удаляется, когда снова станет полной
+	assert.True(t, server.Exists("ratelimit:b"))
+	server.FastForward(11 * time.Second)
+	assert.False(t, server.Exists("ratelimit:b"))
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+	server.Close()
+
+	_, err := ratelimit.NewRedisStore(client, "").Take(context.Background(), "a", policy, time.Now())
+	assert.Error(t, err)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("ratelimit_store", "")
+	t.Setenv("ratelimit_auth_per_minute", "20")
+	t.Setenv("trusted_proxies", "10.0.0.0/8, 127.0.0.1")
+
+	cfg, err := ratelimit.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.StoreMemory, cfg.Store)
+	assert.Equal(t, ratelimit.Policy{Name: "auth", Limit: 20, Period: time.Minute, Burst: 5}, cfg.Policies.Auth)
+	assert.Equal(t, 300, cfg.Policies.Catalog.Limit)
+	assert.Equal(t, "token", cfg.Policies.Token.Name)
+	assert.Equal(t, []string{"10.0.0.0/8", "127.0.0.1"}, cfg.TrustedProxies)
+
+	store, err := ratelimit.NewStore(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &ratelimit.MemoryStore{}, store)
+
+	// Для redis нужен адрес
+	t.Setenv("ratelimit_store", "redis")
+	_, err = ratelimit.LoadConfig()
+	assert.ErrorContains(t, err, "ratelimit_redis_url")
+
+	t.Setenv("ratelimit_redis_url", "redis://localhost:6379/1")
+	cfg, err = ratelimit.LoadConfig()
+	require.NoError(t, err)
+	store, err = ratelimit.NewStore(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &ratelimit.RedisStore{}, store)
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript атомарно пополняет корзину и забирает из неё токен.
+// Корзина хранится в hash с полями tokens и updated (миллисекунды) и
+// удаляется, когда снова станет полной.
+var takeScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = burst
+	updated = now
+end
+
+tokens = math.min(burst, tokens + math.max(now - updated, 0) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore хранит корзины в redis, общем для всех реплик
+// manage_service. Время берётся из часов реплики, поэтому их часы должны
+// быть синхронизированы.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore хранит корзины под ключами с префиксом prefix.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	perMillisecond := policy.rate() / 1000
+	values, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		policy.Burst, strconv.FormatFloat(perMillisecond, 'g', -1, 64), now.UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit/RedisStore.Take: %w", err)
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit/RedisStore.Take: неожиданный ответ %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	text, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit/RedisStore.Take: %w", err)
+	}
+	return result(tokens, allowed == 1, policy), nil
+}
+
+// NewStore создаёт хранилище, выбранное в cfg.
+func NewStore(cfg Config) (Store, error) {
+	if cfg.Store != StoreRedis {
+		return NewMemoryStore(), nil
+	}
+
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit/NewStore: %w", err)
+	}
+	return NewRedisStore(redis.NewClient(opts), "ratelimit:"), nil
+}
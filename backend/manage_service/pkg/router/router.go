@@ -0,0 +1,110 @@
+// Package router собирает публичные http маршруты сервиса.
+package router
+
+import (
+	"common/health"
+	"common/metrics/ginmetrics"
+	"common/openapi"
+	"manage-service/api"
+	"manage-service/pkg/client"
+	"manage-service/pkg/handlers"
+	"manage-service/pkg/middleware"
+	"manage-service/pkg/outbound"
+	"manage-service/pkg/ratelimit"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout ограничивает проверку готовности каждого сервиса.
+const readyTimeout = 2 * time.Second
+
+// New возвращает gin с маршрутами всех обработчиков и спецификацией API.
+// Частота запросов к группам маршрутов ограничивается политиками
+// policies, корзины хранит store. Состояние dependencies отдаётся в /health,
+// готовность сервисов - в /readyz.
+func New(auth *client.Auth, authoriz *client.Authoriz, core *client.Core, store ratelimit.Store, policies ratelimit.Policies, dependencies ...*outbound.Transport) *gin.Engine {
+	handler := handlers.NewHandler(auth, authoriz, core)
+	authLimit := middleware.RateLimit(store, policies.Auth)
+	catalogLimit := middleware.RateLimit(store, policies.Catalog)
+	// Шлюз готов, когда готовы все сервисы, к которым он обращается
+	checker := health.New(readyTimeout).Add("auth", auth.Ready).Add("authoriz", authoriz.Ready).Add("core", core.Ready)
+
+	// Запросы пишет в лог tracing.Handler вместе с идентификатором запроса
+	r := gin.New()
+	r.Use(gin.Recovery(), ginmetrics.Middleware, middleware.Actor())
+
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"Content-Length", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	public := r.Group("/")
+	{
+		public.GET(openapi.Path, gin.WrapF(openapi.Handler(api.Spec)))
+		public.GET("/health", handlers.Health(dependencies...))
+		public.GET(health.LivePath, gin.WrapH(health.Live()))
+		public.GET(health.ReadyPath, gin.WrapH(checker.Ready()))
+		public.POST("/registration", authLimit, handler.Registration)
+		public.POST("/login", authLimit, handler.Login)
+		public.GET("/product", catalogLimit, middleware.OptionalAPIKey(authoriz, middleware.ScopeProductRead), handler.GetAllProduct)
+		public.GET("/product/:id", catalogLimit, middleware.OptionalAPIKey(authoriz, middleware.ScopeProductRead), handler.GetProduct)
+		public.GET("/images/:key", catalogLimit, handler.GetImage)
+		public.HEAD("/images/:key", catalogLimit, handler.GetImage)
+	}
+
+	protected := r.Group("/")
+	// Адрес ограничивается до проверки токена: иначе неправильные токены
+	// подбирались бы без ограничений, а каждый стоил бы запроса к authoriz
+	protected.Use(middleware.RateLimit(store, policies.Token), middleware.AuthMiddleware(authoriz), middleware.RateLimit(store, policies.User))
+	{
+		protected.POST("/logout", handler.Logout)
+		protected.POST("/product", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.CreateProduct)
+		protected.PUT("/product/:id", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.UpdateProduct)
+		protected.DELETE("/product/:id", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.DeleteProduct)
+		protected.POST("/product/:id/confirm", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.ConfirmProductImages)
+		protected.PUT("/product/:id/images/order", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.ReorderImages)
+		protected.PUT("/product/:id/images/:image_id/primary", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.SetPrimaryImage)
+		protected.PUT("/product/:id/images/:image_id/alt", middleware.AdminOrScope(middleware.ScopeProductWrite), handler.SetImageAlt)
+		protected.PUT("/product/change", middleware.RequireScope(middleware.ScopeProductCount), handler.ChangeCountProduct)
+	}
+
+	me := protected.Group("/me")
+	me.Use(middleware.UserOnly())
+	{
+		me.GET("", handler.GetMe)
+		me.PATCH("", handler.UpdateMe)
+		me.PUT("/password", handler.ChangePassword)
+		me.GET("/address", handler.ListAddresses)
+		me.POST("/address", handler.CreateAddress)
+		me.PUT("/address/:id", handler.UpdateAddress)
+		me.DELETE("/address/:id", handler.DeleteAddress)
+		me.PUT("/address/:id/default", handler.SetDefaultAddress)
+		me.GET("/export", handler.ExportMe)
+		me.GET("/deletion", handler.GetDeletion)
+		me.POST("/deletion", handler.RequestDeletion)
+		me.DELETE("/deletion", handler.CancelDeletion)
+	}
+
+	apiKeys := protected.Group("/apikey")
+	apiKeys.Use(middleware.AdminOnly())
+	{
+		apiKeys.POST("", handler.CreateAPIKey)
+		apiKeys.GET("", handler.ListAPIKeys)
+		apiKeys.DELETE("/:id", handler.RevokeAPIKey)
+	}
+
+	admin := protected.Group("/admin")
+	admin.Use(middleware.AdminOnly())
+	{
+		admin.GET("/audit", handler.AuditLog)
+		admin.PUT("/users/:id/admin", handler.GrantAdmin)
+	}
+
+	return r
+}
@@ -0,0 +1,465 @@
+package router_test
+
+import (
+	"common/metrics"
+	"common/openapi/contract"
+	"common/proto/authpb"
+	"common/proto/authzpb"
+	"common/proto/catalogpb"
+	"common/response"
+	"common/rpc"
+	"context"
+	"encoding/json"
+	"manage-service/api"
+	"manage-service/pkg/client"
+	"manage-service/pkg/outbound"
+	"manage-service/pkg/ratelimit"
+	"manage-service/pkg/router"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	problem := struct {
+		Code string `json:"code"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	return problem.Code
+}
+
+// unlimited не мешает тестам, которые не проверяют ограничение запросов.
+var unlimited = ratelimit.Policies{
+	Auth:    ratelimit.Policy{Name: "auth", Limit: 1000, Period: time.Second, Burst: 1000},
+	Catalog: ratelimit.Policy{Name: "catalog", Limit: 1000, Period: time.Second, Burst: 1000},
+	Token:   ratelimit.Policy{Name: "token", Limit: 1000, Period: time.Second, Burst: 1000},
+	User:    ratelimit.Policy{Name: "user", Limit: 1000, Period: time.Second, Burst: 1000},
+}
+
+// serve запускает сервис в памяти и возвращает соединение с ним.
+func serve(t *testing.T, register func(*grpc.Server)) *grpc.ClientConn {
+	server := grpc.NewServer()
+	register(server)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type fakeAuth struct {
+	authpb.UnimplementedAuthServer
+}
+
+func (fakeAuth) Login(context.Context, *authpb.Credentials) (*authpb.User, error) {
+	return &authpb.User{Id: "0b6f2a52-3e0e-4b55-9c3d-8a1d6f0c1e11"}, nil
+}
+
+type fakeAuthz struct {
+	authzpb.UnimplementedAuthzServer
+}
+
+func (fakeAuthz) IssueTokens(context.Context, *authzpb.IssueTokensRequest) (*authzpb.Tokens, error) {
+	return &authzpb.Tokens{Access: "access", Refresh: "refresh"}, nil
+}
+
+// Introspect принимает любой access токен, кроме "expired", администратор -
+// владелец токена "admin".
+func (fakeAuthz) Introspect(_ context.Context, req *authzpb.IntrospectRequest) (*authzpb.Principal, error) {
+	if req.Access == "expired" {
+		return nil, rpc.Errorf(http.StatusUnauthorized, response.CodeTokenExpired, "Токен истёк")
+	}
+	return &authzpb.Principal{UserId: "0b6f2a52-3e0e-4b55-9c3d-8a1d6f0c1e11", Admin: req.Access == "admin"}, nil
+}
+
+func (fakeAuthz) Refresh(context.Context, *authzpb.Tokens) (*authzpb.Tokens, error) {
+	return &authzpb.Tokens{Access: "new-access", Refresh: "new-refresh"}, nil
+}
+
+// rejectingAuthz отклоняет все access токены и считает проверки.
+type rejectingAuthz struct {
+	authzpb.UnimplementedAuthzServer
+	introspected *atomic.Int32
+}
+
+func (a rejectingAuthz) Introspect(context.Context, *authzpb.IntrospectRequest) (*authzpb.Principal, error) {
+	a.introspected.Add(1)
+	return nil, status.Error(codes.Unauthenticated, "Токен недействителен")
+}
+
+type fakeCatalog struct {
+	catalogpb.UnimplementedCatalogServer
+}
+
+func (fakeCatalog) ListProducts(context.Context, *catalogpb.ListProductsRequest) (*catalogpb.ListProductsResponse, error) {
+	return &catalogpb.ListProductsResponse{Products: []*catalogpb.Product{{
+		Id:    1,
+		Name:  "Телефон",
+		Count: 3,
+		Images: []*catalogpb.Image{{
+			Id:        2,
+			Url:       "/images/a.png",
+			Alt:       map[string]string{"ru": "Телефон"},
+			IsPrimary: true,
+			Variants:  &catalogpb.ImageVariants{Thumbnail: "/images/a.png", Card: "/images/a.png", Full: "/images/a.png"},
+		}},
+	}}}, nil
+}
+
+func TestContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Сервисы отвечают только на вызовы, которые нужны тесту, остальные
+	// возвращают Unimplemented
+	handler := router.New(
+		client.NewAuth("http://auth", http.DefaultClient, serve(t, func(s *grpc.Server) { authpb.RegisterAuthServer(s, fakeAuth{}) })),
+		client.NewAuthoriz("http://authoriz", http.DefaultClient, serve(t, func(s *grpc.Server) { authzpb.RegisterAuthzServer(s, fakeAuthz{}) })),
+		client.NewCore("http://core", http.DefaultClient, serve(t, func(s *grpc.Server) { catalogpb.RegisterCatalogServer(s, fakeCatalog{}) })),
+		ratelimit.NewMemoryStore(), unlimited,
+	)
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for header, value := range headers {
+			req.Header.Set(header, value)
+		}
+
+		rec, err := validator.Do(handler, req)
+		require.NoError(t, err)
+		return rec
+	}
+
+	rec := do("GET", "/openapi.json", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, string(api.Spec), rec.Body.String())
+
+	rec = do("POST", "/login", `{"login":"user","password":"pass"}`, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Set-Cookie"), "refreshToken=refresh")
+
+	rec = do("GET", "/product", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"is_primary":true`)
+
+	rec = do("GET", "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	for _, path := range []string{"/registration", "/login"} {
+		rec = do("POST", path, "{", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+		assert.Equal(t, "BAD_REQUEST", problemCode(t, rec))
+	}
+
+	protected := []struct {
+		method, path string
+	}{
+		{"POST", "/logout"},
+		{"POST", "/product"},
+		{"PUT", "/product/1"},
+		{"DELETE", "/product/1"},
+		{"PUT", "/product/change"},
+		{"POST", "/product/1/confirm"},
+		{"PUT", "/product/1/images/order"},
+		{"PUT", "/product/1/images/2/primary"},
+		{"PUT", "/product/1/images/2/alt"},
+		{"GET", "/me"},
+		{"PATCH", "/me"},
+		{"PUT", "/me/password"},
+		{"GET", "/me/address"},
+		{"POST", "/me/address"},
+		{"PUT", "/me/address/1"},
+		{"DELETE", "/me/address/1"},
+		{"PUT", "/me/address/1/default"},
+		{"GET", "/me/export"},
+		{"GET", "/me/deletion"},
+		{"POST", "/me/deletion"},
+		{"DELETE", "/me/deletion"},
+		{"POST", "/apikey"},
+		{"GET", "/apikey"},
+		{"DELETE", "/apikey/1"},
+		{"GET", "/admin/audit"},
+		{"PUT", "/admin/users/1/admin"},
+	}
+	for _, tc := range protected {
+		rec = do(tc.method, tc.path, "{}", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s", tc.method, tc.path)
+		assert.Equal(t, "UNAUTHORIZED", problemCode(t, rec))
+	}
+
+	rec = do("GET", "/me", "", map[string]string{"Authorization": "Token abc"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "UNAUTHORIZED", problemCode(t, rec))
+
+	rec = do("GET", "/me", "", map[string]string{"Authorization": "Bearer abc"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "refresh")
+
+	// Покупатель не может менять каталог и назначать администраторов
+	customer := map[string]string{"Authorization": "Bearer customer", "Cookie": "refreshToken=refresh"}
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{"POST", "/product"},
+		{"PUT", "/product/1"},
+		{"DELETE", "/product/1"},
+		{"POST", "/product/1/confirm"},
+		{"PUT", "/product/1/images/order"},
+		{"PUT", "/product/1/images/2/primary"},
+		{"PUT", "/product/1/images/2/alt"},
+		{"PUT", "/admin/users/1/admin"},
+	} {
+		rec = do(tc.method, tc.path, "{}", customer)
+		assert.Equal(t, http.StatusForbidden, rec.Code, "%s %s", tc.method, tc.path)
+		assert.Equal(t, "FORBIDDEN", problemCode(t, rec))
+	}
+	rec = do("DELETE", "/product/1", "", map[string]string{"Authorization": "Bearer admin", "Cookie": "refreshToken=refresh"})
+	assert.NotEqual(t, http.StatusForbidden, rec.Code)
+
+	// Метрики не отдаются на публичном порту
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", metrics.Path, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Запросы учитываются по шаблону маршрута, а не по пути
+	rec = httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", metrics.Path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="GET",route="/product",status="200"}`)
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="DELETE",route="/product/:id",status="401"}`)
+}
+
+// TestRefreshExpiredAccess идёт через настоящий сервер: ResponseRecorder
+// сохраняет и статус 1xx, который net/http не отправил бы как окончательный.
+func TestRefreshExpiredAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := httptest.NewServer(router.New(
+		client.NewAuth("http://auth", http.DefaultClient, nil),
+		client.NewAuthoriz("http://authoriz", http.DefaultClient, serve(t, func(s *grpc.Server) { authzpb.RegisterAuthzServer(s, fakeAuthz{}) })),
+		client.NewCore("http://core", http.DefaultClient, nil),
+		ratelimit.NewMemoryStore(), unlimited,
+	))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"/me", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer expired")
+	req.AddCookie(&http.Cookie{Name: "refreshToken", Value: "refresh"})
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, response.ProblemContentType, resp.Header.Get("Content-Type"))
+	problem := struct {
+		Code   string `json:"code"`
+		Status int    `json:"status"`
+		Access string `json:"access"`
+	}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, string(response.CodeTokenExpired), problem.Code)
+	assert.Equal(t, http.StatusUnauthorized, problem.Status)
+	assert.Equal(t, "new-access", problem.Access)
+	assert.Contains(t, resp.Header.Get("Set-Cookie"), "refreshToken=new-refresh")
+}
+
+func TestContractUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Сервисы остановлены: первая же неудачная попытка размыкает цепь
+	stopped := httptest.NewServer(http.NotFoundHandler())
+	stopped.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	stoppedRPC := listener.Addr().String()
+	listener.Close()
+
+	policy := outbound.Policy{Timeout: time.Second, MaxConcurrent: 1, FailureThreshold: 1, OpenTimeout: time.Minute}
+	auth := outbound.New("auth", policy, nil)
+	authoriz := outbound.New("authoriz", policy, nil)
+	core := outbound.New("core", policy, nil)
+	dial := func(transport *outbound.Transport) *grpc.ClientConn {
+		conn, err := client.Dial(stoppedRPC, rpc.TLS{}, transport)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	handler := router.New(
+		client.NewAuth(stopped.URL, auth.Client(), dial(auth)),
+		client.NewAuthoriz(stopped.URL, authoriz.Client(), dial(authoriz)),
+		client.NewCore(stopped.URL, core.Client(), dial(core)),
+		ratelimit.NewMemoryStore(), unlimited,
+		auth, authoriz, core,
+	)
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec, err := validator.Do(handler, httptest.NewRequest(method, path, nil))
+		require.NoError(t, err)
+		return rec
+	}
+
+	rec := do("GET", "/health")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok","dependencies":{
+		"auth":{"state":"closed","failures":0,"in_flight":0},
+		"authoriz":{"state":"closed","failures":0,"in_flight":0},
+		"core":{"state":"closed","failures":0,"in_flight":0}}}`, rec.Body.String())
+
+	// Отказ соединения по gRPC сразу означает недоступность, а следующий
+	// запрос отклоняет уже разомкнутая цепь
+	for range 2 {
+		rec = do("GET", "/product")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "SERVICE_UNAVAILABLE", problemCode(t, rec))
+	}
+
+	rec = do("GET", "/health")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	health := struct {
+		Status       string                    `json:"status"`
+		Dependencies map[string]outbound.Stats `json:"dependencies"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+	assert.Equal(t, "degraded", health.Status)
+	assert.Equal(t, outbound.StateOpen, health.Dependencies["core"].State)
+	assert.Equal(t, outbound.StateClosed, health.Dependencies["auth"].State)
+
+	// Пока сервисы недоступны, шлюз не готов принимать запросы
+	rec = do("GET", "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	ready := struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status string `json:"status"`
+		} `json:"checks"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ready))
+	assert.Equal(t, "fail", ready.Status)
+	for _, name := range []string{"auth", "authoriz", "core"} {
+		assert.Equal(t, "fail", ready.Checks[name].Status, name)
+	}
+}
+
+func TestContractRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policies := unlimited
+	policies.Auth = ratelimit.Policy{Name: "auth", Limit: 1, Period: time.Minute, Burst: 2}
+	handler := router.New(
+		client.NewAuth("http://auth", http.DefaultClient, serve(t, func(s *grpc.Server) { authpb.RegisterAuthServer(s, fakeAuth{}) })),
+		client.NewAuthoriz("http://authoriz", http.DefaultClient, serve(t, func(s *grpc.Server) { authzpb.RegisterAuthzServer(s, fakeAuthz{}) })),
+		client.NewCore("http://core", http.DefaultClient, nil),
+		ratelimit.NewMemoryStore(), policies,
+	)
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	login := func(ip string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"login":"user","password":"pass"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":40000"
+		rec, err := validator.Do(handler, req)
+		require.NoError(t, err)
+		return rec
+	}
+
+	rec := login("10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1;w=60;burst=2", rec.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", rec.Header().Get("RateLimit-Reset"))
+
+	rec = login("10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = login("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "RATE_LIMITED", problemCode(t, rec))
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	assert.InDelta(t, 60, retryAfter, 1)
+
+	// У другого адреса своя корзина, а спецификация не ограничивается
+	rec = login("10.0.0.2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec, err = validator.Do(handler, httptest.NewRequest("GET", "/openapi.json", nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
+
+func TestContractRateLimitBeforeAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policies := unlimited
+	policies.Token = ratelimit.Policy{Name: "token", Limit: 1, Period: time.Minute, Burst: 2}
+	introspected := &atomic.Int32{}
+	handler := router.New(
+		client.NewAuth("http://auth", http.DefaultClient, nil),
+		client.NewAuthoriz("http://authoriz", http.DefaultClient, serve(t, func(s *grpc.Server) {
+			authzpb.RegisterAuthzServer(s, rejectingAuthz{introspected: introspected})
+		})),
+		client.NewCore("http://core", http.DefaultClient, nil),
+		ratelimit.NewMemoryStore(), policies,
+	)
+
+	validator, err := contract.New(api.Spec)
+	require.NoError(t, err)
+
+	guess := func() *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer guess")
+		req.Header.Set("Cookie", "refreshToken=guess")
+		req.RemoteAddr = "10.0.0.1:40000"
+		rec, err := validator.Do(handler, req)
+		require.NoError(t, err)
+		return rec
+	}
+
+	for range 2 {
+		assert.Equal(t, http.StatusUnauthorized, guess().Code)
+	}
+	// Подбор токенов ограничивается по адресу, и authoriz больше
+	// не спрашивают
+	rec := guess()
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "RATE_LIMITED", problemCode(t, rec))
+	assert.EqualValues(t, 2, introspected.Load())
+}
@@ -14,6 +14,8 @@ import { getCategoryFromParameters } from './utils/parameters';
 import { 
   loadProducts, 
   getFullImageUrl, 
+  getPrimaryImage,
+  getImageAlt,
   deleteProduct 
 } from './utils/loadProductsAndDelete';
 import PersonalAccount from './pages/PersonalAccount';
@@ -247,8 +249,8 @@ function ShoppingList({ isLoggedIn }) {
               <div key={product.id} className="product-card">
                 <Link to={`/product/${product.id}`} className="product-card-link">
                   <img 
-                    src={getFullImageUrl(product.images[0])} 
-                    alt={product.name} 
+                    src={getFullImageUrl(getPrimaryImage(product), 'card')} 
+                    alt={getImageAlt(getPrimaryImage(product), product.name)} 
                     className="product-image" 
                   />
                   <div className="product-details">
@@ -291,7 +293,7 @@ function ShoppingList({ isLoggedIn }) {
           <div className="modal-content" onClick={(e) => e.stopPropagation()}>
             <h2>Подтверждение покупки</h2>
             <div className="modal-product-info">
-              <img src={getFullImageUrl(selectedProduct.images[0])} alt={selectedProduct?.name} />
+              <img src={getFullImageUrl(getPrimaryImage(selectedProduct), 'thumbnail')} alt={getImageAlt(getPrimaryImage(selectedProduct), selectedProduct?.name)} />
               <div>
                 <h3>{selectedProduct?.name}</h3>
                 <p className="price">{selectedProduct?.price.toLocaleString()} ₽</p>
@@ -6,7 +6,7 @@ import axios from 'axios';
 import './Cart.css';
 import Header from '../components/layout/Header/Header'
 import { getCategoryFromParameters } from '../utils/parameters';
-import { getFullImageUrl } from '../utils/loadProductsAndDelete';
+import { getFullImageUrl, getPrimaryImage } from '../utils/loadProductsAndDelete';
 
 
 const Cart = () => {
@@ -135,8 +135,8 @@ const Cart = () => {
   const updateProductCountOnServerBecauseCart = async (productId, quantityChange) => {
     try {
       const response = await axios.put('/product/change', {
-        ID: productId,
-        Count: -quantityChange // Отправляем отрицательное значение
+        id: productId,
+        count: -quantityChange // Отправляем отрицательное значение
       }, {
         headers: {
           'Content-Type': 'application/json'
@@ -281,7 +281,7 @@ const Cart = () => {
                   <div key={item.id} className={`cart-item ${exceedsStock ? 'exceeds-stock' : ''}`}>
                     <div className="item-image">
                       <img 
-                        src={getFullImageUrl(item.images[0])} 
+                        src={getFullImageUrl(getPrimaryImage(item), 'thumbnail')} 
                         alt={item.name}
                         onError={(e) => {
                           e.target.src = '/img/placeholder.jpg';
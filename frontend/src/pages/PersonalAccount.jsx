@@ -8,26 +8,50 @@ import axios from 'axios';
 const PersonalAccount = () => {
     const [isAdmin, setIsAdmin] = useState(false);
     const [loading, setLoading] = useState(true);
+    const [profile, setProfile] = useState(null);
+    const [addresses, setAddresses] = useState([]);
 
     useEffect(() => {
-        // Здесь будет запрос к серверу для проверки прав администратора
-        checkAdminStatus();
+        loadAccount();
     }, []);
 
-    const checkAdminStatus = async () => {
+    const loadAccount = async () => {
         try {
-            // Замените этот пример на реальный запрос к вашему API
-            const response = await axios.get('/api/admin/check-status');
-            setIsAdmin(response.data.isAdmin);
-            
+            const [profileResponse, addressesResponse] = await Promise.all([
+                axios.get('/me'),
+                axios.get('/me/address'),
+            ]);
+            setProfile(profileResponse.data.profile);
+            setIsAdmin(profileResponse.data.profile.admin);
+            setAddresses(addressesResponse.data.addresses || []);
         } catch (error) {
-            console.error('Ошибка при проверке прав администратора:', error);
+            console.error('Ошибка при загрузке личного кабинета:', error);
             setIsAdmin(false);
         } finally {
             setLoading(false);
         }
     };
 
+    const setDefaultAddress = async (id) => {
+        try {
+            await axios.put(`/me/address/${id}/default`);
+            const response = await axios.get('/me/address');
+            setAddresses(response.data.addresses || []);
+        } catch (error) {
+            console.error('Ошибка при смене адреса по умолчанию:', error);
+        }
+    };
+
+    const deleteAddress = async (id) => {
+        try {
+            await axios.delete(`/me/address/${id}`);
+            const response = await axios.get('/me/address');
+            setAddresses(response.data.addresses || []);
+        } catch (error) {
+            console.error('Ошибка при удалении адреса:', error);
+        }
+    };
+
     // Если данные еще загружаются, можно показать заглушку
     if (loading) {
         return (
@@ -68,9 +92,31 @@ const PersonalAccount = () => {
                     <div className="account-section">
                         <h3>Мои данные</h3>
                         <div className="section-content">
-                            <p>Имя: Иван Иванов</p>
-                            <p>Email: example@mail.com</p>
-                            <p>Телефон: +7 (999) 999-99-99</p>
+                            <p>Логин: {profile?.login}</p>
+                            <p>Имя: {profile?.name || 'не указано'}</p>
+                            <p>Телефон: {profile?.phone || 'не указан'}</p>
+                        </div>
+                    </div>
+
+                    <div className="account-section">
+                        <h3>Адреса доставки</h3>
+                        <div className="section-content">
+                            {addresses.length === 0 && <p>Адреса пока не добавлены</p>}
+                            {addresses.map((address) => (
+                                <div key={address.id} className="address-item">
+                                    <p>
+                                        {address.city}, {address.street}, {address.house}
+                                        {address.apartment && `, кв. ${address.apartment}`}
+                                        {address.is_default && ' (по умолчанию)'}
+                                    </p>
+                                    {!address.is_default && (
+                                        <button onClick={() => setDefaultAddress(address.id)}>
+                                            Сделать основным
+                                        </button>
+                                    )}
+                                    <button onClick={() => deleteAddress(address.id)}>Удалить</button>
+                                </div>
+                            ))}
                         </div>
                     </div>
                     
@@ -5,7 +5,7 @@ import axios from 'axios';
 
 import './ProductDetail.css';
 import Header from '../components/layout/Header/Header'
-import { getFullImageUrl } from '../utils/loadProductsAndDelete';
+import { getFullImageUrl, getImageAlt } from '../utils/loadProductsAndDelete';
 import { parseParameters } from '../utils/parameters';
 
 
@@ -182,8 +182,8 @@ const ProductDetail = () => {
               <div className="carousel-slide">
                 {currentImage ? (
                   <img 
-                    src={getFullImageUrl(currentImage)} 
-                    alt={`${product.name} - фото ${currentImageIndex + 1}`}
+                    src={getFullImageUrl(currentImage, 'full')} 
+                    alt={getImageAlt(currentImage, `${product.name} - фото ${currentImageIndex + 1}`)}
                     onError={(e) => {
                       e.target.src = '/img/placeholder.jpg';
                     }}
@@ -240,8 +240,8 @@ const ProductDetail = () => {
                     onClick={() => goToImage(index)}
                   >
                     <img 
-                      src={getFullImageUrl(image)} 
-                      alt={`${product.name} - миниатюра ${index + 1}`}
+                      src={getFullImageUrl(image, 'thumbnail')} 
+                      alt={getImageAlt(image, `${product.name} - миниатюра ${index + 1}`)}
                       onError={(e) => {
                         e.target.src = '/img/placeholder.jpg';
                       }}
@@ -5,6 +5,19 @@ import axios from 'axios';
 import './Registration.css'
 import Header from '../components/layout/Header/Header'
 
+// Сервер возвращает ошибки в формате problem+json со стабильным code,
+// по нему выбирается понятное пользователю сообщение
+const errorMessages = {
+    LOGIN_TAKEN: 'Этот логин уже занят',
+    INVALID_CREDENTIALS: 'Неправильный логин или пароль',
+    VALIDATION_FAILED: 'Проверьте правильность заполнения полей',
+};
+
+function problemMessage(error, fallback) {
+    const problem = error.response?.data;
+    return errorMessages[problem?.code] || problem?.detail || fallback;
+}
+
 function Registration({ onLogin }) {
     return(
         <div className="all_page">
@@ -81,18 +94,18 @@ function RegistrationAndLogin({ onLogin }) {
             const response = await axios.post('/registration', {
                 email: formData.email,
                 phone: formData.phone,
-                username: formData.username,
+                login: formData.username,
                 password: formData.password
             });
 
             // Access токен приходит в теле ответа
-            if (response.data.access_token) {
-                localStorage.setItem('authToken', response.data.access_token);
+            if (response.data.access) {
+                localStorage.setItem('authToken', response.data.access);
                 
                 // Refresh токен автоматически устанавливается в http-only cookie
                 // через заголовок Set-Cookie, ничего делать не нужно
                 
-                onLogin(response.data.access_token);
+                onLogin(response.data.access);
                 setIsActive(true);
                 setTimeout(() => navigate('/'), 1500);
             } else {
@@ -100,13 +113,7 @@ function RegistrationAndLogin({ onLogin }) {
             }
         } catch (error) {
             console.error('Ошибка регистрации:', error);
-            if (error.response?.data?.message) {
-                setError(error.response.data.message);
-            } else if (error.response?.data?.error) {
-                setError(error.response.data.error);
-            } else {
-                setError('Ошибка при регистрации. Попробуйте снова.');
-            }
+            setError(problemMessage(error, 'Ошибка при регистрации. Попробуйте снова.'));
         } finally {
             setLoading(false);
         }
@@ -123,18 +130,18 @@ function RegistrationAndLogin({ onLogin }) {
 
         try {
             const response = await axios.post('/login', {
-                username: loginData.username,
+                login: loginData.username,
                 password: loginData.password
             });
 
             // Access токен приходит в теле ответа
-            if (response.data.access_token) {
-                localStorage.setItem('authToken', response.data.access_token);
+            if (response.data.access) {
+                localStorage.setItem('authToken', response.data.access);
                 
                 // Refresh токен автоматически устанавливается в http-only cookie
                 // через заголовок Set-Cookie, ничего делать не нужно
                 
-                onLogin(response.data.access_token);
+                onLogin(response.data.access);
                 setIsActive(true);
                 setTimeout(() => navigate('/'), 1500);
             } else {
@@ -142,13 +149,7 @@ function RegistrationAndLogin({ onLogin }) {
             }
         } catch (error) {
             console.error('Ошибка входа:', error);
-            if (error.response?.data?.message) {
-                setError(error.response.data.message);
-            } else if (error.response?.data?.error) {
-                setError(error.response.data.error);
-            } else {
-                setError('Ошибка при входе. Проверьте логин и пароль.');
-            }
+            setError(problemMessage(error, 'Ошибка при входе. Проверьте логин и пароль.'));
         } finally {
             setLoading(false);
         }
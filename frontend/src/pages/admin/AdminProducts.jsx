@@ -10,6 +10,7 @@ import {
     validateParameters,
     prepareParametersForSubmit
 } from '../../utils/parameters';
+import { getFullImageUrl, getPrimaryImage } from '../../utils/loadProductsAndDelete';
 
 const AdminProducts = () => {
   const [products, setProducts] = useState([]);
@@ -37,14 +38,7 @@ const AdminProducts = () => {
       const response = await axios.get('/product');
       console.log('📦 Получены товары:', response.data);
       
-      let productsData = [];
-      if (response.data && response.data.Products) {
-        productsData = response.data.Products;
-      } else if (response.data && Array.isArray(response.data)) {
-        productsData = response.data;
-      }
-      
-      setProducts(productsData);
+      setProducts(response.data?.products || []);
     } catch (err) {
       console.error('❌ Ошибка загрузки товаров:', err);
       setError('Не удалось загрузить товары');
@@ -57,8 +51,8 @@ const AdminProducts = () => {
     // Загружаем существующие изображения
     const existingImages = product.images ? product.images.map((image, index) => ({
       id: `existing-${index}`,
-      url: `https://electronic.s3.regru.cloud/products/${image}`,
-      fileName: image,
+      url: getFullImageUrl(image),
+      fileName: image?.url ?? image,
       isExisting: true
     })) : [];
     
@@ -369,7 +363,7 @@ const AdminProducts = () => {
                     <td className="admin-product-image">
                       {product.images && product.images.length > 0 ? (
                         <img 
-                          src={`https://electronic.s3.regru.cloud/products/${product.images[0]}`}
+                          src={getFullImageUrl(getPrimaryImage(product), 'thumbnail')}
                           alt={product.name}
                           onError={(e) => {
                             e.target.src = '/img/placeholder.jpg';
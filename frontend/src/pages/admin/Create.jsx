@@ -25,8 +25,14 @@ const Create = () => {
   const [parameters, setParameters] = useState([]);
   const fileInputRef = useRef(null);
 
+  // sha256 файла в base64: по нему хранилище проверяет загруженный файл
+  const fileChecksum = async (file) => {
+    const digest = await crypto.subtle.digest("SHA-256", await file.arrayBuffer());
+    return btoa(String.fromCharCode(...new Uint8Array(digest)));
+  };
+
   // Функция создания продукта
-  const createProductAndGetUrls = async (productData, fileNames) => {
+  const createProductAndGetUrls = async (productData, files) => {
     try {
       // Формируем строку параметров с помощью новой функции
       const parametersString = prepareParametersForSubmit(parameters, productData.category);
@@ -39,7 +45,14 @@ const Create = () => {
           description: productData.description,
           parameters: parametersString,
           count: Number(productData.count) || 1,
-          images: fileNames,
+          images: await Promise.all(
+            files.map(async (file) => ({
+              name: file.name,
+              content_type: file.type,
+              size: file.size,
+              checksum: await fileChecksum(file),
+            })),
+          ),
         },
         {
           headers: {
@@ -56,15 +69,15 @@ const Create = () => {
   };
 
   // Функция загрузки файлов на S3
-  const uploadFilesToS3 = async (files, s3Urls) => {
+  const uploadFilesToS3 = async (files, uploads) => {
     for (let i = 0; i < files.length; i++) {
       try {
         const file = files[i];
-        const s3Url = s3Urls[i];
+        const upload = uploads[i];
 
-        await axios.put(s3Url, file, {
+        await axios.put(upload.url, file, {
           headers: {
-            "Content-Type": file.type,
+            ...upload.headers,
             "x-amz-acl": "public-read",
           },
         });
@@ -183,18 +196,20 @@ const Create = () => {
     setIsSubmitting(true);
 
     try {
-      const fileNames = previewImages.map((img) => img.file.name);
       const files = previewImages.map((img) => img.file);
 
       console.log("📤 Создаем товар и получаем URLs для загрузки...");
 
-      const response = await createProductAndGetUrls(productData, fileNames);
+      const response = await createProductAndGetUrls(productData, files);
       console.log("✅ Получены URLs для загрузки:", response.urls);
 
       console.log("🔄 Загружаем файлы на S3...");
-      await uploadFilesToS3(files, response.urls);
+      await uploadFilesToS3(files, response.uploads);
       console.log("✅ Все файлы загружены на S3");
 
+      // Подтверждаем загрузку, иначе изображения не появятся в каталоге
+      await axios.post(`/product/${response.id}/confirm`);
+
       alert("Товар успешно добавлен!");
 
       // Сбрасываем форму
@@ -243,7 +258,7 @@ const Create = () => {
                 type="file"
                 ref={fileInputRef}
                 onChange={handleFileInputChange}
-                accept="image/*"
+                accept="image/jpeg,image/png,image/gif,image/webp"
                 multiple
                 style={{ display: "none" }}
               />
@@ -10,12 +10,10 @@ export const getAll = async () => {
       const response = await axios.get('/product');
       console.log('📦 Полный ответ:', response.data);
       
-      if (response.data && response.data.Products) {
-        console.log('✅ Товары найдены:', response.data.Products);
-        return response.data.Products;
-      } else if (response.data && Array.isArray(response.data)) {
-        console.log('✅ Товары (массив):', response.data);
-        return response.data;
+      // Формат ответа описан в /openapi.json
+      if (response.data && Array.isArray(response.data.products)) {
+        console.log('✅ Товары найдены:', response.data.products);
+        return response.data.products;
       } else {
         console.warn('⚠️ Товары не найдены в ответе');
         return [];
@@ -30,8 +28,8 @@ export const getAll = async () => {
 export const updateProductCountOnServer = async (productId, quantityChange) => {
   try {
     const response = await axios.put('/product/change', {
-      ID: productId,
-      Count: -quantityChange // Отправляем отрицательное значение
+      id: productId,
+      count: -quantityChange // Отправляем отрицательное значение
     }, {
       headers: {
         'Content-Type': 'application/json'
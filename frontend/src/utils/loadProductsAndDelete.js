@@ -1,12 +1,31 @@
 import axios from 'axios';
 import { getAll } from '../services/api';
 
-// Базовый URL S3
-export const getFullImageUrl = (filename) => {
-  const url = `https://electronic.s3.regru.cloud/products/${filename}`;
+// Бэкенд отдаёт изображения объектами с постоянными ссылками вида
+// /images/{key} и уменьшенными копиями; голые ключи старых ответов
+// по-прежнему ведут напрямую в бакет S3
+export const getFullImageUrl = (image, size) => {
+  if (image && typeof image === 'object') {
+    return (size && image.variants?.[size]) || image.url;
+  }
+  if (image?.startsWith('/') || image?.startsWith('http')) {
+    return image;
+  }
+  const url = `https://electronic.s3.regru.cloud/products/${image}`;
   return url;
 };
 
+// Главное изображение товара, выбранное администратором
+export const getPrimaryImage = (product) => {
+  const images = product?.images || [];
+  return images.find((image) => image?.is_primary) || images[0];
+};
+
+// Альтернативный текст изображения на русском или запасной вариант
+export const getImageAlt = (image, fallback) => {
+  return image?.alt?.ru || fallback;
+};
+
 // Функция загрузки товаров
 export const loadProducts = async () => {    
   const productsData = await getAll();